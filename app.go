@@ -2,25 +2,131 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"path"
 	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"SystemReinstaller/core"
+	"SystemReinstaller/utils"
+
+	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
+// recentLogCapacity 内存中保留的最近日志条数
+const recentLogCapacity = 1000
+
 // App struct
 type App struct {
-	ctx context.Context
+	ctx            context.Context
+	installer      *core.SystemInstaller
+	apiClient      *core.APIClient
+	catalogs       *core.CatalogSet
+	catalogMutex   sync.RWMutex // 保护 catalogs，更新配置时会整体替换
+	catalogOptions core.CatalogOptions
+	vhdManager     *core.VHDManager
+	driverManager  *core.DriverManager
+	detector       *core.SystemDetector
+	hostTarget     core.HostTarget
+	hostTargetOnce sync.Once
+	backups        *core.DriverBackupIndex // 配置的备份目录的索引，目录变化时重新创建
+	backupMutex    sync.Mutex
+	logger         *utils.Logger
+	recentLogs     *utils.RingSink
 }
 
 // NewApp creates a new App application struct
-func NewApp() *App {
-	return &App{}
+func NewApp(catalogOptions core.CatalogOptions, configOptions core.ConfigOptions) *App {
+	logger := utils.NewLogger()
+	recentLogs := utils.NewRingSink(recentLogCapacity)
+	logger.AddSink(recentLogs)
+
+	apiClient := core.NewAPIClient()
+	apiClient.SetLogger(logger)
+	installer := core.NewSystemInstaller()
+	installer.SetLogger(logger)
+	installer.SetAPIClient(apiClient)
+	installer.SetConfigOptions(configOptions)
+	vhdManager := core.NewVHDManager()
+	vhdManager.SetLogger(logger)
+	driverManager := core.NewDriverManager()
+	driverManager.SetLogger(logger)
+
+	return &App{
+		installer:      installer,
+		apiClient:      apiClient,
+		catalogs:       core.NewCatalogSet(core.NewAPICatalog(core.DefaultAPIBaseURL, apiClient)),
+		catalogOptions: catalogOptions,
+		vhdManager:     vhdManager,
+		driverManager:  driverManager,
+		detector:       core.NewSystemDetector(),
+		logger:         logger,
+		recentLogs:     recentLogs,
+	}
+}
+
+// initialize 加载配置并解压reinstall脚本，失败时程序不能继续运行
+func (a *App) initialize() error {
+	if err := a.installer.Initialize(); err != nil {
+		a.logger.Error("初始化安装器失败", "error", err)
+		return err
+	}
+	if err := a.installer.ConfigLoadError(); err != nil {
+		println("警告:", err.Error(), "（使用默认配置，修复前不会保存配置）")
+	}
+	for _, err := range a.installer.ConfigOverrideErrors() {
+		println("警告: 忽略无效的配置覆盖", err.Error())
+	}
+	return nil
 }
 
 // startup is called when the app starts. The context is saved
 // so we can call the runtime methods
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
-	fmt.Println("App started successfully")
+	a.logger.AddSink(utils.NewEventSink("log:entry", func(name string, data ...interface{}) {
+		wailsruntime.EventsEmit(ctx, name, data...)
+	}))
+
+	// API凭据保存在加密密钥库中，解锁后生效；旧版本的明文凭据在解锁时迁移
+	if creds, err := core.LoadLegacyCredentials(core.LegacyCredentialsPath()); err != nil {
+		a.logger.Warning("加载API凭据失败", "error", err)
+	} else if creds != nil {
+		a.logger.Warning("API凭据以明文保存，解锁密钥库后将迁移到密钥库", "path", core.LegacyCredentialsPath())
+		a.apiClient.SetCredentials(creds)
+	}
+	a.applyConfig(a.installer.Config())
+	if catalogs, err := a.installer.BuildCatalogSet(a.apiClient, a.catalogOptions); err != nil {
+		a.logger.Warning("加载目录来源失败，使用默认API", "error", err)
+	} else {
+		a.setCatalogs(catalogs)
+	}
+	a.logger.Info("App started successfully", "version", core.AppVersion)
+}
+
+// shutdown 程序退出时关闭日志
+func (a *App) shutdown(ctx context.Context) {
+	a.logger.Info("App shutting down")
+	a.logger.Close()
+}
+
+// applyConfig 将生效的配置应用到日志、网络、API客户端和VHD管理器
+func (a *App) applyConfig(config *core.Config) {
+	if level, err := utils.ParseLevel(config.LogLevel); err == nil {
+		a.logger.SetLevel(level)
+	}
+	network := core.NetworkOptionsFromConfig(config, a.installer.Secrets())
+	if err := core.DefaultHTTPTransport().Configure(network); err != nil {
+		a.logger.Error("应用网络配置失败，继续使用原来的设置", "error", err)
+	}
+	a.apiClient.SetTimeout(time.Duration(config.API.Timeout) * time.Second)
+	a.apiClient.SetConcurrency(config.Download.Concurrency)
+	a.vhdManager.SetDownloadConfig(config.Download)
 }
 
 // Greet returns a greeting for the given name
@@ -43,63 +149,193 @@ func (a *App) GetSystemInfo() map[string]interface{} {
 	}
 }
 
-// GetAvailableServers 获取可用服务器列表
-func (a *App) GetAvailableServers() []interface{} {
-	return []interface{}{
-		map[string]interface{}{
-			"id":       "1",
-			"name":     "官方服务器",
-			"location": "北京",
-			"type":     "主服务器",
-			"status":   "online",
-		},
-		map[string]interface{}{
-			"id":       "2",
-			"name":     "镜像服务器",
-			"location": "上海",
+// catalogSet 当前使用的目录来源
+func (a *App) catalogSet() *core.CatalogSet {
+	a.catalogMutex.RLock()
+	defer a.catalogMutex.RUnlock()
+	return a.catalogs
+}
+
+// setCatalogs 替换目录来源，正在进行的查询继续使用原来的集合
+func (a *App) setCatalogs(catalogs *core.CatalogSet) {
+	a.catalogMutex.Lock()
+	defer a.catalogMutex.Unlock()
+	a.catalogs = catalogs
+}
+
+// GetAvailableServers 获取所有目录来源的服务器列表
+func (a *App) GetAvailableServers() ([]interface{}, error) {
+	catalog, err := a.catalogSet().Servers(a.ctx)
+	if err != nil {
+		return nil, translateAPIError(err)
+	}
+	for source, message := range catalog.Errors {
+		a.logger.Warning("目录来源不可用", "source", source, "error", message)
+	}
+
+	result := make([]interface{}, 0, len(catalog.Servers))
+	for _, server := range catalog.Servers {
+		status := "online"
+		if server.Stale {
+			status = "stale"
+		}
+		result = append(result, map[string]interface{}{
+			"id":       server.Key(),
+			"source":   server.Source,
+			"name":     server.Name,
+			"location": server.Location,
 			"type":     "镜像服务器",
-			"status":   "online",
-		},
-	}
-}
-
-// GetVHDListFromServer 从服务器获取VHD列表
-func (a *App) GetVHDListFromServer(serverId interface{}) []interface{} {
-	// 模拟VHD列表数据
-	return []interface{}{
-		map[string]interface{}{
-			"filename":    "windows10_pro_x64_22h2.vhd",
-			"displayName": "Windows 10 Pro x64 22H2",
-			"system":      "Windows 10",
-			"version":     "22H2",
-			"language":    "中文(zh-cn)",
-			"bootMode":    "UEFI",
-			"size":        "4.2 GB",
-		},
-		map[string]interface{}{
-			"filename":    "windows11_pro_x64_23h2.vhd",
-			"displayName": "Windows 11 Pro x64 23H2",
-			"system":      "Windows 11",
-			"version":     "23H2",
-			"language":    "中文(zh-cn)",
-			"bootMode":    "UEFI",
-			"size":        "4.8 GB",
-		},
-		map[string]interface{}{
-			"filename":    "windows_server_2022_datacenter.vhd",
-			"displayName": "Windows Server 2022 Datacenter",
-			"system":      "Windows Server",
-			"version":     "2022",
-			"language":    "英文(en-us)",
-			"bootMode":    "UEFI",
-			"size":        "3.9 GB",
-		},
+			"status":   status,
+			"vhdCount": len(server.Images),
+		})
+	}
+	return result, nil
+}
+
+// GetVHDListFromServer 从服务器获取VHD列表，server可以是服务器ID或前端的服务器对象
+func (a *App) GetVHDListFromServer(server interface{}) ([]interface{}, error) {
+	list, err := a.catalogSet().VHDList(a.ctx, serverIDFromFrontend(server))
+	if err != nil {
+		return nil, translateAPIError(err)
+	}
+
+	// 探测下载地址的大小和断点续传支持，结果有缓存，超时的条目显示为未知
+	urls := make([]string, 0, len(list.VHDs))
+	for _, vhd := range list.VHDs {
+		urls = append(urls, vhd.Image.URL)
+	}
+	probeCtx, cancel := context.WithTimeout(a.ctx, 10*time.Second)
+	probes := a.apiClient.ProbeImages(probeCtx, urls)
+	cancel()
+
+	target := a.detectHostTarget()
+	result := make([]interface{}, 0, len(list.VHDs))
+	for _, vhd := range list.VHDs {
+		image := vhd.Image
+		probe := probes[image.URL]
+		// 探测到的 Content-Length 是下载字节数；只有明确未压缩的镜像才等于解压后大小
+		if probe != nil && probe.Size > 0 && image.CompressedSize == 0 {
+			image.CompressedSize = probe.Size
+			if image.Size == 0 && image.Compression == "none" {
+				image.Size = probe.Size
+			}
+		}
+		bootModes := image.BootModes
+		if bootModes == nil {
+			bootModes = []string{}
+		}
+		result = append(result, map[string]interface{}{
+			"filename":       path.Base(image.URL),
+			"displayName":    vhd.Name,
+			"url":            image.URL,
+			"system":         imageSystemName(image),
+			"osFamily":       image.OSFamily,
+			"edition":        image.Edition,
+			"version":        image.Version,
+			"build":          image.Build,
+			"arch":           image.Arch,
+			"language":       image.Language,
+			"bootMode":       strings.Join(bootModes, "/"),
+			"bootModes":      bootModes,
+			"size":           formatImageSize(image.Size),
+			"sizeBytes":      image.Size,
+			"compressedSize": image.CompressedSize,
+			"format":         image.Format,
+			"compression":    image.Compression,
+			"sha256":         image.SHA256,
+			"releaseDate":    image.ReleaseDate,
+			"minDiskSize":    image.MinDiskSize,
+			"issues":         image.CompatibilityIssues("", target.BootMode, target.DiskBytes),
+			"resumable":      probe != nil && probe.Resumable,
+			"segmented":      probe != nil && probe.Segmented,
+		})
+	}
+	return result, nil
+}
+
+// detectHostTarget 本机的启动模式和系统盘大小，只检测一次
+func (a *App) detectHostTarget() core.HostTarget {
+	a.hostTargetOnce.Do(func() {
+		a.hostTarget = a.detector.DetectHostTarget()
+		a.logger.Debug("检测安装目标", "boot_mode", a.hostTarget.BootMode, "disk_bytes", a.hostTarget.DiskBytes)
+	})
+	return a.hostTarget
+}
+
+// ProbeImage 探测镜像地址的大小和断点续传支持
+func (a *App) ProbeImage(url string) (*core.ImageProbe, error) {
+	return a.apiClient.ProbeImage(a.ctx, url)
+}
+
+// imageSystemName 前端显示和筛选用的系统名称，例如 "Windows 11 Pro"
+func imageSystemName(image core.ImageEntry) string {
+	family := image.OSFamily
+	switch family {
+	case "windows":
+		family = "Windows"
+	case "linux":
+		family = "Linux"
+	}
+	parts := []string{}
+	for _, part := range []string{family, image.Version, image.Edition} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatImageSize 格式化镜像大小，未知时显示"未知"
+func formatImageSize(size int64) string {
+	if size <= 0 {
+		return "未知"
+	}
+	return fmt.Sprintf("%.2f GB", float64(size)/(1024*1024*1024))
+}
+
+// serverIDFromFrontend 从前端传入的参数中取出服务器ID
+func serverIDFromFrontend(server interface{}) string {
+	switch v := server.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case map[string]interface{}:
+		if id, ok := v["id"]; ok && id != nil {
+			return fmt.Sprint(id)
+		}
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// translateAPIError 将API错误转换为前端显示的提示信息
+func translateAPIError(err error) error {
+	var authErr *core.AuthError
+	var apiErr *core.APIError
+	switch {
+	case errors.As(err, &authErr):
+		return authErr
+	case errors.As(err, &apiErr):
+		switch {
+		case apiErr.StatusCode >= 500:
+			return fmt.Errorf("服务器暂时不可用(HTTP %d)，请稍后重试", apiErr.StatusCode)
+		case apiErr.StatusCode == 404:
+			return fmt.Errorf("请求的资源不存在: %s", apiErr.Message)
+		default:
+			return fmt.Errorf("服务器返回错误: %s", apiErr.Message)
+		}
+	case errors.Is(err, core.ErrServerNotFound), errors.Is(err, core.ErrVHDNotFound):
+		return err
+	default:
+		return fmt.Errorf("无法连接服务器: %v", err)
 	}
 }
 
 // DownloadVHD 下载VHD文件
 func (a *App) DownloadVHD(vhdId interface{}, savePath string) map[string]interface{} {
-	fmt.Printf("开始下载VHD: %v 到 %s\n", vhdId, savePath)
+	a.logger.Info("开始下载VHD", "id", vhdId, "path", savePath)
 
 	return map[string]interface{}{
 		"success": true,
@@ -109,79 +345,147 @@ func (a *App) DownloadVHD(vhdId interface{}, savePath string) map[string]interfa
 	}
 }
 
-// GetSystemDrivers 获取系统驱动列表
+// GetSystemDrivers 获取已安装的驱动列表
 func (a *App) GetSystemDrivers() []interface{} {
-	return []interface{}{
-		map[string]interface{}{
-			"name":        "NVIDIA Graphics Driver",
-			"version":     "528.49",
-			"driverClass": "Display",
-			"date":        "2023-10-15",
-		},
-		map[string]interface{}{
-			"name":        "Realtek Audio Driver",
-			"version":     "6.0.9228.1",
-			"driverClass": "Media",
-			"date":        "2023-09-20",
-		},
-		map[string]interface{}{
-			"name":        "Intel Network Adapter",
-			"version":     "12.19.2.4",
-			"driverClass": "Net",
-			"date":        "2023-08-30",
-		},
+	drivers, err := a.driverManager.ListDrivers(a.ctx)
+	if err != nil {
+		a.logger.Error("获取驱动列表失败", "error", err)
+		return []interface{}{}
+	}
+
+	result := make([]interface{}, 0, len(drivers))
+	for _, driver := range drivers {
+		result = append(result, toFrontendMap(driver))
 	}
+	return result
 }
 
-// BackupDrivers 备份驱动
-func (a *App) BackupDrivers(targetPath string) map[string]interface{} {
-	fmt.Printf("备份驱动到: %s\n", targetPath)
+// BackupDrivers 将第三方驱动备份到配置的备份目录，format 为 zip、tar.zst 或 7z
+// firmware 为 true 时同时备份随内核发布的模块和发行版软件包提供的固件（Linux），进度通过 driver:backup:progress 事件发送
+func (a *App) BackupDrivers(format string, firmware bool) map[string]interface{} {
+	index := a.backupIndex()
+	a.logger.Info("备份驱动", "path", index.Root(), "format", format, "firmware", firmware)
 
-	return map[string]interface{}{
-		"success":     true,
-		"message":     "备份完成",
-		"driverCount": 15,
-		"path":        targetPath,
+	options := core.DriverBackupOptions{TargetDir: index.Root(), Format: format, Firmware: firmware}
+	result, err := a.driverManager.Backup(a.ctx, options, func(progress core.DriverBackupProgress) {
+		wailsruntime.EventsEmit(a.ctx, "driver:backup:progress", progress)
+	})
+	if err != nil {
+		a.logger.Error("备份驱动失败", "error", err)
+		return map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		}
+	}
+
+	if _, err := index.Add(a.ctx, result.Path); err != nil {
+		a.logger.Warning("更新备份索引失败", "error", err)
 	}
+
+	response := toFrontendMap(result)
+	response["success"] = true
+	response["message"] = fmt.Sprintf("备份完成，共 %d 个驱动", result.DriverCount)
+	if len(result.Failed) > 0 {
+		response["message"] = fmt.Sprintf("备份完成，共 %d 个驱动，%d 个导出失败: %s", result.DriverCount, len(result.Failed), strings.Join(result.Failed, ", "))
+	}
+	response["driverCount"] = result.DriverCount
+	response["failedCount"] = len(result.Failed)
+	return response
 }
 
-// RestoreDrivers 恢复驱动
+// RestoreDrivers 从备份归档恢复与本机硬件匹配的驱动，进度通过 driver:restore:progress 事件发送
 func (a *App) RestoreDrivers(backupPath string) map[string]interface{} {
-	fmt.Printf("从备份恢复驱动: %s\n", backupPath)
+	a.logger.Info("从备份恢复驱动", "path", backupPath)
 
-	return map[string]interface{}{
-		"success": true,
-		"message": "恢复完成",
-		"path":    backupPath,
+	result, err := a.driverManager.Restore(a.ctx, backupPath, func(progress core.DriverBackupProgress) {
+		wailsruntime.EventsEmit(a.ctx, "driver:restore:progress", progress)
+	})
+	if err != nil {
+		a.logger.Error("恢复驱动失败", "error", err)
+		return map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		}
 	}
+
+	response := toFrontendMap(result)
+	response["success"] = result.Failed == 0
+	response["message"] = fmt.Sprintf("已安装 %d 个驱动，跳过 %d 个，失败 %d 个", result.Installed, result.Skipped, result.Failed)
+	return response
 }
 
-// LoadBackupHistory 加载备份历史
+// backupIndex 配置的驱动备份目录的索引
+// 备份目录只由配置决定，前端不能指定其他目录来扫描、校验或删除文件
+func (a *App) backupIndex() *core.DriverBackupIndex {
+	root := core.DriverBackupDir(a.installer.Config())
+	a.backupMutex.Lock()
+	defer a.backupMutex.Unlock()
+	if a.backups == nil || a.backups.Root() != root {
+		a.backups = core.NewDriverBackupIndex(root)
+		a.backups.SetLogger(a.logger)
+	}
+	return a.backups
+}
+
+// GetDriverBackupDir 返回配置的驱动备份目录
+func (a *App) GetDriverBackupDir() string {
+	return a.backupIndex().Root()
+}
+
+// backupRecordMap 将备份记录转换为前端使用的格式
+func backupRecordMap(record core.DriverBackupRecord) map[string]interface{} {
+	result := toFrontendMap(record)
+	result["time"] = record.CreatedAt.UnixMilli()
+	result["isCompressed"] = record.Compressed
+	result["driverCount"] = record.DriverCount
+	return result
+}
+
+// LoadBackupHistory 重新扫描备份目录并返回索引中的备份，按创建时间倒序
 func (a *App) LoadBackupHistory() []interface{} {
-	return []interface{}{
-		map[string]interface{}{
-			"name":         "备份_20240101_120000",
-			"path":         "C:\\DriverBackup\\backup_20240101_120000.7z",
-			"time":         1704096000000, // 时间戳
-			"size":         "156 MB",
-			"isCompressed": true,
-			"driverCount":  15,
-		},
-		map[string]interface{}{
-			"name":         "备份_20231220_150000",
-			"path":         "C:\\DriverBackup\\backup_20231220_150000",
-			"time":         1703059200000,
-			"size":         "234 MB",
-			"isCompressed": false,
-			"driverCount":  12,
-		},
+	index := a.backupIndex()
+	records, err := index.Rescan(a.ctx)
+	if err != nil {
+		a.logger.Error("加载备份历史失败", "root", index.Root(), "error", err)
+		return []interface{}{}
+	}
+	result := make([]interface{}, 0, len(records))
+	for _, record := range records {
+		result = append(result, backupRecordMap(record))
+	}
+	return result
+}
+
+// VerifyBackup 校验备份归档的哈希和其中所有文件
+func (a *App) VerifyBackup(backupPath string) map[string]interface{} {
+	record, err := a.backupIndex().Verify(a.ctx, backupPath)
+	if err != nil {
+		return map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		}
+	}
+	response := backupRecordMap(*record)
+	response["success"] = record.Status == core.BackupStatusOK
+	response["message"] = "备份完整"
+	if record.Status != core.BackupStatusOK {
+		response["message"] = record.Error
 	}
+	return response
 }
 
-// DeleteBackup 删除备份
-func (a *App) DeleteBackup(record interface{}) map[string]interface{} {
-	fmt.Printf("删除备份: %v\n", record)
+// DeleteBackup 删除备份目录中的备份，目录之外的路径会被拒绝
+func (a *App) DeleteBackup(backupPath string) map[string]interface{} {
+	index := a.backupIndex()
+	a.logger.Info("删除备份", "root", index.Root(), "path", backupPath)
 
+	if err := index.Delete(backupPath); err != nil {
+		a.logger.Error("删除备份失败", "error", err)
+		return map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		}
+	}
 	return map[string]interface{}{
 		"success": true,
 		"message": "删除成功",
@@ -190,12 +494,551 @@ func (a *App) DeleteBackup(record interface{}) map[string]interface{} {
 
 // SelectFile 选择文件
 func (a *App) SelectFile(filters interface{}) string {
-	fmt.Printf("选择文件，过滤器: %v\n", filters)
+	a.logger.Debug("选择文件", "filters", filters)
 	return ""
 }
 
-// SelectDirectory 选择目录
-func (a *App) SelectDirectory() string {
-	fmt.Println("选择目录")
-	return ""
+// SelectDirectory 打开目录选择对话框，defaultDir 存在时作为初始目录，取消选择时返回空字符串
+func (a *App) SelectDirectory(defaultDir string) string {
+	a.logger.Debug("选择目录", "default", defaultDir)
+	options := wailsruntime.OpenDialogOptions{Title: "选择目录", CanCreateDirectories: true}
+	if info, err := os.Stat(defaultDir); err == nil && info.IsDir() {
+		options.DefaultDirectory = defaultDir
+	}
+	dir, err := wailsruntime.OpenDirectoryDialog(a.ctx, options)
+	if err != nil {
+		a.logger.Error("选择目录失败", "error", err)
+		return ""
+	}
+	return dir
+}
+
+// GetReinstallScriptInfo 获取当前使用的reinstall脚本版本
+func (a *App) GetReinstallScriptInfo() map[string]interface{} {
+	info := a.installer.GetReinstallScriptInfo()
+	if info == nil {
+		return map[string]interface{}{
+			"success": false,
+			"message": "没有可用的reinstall脚本",
+		}
+	}
+
+	return map[string]interface{}{
+		"success": true,
+		"version": info.Version,
+		"source":  info.Source,
+		"sha256":  info.SHA256,
+	}
+}
+
+// CheckScriptUpdate 检查reinstall脚本更新
+func (a *App) CheckScriptUpdate() map[string]interface{} {
+	info, err := a.installer.CheckScriptUpdate(a.ctx)
+	if err != nil {
+		return map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		}
+	}
+
+	return map[string]interface{}{
+		"success":        true,
+		"available":      info.Available,
+		"currentVersion": info.CurrentVersion,
+		"latestVersion":  info.LatestVersion,
+	}
+}
+
+// ApplyScriptUpdate 下载并启用最新的reinstall脚本
+func (a *App) ApplyScriptUpdate() map[string]interface{} {
+	info, err := a.installer.ApplyScriptUpdate(a.ctx)
+	if err != nil {
+		return map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		}
+	}
+
+	return map[string]interface{}{
+		"success": true,
+		"message": "脚本已更新",
+		"version": info.Version,
+		"source":  info.Source,
+	}
+}
+
+// RollbackReinstallScript 回滚到内置的reinstall脚本
+func (a *App) RollbackReinstallScript() map[string]interface{} {
+	info, err := a.installer.RollbackReinstallScript()
+	if err != nil {
+		return map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		}
+	}
+
+	return map[string]interface{}{
+		"success": true,
+		"message": "已回滚到内置脚本",
+		"version": info.Version,
+		"source":  info.Source,
+	}
+}
+
+// SetAPICredentials 将API密钥和签名密钥保存到密钥库，并立即生效
+func (a *App) SetAPICredentials(apiKey string, signingSecret string) map[string]interface{} {
+	creds := &core.Credentials{APIKey: apiKey, SigningSecret: signingSecret}
+	if err := core.SaveCredentials(a.installer.Secrets(), creds); err != nil {
+		return map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		}
+	}
+	a.apiClient.SetCredentials(creds)
+
+	return map[string]interface{}{
+		"success": true,
+		"message": "API密钥已保存",
+	}
+}
+
+// GetSecretStoreStatus 获取密钥库状态：是否已创建、是否已解锁
+func (a *App) GetSecretStoreStatus() map[string]interface{} {
+	store := a.installer.Secrets()
+	return map[string]interface{}{
+		"exists": store.Exists(),
+		"locked": store.Locked(),
+		"path":   store.Path(),
+	}
+}
+
+// UnlockSecretStore 用口令解锁密钥库，密钥库不存在时用该口令创建
+// 解锁后迁移旧版本的明文凭据并应用保存的API凭据
+func (a *App) UnlockSecretStore(passphrase string) map[string]interface{} {
+	store := a.installer.Secrets()
+	created := !store.Exists()
+	if err := store.Unlock(passphrase); err != nil {
+		a.logger.Warning("解锁密钥库失败", "error", err)
+		return map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		}
+	}
+
+	if migrated, err := core.MigrateLegacyCredentials(store, core.LegacyCredentialsPath()); err != nil {
+		a.logger.Warning("迁移明文凭据失败", "error", err)
+	} else if migrated {
+		a.logger.Info("已将明文凭据迁移到密钥库")
+	}
+	if err := a.apiClient.LoadCredentials(store); err != nil {
+		a.logger.Warning("加载API凭据失败", "error", err)
+	}
+	a.applyConfig(a.installer.Config()) // 代理密码保存在密钥库中
+
+	message := "密钥库已解锁"
+	if created {
+		message = "密钥库已创建"
+	}
+	return map[string]interface{}{
+		"success": true,
+		"message": message,
+		"created": created,
+	}
+}
+
+// LockSecretStore 锁定密钥库，已应用的API凭据在本次运行中继续有效
+func (a *App) LockSecretStore() map[string]interface{} {
+	a.installer.Secrets().Lock()
+	return map[string]interface{}{
+		"success": true,
+		"message": "密钥库已锁定",
+	}
+}
+
+// ChangeSecretStorePassphrase 修改密钥库口令，需要先解锁
+func (a *App) ChangeSecretStorePassphrase(oldPassphrase string, newPassphrase string) map[string]interface{} {
+	if err := a.installer.Secrets().ChangePassphrase(oldPassphrase, newPassphrase); err != nil {
+		return map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		}
+	}
+	return map[string]interface{}{
+		"success": true,
+		"message": "口令已修改",
+	}
+}
+
+// SetProxyPassword 将代理密码保存到密钥库并立即生效，代理地址中只需填写用户名
+func (a *App) SetProxyPassword(password string) map[string]interface{} {
+	if err := core.SaveProxyPassword(a.installer.Secrets(), password); err != nil {
+		return map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		}
+	}
+	a.applyConfig(a.installer.Config())
+
+	return map[string]interface{}{
+		"success": true,
+		"message": "代理密码已保存",
+	}
+}
+
+// GetInstallHistory 查询安装历史，参数为空表示不过滤
+func (a *App) GetInstallHistory(osType string, outcome string, limit int) []interface{} {
+	records, err := a.installer.GetInstallHistory(core.HistoryQuery{
+		OSType:  osType,
+		Outcome: outcome,
+		Limit:   limit,
+	})
+	if err != nil {
+		a.logger.Error("读取安装历史失败", "error", err)
+		return []interface{}{}
+	}
+
+	result := make([]interface{}, 0, len(records))
+	for _, record := range records {
+		result = append(result, toFrontendMap(record))
+	}
+	return result
+}
+
+// ExportInstallHistory 导出安装历史，format为json或csv
+func (a *App) ExportInstallHistory(savePath string, format string) map[string]interface{} {
+	if err := a.installer.ExportInstallHistory(savePath, format, core.HistoryQuery{}); err != nil {
+		return map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		}
+	}
+
+	return map[string]interface{}{
+		"success": true,
+		"message": "导出完成",
+		"path":    savePath,
+	}
+}
+
+// ListProfiles 获取所有安装方案
+func (a *App) ListProfiles() []interface{} {
+	profiles, err := a.installer.Profiles().List()
+	if err != nil {
+		a.logger.Error("读取安装方案失败", "error", err)
+		return []interface{}{}
+	}
+
+	result := make([]interface{}, 0, len(profiles))
+	for _, profile := range profiles {
+		result = append(result, toFrontendMap(profile))
+	}
+	return result
+}
+
+// GetProfile 获取安装方案
+func (a *App) GetProfile(name string) map[string]interface{} {
+	profile, err := a.installer.Profiles().Get(name)
+	if err != nil {
+		return map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		}
+	}
+
+	response := toFrontendMap(profile)
+	response["success"] = true
+	return response
+}
+
+// CreateProfile 新建安装方案，密码和SSH密钥保存在密钥库中，需要先解锁
+func (a *App) CreateProfile(profile interface{}) map[string]interface{} {
+	var input core.InstallProfile
+	if err := fromFrontend(profile, &input); err != nil {
+		return map[string]interface{}{
+			"success": false,
+			"message": fmt.Sprintf("安装方案格式错误: %v", err),
+		}
+	}
+	created, err := a.installer.Profiles().Create(input)
+	if err != nil {
+		return map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		}
+	}
+
+	a.logger.Info("已创建安装方案", "profile", created.Name)
+	response := toFrontendMap(created)
+	response["success"] = true
+	response["message"] = "安装方案已保存"
+	return response
+}
+
+// UpdateProfile 修改安装方案，profile 中的名称与 name 不同时为重命名
+func (a *App) UpdateProfile(name string, profile interface{}) map[string]interface{} {
+	var input core.InstallProfile
+	if err := fromFrontend(profile, &input); err != nil {
+		return map[string]interface{}{
+			"success": false,
+			"message": fmt.Sprintf("安装方案格式错误: %v", err),
+		}
+	}
+	updated, err := a.installer.Profiles().Update(name, input)
+	if err != nil {
+		return map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		}
+	}
+
+	a.logger.Info("已修改安装方案", "profile", updated.Name)
+	response := toFrontendMap(updated)
+	response["success"] = true
+	response["message"] = "安装方案已保存"
+	return response
+}
+
+// DeleteProfile 删除安装方案
+func (a *App) DeleteProfile(name string) map[string]interface{} {
+	if err := a.installer.Profiles().Delete(name); err != nil {
+		return map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		}
+	}
+
+	a.logger.Info("已删除安装方案", "profile", name)
+	return map[string]interface{}{
+		"success": true,
+		"message": "安装方案已删除",
+	}
+}
+
+// ExportProfiles 导出安装方案，names为空时导出全部；format为json或yaml，为空时按扩展名判断
+func (a *App) ExportProfiles(savePath string, names []string, format string) map[string]interface{} {
+	if err := a.installer.Profiles().ExportFile(savePath, names, format); err != nil {
+		return map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		}
+	}
+
+	return map[string]interface{}{
+		"success": true,
+		"message": "导出完成",
+		"path":    savePath,
+	}
+}
+
+// ImportProfiles 从JSON或YAML文件导入安装方案，overwrite为false时跳过同名方案
+func (a *App) ImportProfiles(path string, format string, overwrite bool) map[string]interface{} {
+	result, err := a.installer.Profiles().ImportFile(path, format, overwrite)
+	if err != nil {
+		return map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		}
+	}
+
+	response := toFrontendMap(result)
+	response["success"] = true
+	response["message"] = fmt.Sprintf("已导入%d个安装方案", len(result.Imported))
+	if len(result.Skipped) > 0 {
+		response["message"] = fmt.Sprintf("已导入%d个安装方案，跳过%d个同名方案", len(result.Imported), len(result.Skipped))
+	}
+	return response
+}
+
+// ApplyProfile 以安装方案为基础应用本次安装的覆盖项，返回合并后的安装选项（不含密码）
+// secrets 列出方案保存在密钥库中的字段
+func (a *App) ApplyProfile(name string, overrides map[string]interface{}) map[string]interface{} {
+	options, err := a.installer.ProfileOptions(name, overrides)
+	if err != nil {
+		return map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		}
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"options": toFrontendMap(options.WithoutSecrets()),
+	}
+	if profile, err := a.installer.Profiles().Get(name); err == nil && len(profile.Secrets) > 0 {
+		response["secrets"] = profile.Secrets // 安装时从密钥库读取的字段
+	}
+	if err := a.installer.ValidateInstallOptions(options); err != nil {
+		response["message"] = err.Error()
+	}
+	return response
+}
+
+// ExportLogs 将日志、安装记录、系统检测结果和脱敏后的配置打包为zip
+func (a *App) ExportLogs(savePath string) map[string]interface{} {
+	result, err := a.installer.ExportLogs(savePath)
+	if err != nil {
+		return map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		}
+	}
+
+	response := toFrontendMap(result)
+	response["success"] = true
+	response["message"] = "日志导出完成"
+	return response
+}
+
+// UploadDiagnostics 上传脱敏后的诊断信息，需要用户在配置中同意
+func (a *App) UploadDiagnostics() map[string]interface{} {
+	result, err := a.installer.UploadDiagnostics(a.ctx)
+	if err != nil {
+		return map[string]interface{}{
+			"success": false,
+			"consent": !errors.Is(err, core.ErrDiagnosticsConsent),
+			"message": err.Error(),
+		}
+	}
+
+	response := toFrontendMap(result)
+	response["success"] = true
+	response["consent"] = true
+	response["message"] = fmt.Sprintf("诊断信息已上传，工单号：%s", result.TicketID)
+	return response
+}
+
+// CancelDiagnosticsUpload 放弃未完成的诊断上传
+func (a *App) CancelDiagnosticsUpload() map[string]interface{} {
+	if err := a.installer.CancelDiagnosticsUpload(); err != nil {
+		return map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		}
+	}
+	return map[string]interface{}{
+		"success": true,
+		"message": "已取消诊断上传",
+	}
+}
+
+// GetRecentLogs 获取内存中最近的日志，limit<=0时返回全部
+func (a *App) GetRecentLogs(limit int) []interface{} {
+	entries := a.recentLogs.Entries()
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+
+	result := make([]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		item := map[string]interface{}{
+			"time":    entry.Time.Format(time.RFC3339),
+			"level":   strings.ToLower(entry.Level.String()),
+			"message": entry.Message,
+		}
+		fields := map[string]interface{}{}
+		for _, field := range entry.Fields {
+			fields[field.Key] = field.Value
+		}
+		item["fields"] = fields
+		result = append(result, item)
+	}
+	return result
+}
+
+// SetLogLevel 设置日志级别：debug, info, warning, error
+func (a *App) SetLogLevel(level string) map[string]interface{} {
+	parsed, err := utils.ParseLevel(level)
+	if err != nil {
+		return map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		}
+	}
+	a.logger.SetLevel(parsed)
+	return map[string]interface{}{
+		"success": true,
+		"message": "日志级别已设置为" + parsed.String(),
+	}
+}
+
+// GetConfig 获取生效的配置，sources 为每个配置项的来源（default/file/env/cli）
+func (a *App) GetConfig() map[string]interface{} {
+	result := toFrontendMap(a.installer.Config())
+	result["path"] = a.installer.ConfigPath()
+	result["sources"] = a.installer.ConfigSources()
+	if overrideErrs := a.installer.ConfigOverrideErrors(); len(overrideErrs) > 0 {
+		messages := make([]string, len(overrideErrs))
+		for i, err := range overrideErrs {
+			messages[i] = err.Error()
+		}
+		result["overrideErrors"] = messages
+	}
+	// 配置文件无法解析时界面显示的是默认配置，同时给出原因
+	if err := a.installer.ConfigLoadError(); err != nil {
+		result["loadError"] = err.Error()
+		var configErrs core.ConfigErrors
+		if errors.As(err, &configErrs) {
+			result["errors"] = configErrs
+		}
+	}
+	return result
+}
+
+// UpdateConfig 修改配置文件，只需传入要修改的项，校验通过后保存并立即生效
+// 被环境变量或命令行覆盖的配置项仍以覆盖值为准
+func (a *App) UpdateConfig(changes interface{}) map[string]interface{} {
+	config := a.installer.FileConfig()
+	data, err := json.Marshal(changes)
+	if err == nil {
+		err = json.Unmarshal(data, config)
+	}
+	if err != nil {
+		return map[string]interface{}{
+			"success": false,
+			"message": fmt.Sprintf("配置格式错误: %v", err),
+		}
+	}
+
+	if err := a.installer.UpdateConfig(config); err != nil {
+		result := map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		}
+		var configErrs core.ConfigErrors
+		if errors.As(err, &configErrs) {
+			result["errors"] = configErrs
+		}
+		return result
+	}
+
+	a.applyConfig(a.installer.Config())
+	if catalogs, err := a.installer.BuildCatalogSet(a.apiClient, a.catalogOptions); err != nil {
+		a.logger.Warning("重新加载目录来源失败", "error", err)
+	} else {
+		a.setCatalogs(catalogs)
+	}
+	return map[string]interface{}{
+		"success": true,
+		"message": "配置已保存",
+	}
+}
+
+// fromFrontend 将前端传入的对象按json标签转换为结构体
+func fromFrontend(v interface{}, out interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// toFrontendMap 将结构体按json标签转换为前端使用的map
+func toFrontendMap(v interface{}) map[string]interface{} {
+	result := map[string]interface{}{}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return result
+	}
+	json.Unmarshal(data, &result)
+	return result
 }
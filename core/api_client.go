@@ -1,35 +1,138 @@
 package core
 
 import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	mathrand "math/rand"
 	"net/http"
+	neturl "net/url"
+	"os"
+	"path"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"SystemReinstaller/utils"
 )
 
+// DefaultAPIBaseURL 默认的API地址
+const DefaultAPIBaseURL = "https://autoinstaller.qkdny.com/api/autoinstaller"
+
+// maxAPIResponseSize API响应体大小上限
+const maxAPIResponseSize = 8 << 20
+
+// maxRetryAfter 服务器要求的重试等待时间上限
+const maxRetryAfter = 2 * time.Minute
+
 // APIClient API客户端
 type APIClient struct {
-	baseURL    string
-	apiKey     string
-	clientID   string
-	httpClient *http.Client
+	baseURL       string
+	apiKey        string
+	signingSecret string
+	authMutex     sync.RWMutex
+	clientID      string
+	httpClient    *http.Client
+	clientMutex   sync.RWMutex // 保护 httpClient、baseURL 和 cache，重新加载配置时可能与请求同时发生
+	retry         RetryPolicy
+	cache         *CatalogCache
+	prober        *ImageProber
+	concurrency   int
+	logger        *utils.Logger
+}
+
+// RetryPolicy 幂等请求的重试策略（指数退避加随机抖动）
+type RetryPolicy struct {
+	MaxAttempts int           // 最大尝试次数（含第一次）
+	BaseDelay   time.Duration // 第一次重试前的等待时间
+	MaxDelay    time.Duration // 单次等待上限
+}
+
+// DefaultRetryPolicy 默认重试策略
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 4,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    10 * time.Second,
+	}
+}
+
+// backoff 第attempt次失败后的等待时间：在[d/2, d)之间随机
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay << uint(attempt-1)
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+	return half + time.Duration(mathrand.Int63n(int64(half)))
+}
+
+// APIError API返回的错误
+type APIError struct {
+	StatusCode int    `json:"status_code"` // HTTP状态码
+	Code       string `json:"code"`        // 服务器错误码
+	Message    string `json:"message"`     // 错误信息
+}
+
+// Error 实现error接口
+func (e *APIError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("API错误(HTTP %d, %s): %s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("API错误(HTTP %d): %s", e.StatusCode, e.Message)
 }
 
+// AuthError 认证失败(401)或无权限(403)
+type AuthError struct {
+	*APIError
+}
+
+// Error 实现error接口
+func (e *AuthError) Error() string {
+	if e.StatusCode == http.StatusForbidden {
+		return fmt.Sprintf("无权访问: %s", e.Message)
+	}
+	return fmt.Sprintf("认证失败，请检查API密钥: %s", e.Message)
+}
+
+// Unwrap 返回底层的APIError
+func (e *AuthError) Unwrap() error {
+	return e.APIError
+}
+
+// ErrServerNotFound 指定的服务器不存在
+var ErrServerNotFound = errors.New("未找到服务器")
+
+// ErrVHDNotFound 指定的VHD不存在
+var ErrVHDNotFound = errors.New("未找到VHD")
+
 // ServerInfo 服务器信息
 type ServerInfo struct {
-	ID           string                 `json:"id"`
-	Name         string                 `json:"name"`
-	Location     string                 `json:"location"`
-	DownloadURLs map[string]interface{} `json:"download_urls"`
+	ID       string                `json:"id"`
+	Name     string                `json:"name"`
+	Location string                `json:"location"`
+	Images   map[string]ImageEntry `json:"download_urls"`    // 镜像名称 -> 镜像条目
+	Source   string                `json:"source,omitempty"` // 目录来源名称
+	Stale    bool                  `json:"stale,omitempty"`  // API不可用时来自过期的本地缓存
 }
 
-// ServerListResponse 服务器列表响应
-type ServerListResponse struct {
-	Success bool         `json:"success"`
-	Data    ServerData   `json:"data"`
-	Error   string       `json:"error,omitempty"`
+// Key 带来源前缀的服务器标识，多个来源的服务器ID可能重复
+func (s ServerInfo) Key() string {
+	if s.Source == "" {
+		return s.ID
+	}
+	return s.Source + "/" + s.ID
 }
 
 // ServerData 服务器数据
@@ -37,219 +140,516 @@ type ServerData struct {
 	Servers []ServerInfo `json:"servers"`
 }
 
-// VHDListResponse VHD列表响应
-type VHDListResponse struct {
-	Success bool                   `json:"success"`
-	Data    map[string]interface{} `json:"data"`
-	Error   string                 `json:"error,omitempty"`
+// VHDImage 服务器上的VHD镜像
+type VHDImage struct {
+	Name  string     `json:"name"`
+	Image ImageEntry `json:"image"`
+}
+
+// VHDList 某个服务器的VHD列表
+type VHDList struct {
+	Server ServerInfo `json:"server"`
+	VHDs   []VHDImage `json:"vhds"`
+}
+
+// ScriptRelease reinstall脚本发布信息
+type ScriptRelease struct {
+	Manifest  string `json:"manifest"`  // base64编码的清单JSON原文
+	Signature string `json:"signature"` // 清单原文的Ed25519签名(base64)
+	BaseURL   string `json:"base_url"`  // 脚本文件下载地址前缀
+}
+
+// apiResponse API通用响应格式
+type apiResponse struct {
+	Success bool            `json:"success"`
+	Data    json.RawMessage `json:"data"`
+	Error   string          `json:"error,omitempty"`
+	Code    string          `json:"code,omitempty"`
 }
 
 // NewAPIClient 创建API客户端
 func NewAPIClient() *APIClient {
+	httpClient := DefaultHTTPTransport().Client(30 * time.Second)
+	return &APIClient{
+		baseURL:     DefaultAPIBaseURL,
+		clientID:    "go-client",
+		httpClient:  httpClient,
+		retry:       DefaultRetryPolicy(),
+		prober:      NewImageProber(httpClient),
+		concurrency: 4,
+		logger:      utils.NewNopLogger(),
+	}
+}
+
+// SetLogger 设置日志记录器
+func (ac *APIClient) SetLogger(logger *utils.Logger) {
+	ac.logger = logger.With("component", "api")
+}
+
+// normalizeBaseURL 校验API地址并去掉末尾的斜杠
+func normalizeBaseURL(baseURL string) (string, error) {
+	parsed, err := neturl.Parse(strings.TrimSpace(baseURL))
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", fmt.Errorf("API地址无效: %q", baseURL)
+	}
+	return strings.TrimSuffix(parsed.String(), "/"), nil
+}
+
+// SetBaseURL 设置API地址，重新加载配置时可以与进行中的请求同时调用
+func (ac *APIClient) SetBaseURL(baseURL string) error {
+	normalized, err := normalizeBaseURL(baseURL)
+	if err != nil {
+		return err
+	}
+	ac.clientMutex.Lock()
+	defer ac.clientMutex.Unlock()
+	ac.baseURL = normalized
+	return nil
+}
+
+// BaseURL 当前API地址
+func (ac *APIClient) BaseURL() string {
+	ac.clientMutex.RLock()
+	defer ac.clientMutex.RUnlock()
+	return ac.baseURL
+}
+
+// WithBaseURL 创建指向另一API地址的客户端，共享连接和重试策略
+// 认证信息属于当前API，不会复制到新客户端
+func (ac *APIClient) WithBaseURL(baseURL string) (*APIClient, error) {
+	normalized, err := normalizeBaseURL(baseURL)
+	if err != nil {
+		return nil, err
+	}
 	return &APIClient{
-		baseURL:  "https://autoinstaller.qkdny.com/api/autoinstaller",
-		clientID: "go-client",
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		baseURL:     normalized,
+		clientID:    ac.clientID,
+		httpClient:  ac.client(),
+		retry:       ac.retry,
+		cache:       ac.catalogCache(),
+		prober:      ac.prober,
+		concurrency: ac.concurrency,
+		logger:      ac.logger,
+	}, nil
+}
+
+// SetCatalogCache 设置目录缓存，为nil时每次都请求API
+func (ac *APIClient) SetCatalogCache(cache *CatalogCache) {
+	ac.clientMutex.Lock()
+	defer ac.clientMutex.Unlock()
+	ac.cache = cache
+}
+
+// catalogCache 当前使用的目录缓存
+func (ac *APIClient) catalogCache() *CatalogCache {
+	ac.clientMutex.RLock()
+	defer ac.clientMutex.RUnlock()
+	return ac.cache
+}
+
+// SetTimeout 设置单次请求的超时，<=0时不修改
+// 替换为新的 http.Client，不影响进行中的请求以及共用原客户端的探测器和 WithBaseURL 副本
+func (ac *APIClient) SetTimeout(timeout time.Duration) {
+	if timeout <= 0 {
+		return
+	}
+	ac.clientMutex.Lock()
+	defer ac.clientMutex.Unlock()
+	client := *ac.httpClient
+	client.Timeout = timeout
+	ac.httpClient = &client
+}
+
+// client 当前使用的 http.Client
+func (ac *APIClient) client() *http.Client {
+	ac.clientMutex.RLock()
+	defer ac.clientMutex.RUnlock()
+	return ac.httpClient
+}
+
+// SetConcurrency 设置批量探测镜像时的并发数
+func (ac *APIClient) SetConcurrency(n int) {
+	if n < 1 {
+		n = 1
+	}
+	ac.concurrency = n
+}
+
+// SetRetryPolicy 设置重试策略
+func (ac *APIClient) SetRetryPolicy(policy RetryPolicy) {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
 	}
+	ac.retry = policy
 }
 
 // SetAPIKey 设置API密钥
 func (ac *APIClient) SetAPIKey(apiKey string) {
+	ac.authMutex.Lock()
+	defer ac.authMutex.Unlock()
 	ac.apiKey = apiKey
 }
 
-// GetServerList 获取服务器列表
-func (ac *APIClient) GetServerList() map[string]interface{} {
-	url := fmt.Sprintf("%s/iso-download/", ac.baseURL)
-	
-	resp, err := ac.httpClient.Get(url)
+// SetSigningSecret 设置请求签名密钥，为空时不签名
+func (ac *APIClient) SetSigningSecret(secret string) {
+	ac.authMutex.Lock()
+	defer ac.authMutex.Unlock()
+	ac.signingSecret = secret
+}
+
+// SetCredentials 应用保存的认证信息
+func (ac *APIClient) SetCredentials(creds *Credentials) {
+	ac.authMutex.Lock()
+	defer ac.authMutex.Unlock()
+	ac.apiKey = creds.APIKey
+	ac.signingSecret = creds.SigningSecret
+}
+
+// LoadCredentials 从密钥库读取并应用API凭据
+func (ac *APIClient) LoadCredentials(store SecretStore) error {
+	creds, err := LoadCredentials(store)
 	if err != nil {
-		return map[string]interface{}{
-			"success": false,
-			"error":   err.Error(),
-		}
+		return err
 	}
-	defer resp.Body.Close()
-	
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return map[string]interface{}{
-			"success": false,
-			"error":   err.Error(),
-		}
+	ac.SetCredentials(creds)
+	return nil
+}
+
+// GetServerList 获取服务器列表
+func (ac *APIClient) GetServerList(ctx context.Context) ([]ServerInfo, error) {
+	url := ac.BaseURL() + "/iso-download/"
+	if cache := ac.catalogCache(); cache != nil {
+		return ac.getCachedServerList(ctx, cache, url)
 	}
-	
-	var response ServerListResponse
-	err = json.Unmarshal(body, &response)
-	if err != nil {
-		return map[string]interface{}{
-			"success": false,
-			"error":   err.Error(),
-		}
+
+	var data ServerData
+	if err := ac.doJSON(ctx, http.MethodGet, url, nil, &data); err != nil {
+		return nil, err
 	}
-	
-	if !response.Success {
-		return map[string]interface{}{
-			"success": false,
-			"error":   response.Error,
-		}
+	return data.Servers, nil
+}
+
+// GetVHDList 获取VHD列表，serverID为空时使用第一个服务器
+func (ac *APIClient) GetVHDList(ctx context.Context, serverID string) (*VHDList, error) {
+	servers, err := ac.GetServerList(ctx)
+	if err != nil {
+		return nil, err
 	}
-	
-	// 转换为标准格式
-	formattedServers := make([]map[string]interface{}, 0)
-	for _, server := range response.Data.Servers {
-		formattedServers = append(formattedServers, map[string]interface{}{
-			"id":            server.ID,
-			"name":          server.Name,
-			"location":      server.Location,
-			"download_urls": server.DownloadURLs,
-		})
-	}
-	
-	return map[string]interface{}{
-		"success": true,
-		"data": map[string]interface{}{
-			"servers": formattedServers,
-		},
-	}
-}
-
-// GetVHDList 获取VHD列表
-func (ac *APIClient) GetVHDList(serverID string) map[string]interface{} {
-	// 首先获取服务器列表
-	serverResult := ac.GetServerList()
-	if !serverResult["success"].(bool) {
-		return serverResult
-	}
-	
-	data := serverResult["data"].(map[string]interface{})
-	servers := data["servers"].([]map[string]interface{})
-	
-	if len(servers) == 0 {
-		return map[string]interface{}{
-			"success": false,
-			"error":   "没有可用的服务器",
-		}
+	selected, err := findServer(servers, serverID)
+	if err != nil {
+		return nil, err
 	}
-	
-	// 查找指定服务器
-	var selectedServer map[string]interface{}
-	if serverID != "" {
-		for _, server := range servers {
-			if server["id"] == serverID {
-				selectedServer = server
-				break
-			}
-		}
-		
-		if selectedServer == nil {
-			return map[string]interface{}{
-				"success": false,
-				"error":   fmt.Sprintf("未找到服务器: %s", serverID),
-			}
-		}
-	} else {
-		// 如果没有指定服务器ID，使用第一个服务器
-		selectedServer = servers[0]
-	}
-	
-	// 返回VHD列表
-	downloadURLs := selectedServer["download_urls"].(map[string]interface{})
-	vhdList := make([]map[string]interface{}, 0)
-	
-	for name, url := range downloadURLs {
-		vhdList = append(vhdList, map[string]interface{}{
-			"name": name,
-			"url":  url,
-			"size": "未知", // 实际应用中可以获取文件大小
-		})
-	}
-	
-	return map[string]interface{}{
-		"success": true,
-		"data": map[string]interface{}{
-			"server": selectedServer,
-			"vhds":   vhdList,
-		},
-	}
-}
-
-// DownloadVHD 下载VHD文件
-func (ac *APIClient) DownloadVHD(serverID, vhdName, savePath string) bool {
-	// 获取VHD列表
-	vhdResult := ac.GetVHDList(serverID)
-	if !vhdResult["success"].(bool) {
-		return false
+	return newVHDList(*selected), nil
+}
+
+// DownloadVHD 下载VHD文件到savePath
+func (ac *APIClient) DownloadVHD(ctx context.Context, serverID, vhdName, savePath string) error {
+	list, err := ac.GetVHDList(ctx, serverID)
+	if err != nil {
+		return err
 	}
-	
-	data := vhdResult["data"].(map[string]interface{})
-	vhds := data["vhds"].([]map[string]interface{})
-	
+
 	// 查找指定VHD
 	var downloadURL string
-	for _, vhd := range vhds {
-		if vhd["name"] == vhdName {
-			downloadURL = vhd["url"].(string)
+	for _, vhd := range list.VHDs {
+		if vhd.Name == vhdName {
+			downloadURL = vhd.Image.URL
 			break
 		}
 	}
-	
 	if downloadURL == "" {
-		return false
+		return fmt.Errorf("%w: %s", ErrVHDNotFound, vhdName)
 	}
-	
-	// 下载文件（这里是简化版本，实际应用中需要进度回调等）
-	resp, err := ac.httpClient.Get(downloadURL)
+
+	// 下载文件（这里是简化版本，进度和断点续传由VHDManager负责）
+	resp, err := ac.send(ctx, http.MethodGet, downloadURL, nil, nil)
 	if err != nil {
-		return false
+		return err
 	}
 	defer resp.Body.Close()
-	
-	// 这里应该实现实际的文件下载逻辑
-	// 包括进度回调、断点续传等功能
-	
-	return true
-}
-
-// UploadLog 上传日志
-func (ac *APIClient) UploadLog(logData map[string]interface{}) map[string]interface{} {
-	url := fmt.Sprintf("%s/upload-log/", ac.baseURL)
-	
-	jsonData, err := json.Marshal(logData)
+	if err := checkHTTPStatus(resp); err != nil {
+		return err
+	}
+
+	file, err := os.Create(savePath)
 	if err != nil {
-		return map[string]interface{}{
-			"success": false,
-			"error":   err.Error(),
-		}
+		return err
+	}
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		file.Close()
+		os.Remove(savePath)
+		return err
+	}
+	return file.Close()
+}
+
+// ProbeImage 探测镜像地址的大小、Range支持和校验标识，结果会被缓存
+func (ac *APIClient) ProbeImage(ctx context.Context, url string) (*ImageProbe, error) {
+	return ac.prober.Probe(ctx, url)
+}
+
+// ProbeImages 并发探测多个镜像地址，失败的地址不出现在结果中
+func (ac *APIClient) ProbeImages(ctx context.Context, urls []string) map[string]*ImageProbe {
+	return ac.prober.ProbeAll(ctx, urls, ac.concurrency)
+}
+
+// GetScriptRelease 获取最新的reinstall脚本发布信息，endpoint为空时使用默认地址
+func (ac *APIClient) GetScriptRelease(ctx context.Context, endpoint string) (*ScriptRelease, error) {
+	if endpoint == "" {
+		endpoint = ac.BaseURL() + "/reinstall-script/latest/"
+	}
+
+	var release ScriptRelease
+	if err := ac.doJSON(ctx, http.MethodGet, endpoint, nil, &release); err != nil {
+		return nil, err
 	}
-	
-	resp, err := ac.httpClient.Post(url, "application/json", strings.NewReader(string(jsonData)))
+	return &release, nil
+}
+
+// DownloadScriptFile 下载脚本文件，超过maxSize字节视为错误
+func (ac *APIClient) DownloadScriptFile(ctx context.Context, url string, maxSize int64) ([]byte, error) {
+	resp, err := ac.send(ctx, http.MethodGet, url, nil, nil)
 	if err != nil {
-		return map[string]interface{}{
-			"success": false,
-			"error":   err.Error(),
-		}
+		return nil, err
 	}
 	defer resp.Body.Close()
-	
-	body, err := io.ReadAll(resp.Body)
+	if err := checkHTTPStatus(resp); err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
 	if err != nil {
-		return map[string]interface{}{
-			"success": false,
-			"error":   err.Error(),
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("脚本文件过大: %s", path.Base(url))
+	}
+	return data, nil
+}
+
+// doJSON 发送请求并解析通用响应格式，data解析到out
+func (ac *APIClient) doJSON(ctx context.Context, method, url string, body interface{}, out interface{}) error {
+	var payload []byte
+	headers := map[string]string{"Accept": "application/json"}
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
 		}
+		headers["Content-Type"] = "application/json"
+	}
+	return ac.doRequest(ctx, method, url, payload, headers, out)
+}
+
+// doRequest 发送原始请求体并解析通用响应格式，data解析到out
+func (ac *APIClient) doRequest(ctx context.Context, method, url string, payload []byte, headers map[string]string, out interface{}) error {
+	resp, err := ac.send(ctx, method, url, payload, headers)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkHTTPStatus(resp); err != nil {
+		return err
 	}
-	
-	var result map[string]interface{}
-	err = json.Unmarshal(body, &result)
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxAPIResponseSize))
 	if err != nil {
-		return map[string]interface{}{
-			"success": false,
-			"error":   err.Error(),
+		return err
+	}
+	return decodeAPIResponse(resp.StatusCode, data, out)
+}
+
+// decodeAPIResponse 解析通用响应格式，data解析到out
+func decodeAPIResponse(statusCode int, data []byte, out interface{}) error {
+	var response apiResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return &APIError{StatusCode: statusCode, Message: fmt.Sprintf("响应格式错误: %v", err)}
+	}
+	if !response.Success {
+		return &APIError{StatusCode: statusCode, Code: response.Code, Message: response.Error}
+	}
+
+	if out == nil || len(response.Data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(response.Data, out); err != nil {
+		return &APIError{StatusCode: statusCode, Message: fmt.Sprintf("响应数据格式错误: %v", err)}
+	}
+	return nil
+}
+
+// send 发送请求，幂等请求在网络错误和5xx/429时按策略重试
+// 返回的响应可能是非2xx，由调用方检查状态码
+func (ac *APIClient) send(ctx context.Context, method, url string, body []byte, headers map[string]string) (*http.Response, error) {
+	attempts := 1
+	if isIdempotentMethod(method) {
+		attempts = ac.retry.MaxAttempts
+	}
+
+	// 同一请求的重试共用请求ID，便于服务端去重和排查
+	requestID := newRequestID()
+
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		ac.authorize(req, requestID, body)
+
+		resp, err := ac.client().Do(req)
+		var wait time.Duration
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+		case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+			wait = parseRetryAfter(resp.Header.Get("Retry-After"))
+		default:
+			return resp, nil
+		}
+
+		if attempt >= attempts {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+
+		if wait <= 0 {
+			wait = ac.retry.backoff(attempt)
+		}
+		var reason string
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = resp.Status
+		}
+		ac.logger.Warning("请求失败，准备重试", "method", method, "url", url, "attempt", attempt, "wait", wait.String(), "reason", reason)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
 		}
 	}
-	
-	return result
-}
\ No newline at end of file
+}
+
+// authorize 添加认证请求头，配置了签名密钥时对请求签名
+// 认证信息只发送给API所在主机，不会泄露给镜像下载地址
+//
+// 签名内容为 METHOD\nURI\nTIMESTAMP\nREQUEST_ID\nSHA256(BODY)，
+// 服务端据时间戳和请求ID拒绝过期或重放的请求
+func (ac *APIClient) authorize(req *http.Request, requestID string, body []byte) {
+	ac.authMutex.RLock()
+	apiKey, secret := ac.apiKey, ac.signingSecret
+	ac.authMutex.RUnlock()
+
+	req.Header.Set("User-Agent", "SystemReinstaller/"+AppVersion)
+	// 只对API地址本身签名，同一主机的http地址或其他端口不会拿到API密钥和签名
+	if base, err := neturl.Parse(ac.BaseURL()); err != nil || !sameOrigin(base, req.URL) {
+		return
+	}
+	req.Header.Set("X-Client-ID", ac.clientID)
+	req.Header.Set("X-App-Version", AppVersion)
+	req.Header.Set("X-Request-ID", requestID)
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+	if secret == "" {
+		return
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	bodyHash := sha256.Sum256(body)
+	canonical := req.Method + "\n" + req.URL.RequestURI() + "\n" + timestamp + "\n" + requestID + "\n" + hex.EncodeToString(bodyHash[:])
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(canonical))
+
+	req.Header.Set("X-Timestamp", timestamp)
+	req.Header.Set("X-Signature", hex.EncodeToString(mac.Sum(nil)))
+}
+
+// sameOrigin 判断两个地址的协议、主机和端口是否相同，省略的端口按协议默认端口比较
+func sameOrigin(a, b *neturl.URL) bool {
+	if !strings.EqualFold(a.Scheme, b.Scheme) || !strings.EqualFold(a.Hostname(), b.Hostname()) {
+		return false
+	}
+	return originPort(a) == originPort(b)
+}
+
+// originPort 地址的端口，省略时返回协议的默认端口
+func originPort(u *neturl.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http":
+		return "80"
+	case "https":
+		return "443"
+	}
+	return ""
+}
+
+// newRequestID 生成随机请求ID
+func newRequestID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// isIdempotentMethod 是否为可以安全重试的请求方法
+func isIdempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter 解析Retry-After（秒数或HTTP日期），无法解析时返回0
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	var wait time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		wait = time.Duration(seconds) * time.Second
+	} else if at, err := http.ParseTime(value); err == nil {
+		wait = time.Until(at)
+	}
+	if wait < 0 {
+		return 0
+	}
+	if wait > maxRetryAfter {
+		return maxRetryAfter
+	}
+	return wait
+}
+
+// checkHTTPStatus 非2xx响应转换为APIError，尽量从响应体中取出错误码和错误信息
+func checkHTTPStatus(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return nil
+	}
+
+	apiErr := &APIError{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var response apiResponse
+	if json.Unmarshal(data, &response) == nil {
+		apiErr.Code = response.Code
+		if response.Error != "" {
+			apiErr.Message = response.Error
+		}
+	}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return &AuthError{APIError: apiErr}
+	}
+	return apiErr
+}
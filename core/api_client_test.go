@@ -0,0 +1,312 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAuthorizeOnlySignsAPIOrigin(t *testing.T) {
+	ac := NewAPIClient()
+	ac.baseURL = "https://api.example.com/v1"
+	ac.SetCredentials(&Credentials{APIKey: "key", SigningSecret: "secret"})
+
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://api.example.com/v1/images", true},
+		{"https://API.example.com:443/v1/images", true},
+		{"http://api.example.com/v1/images", false},
+		{"https://api.example.com:8443/v1/images", false},
+		{"https://cdn.example.com/v1/images", false},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ac.authorize(req, "request-id", nil)
+		gotKey := req.Header.Get("X-API-Key") != ""
+		gotSignature := req.Header.Get("X-Signature") != ""
+		if gotKey != tt.want || gotSignature != tt.want {
+			t.Errorf("%s: X-API-Key=%v X-Signature=%v, want %v", tt.url, gotKey, gotSignature, tt.want)
+		}
+	}
+}
+
+func TestSetTimeoutReplacesClient(t *testing.T) {
+	ac := NewAPIClient()
+	shared := ac.client()
+	original := shared.Timeout
+	clone, err := ac.WithBaseURL("https://other.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ac.SetTimeout(5 * time.Second)
+	if ac.client().Timeout != 5*time.Second {
+		t.Fatalf("Timeout = %v, want 5s", ac.client().Timeout)
+	}
+	if shared.Timeout != original || clone.client().Timeout != original {
+		t.Fatal("SetTimeout 不应修改共享的 http.Client")
+	}
+	if ac.client().Transport != shared.Transport {
+		t.Fatal("新的客户端应继续使用共享传输层")
+	}
+}
+
+func TestCheckHTTPStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		wantNil     bool
+		wantAuth    bool
+		wantCode    string
+		wantMessage string
+	}{
+		{"2xx", http.StatusNoContent, "", true, false, "", ""},
+		{"JSON错误", http.StatusBadRequest, `{"success":false,"error":"参数错误","code":"bad_param"}`, false, false, "bad_param", "参数错误"},
+		{"JSON没有错误信息", http.StatusNotFound, `{"success":false,"code":"not_found"}`, false, false, "not_found", "Not Found"},
+		{"非JSON", http.StatusBadGateway, "<html>bad gateway</html>", false, false, "", "Bad Gateway"},
+		{"空响应体", http.StatusInternalServerError, "", false, false, "", "Internal Server Error"},
+		{"401", http.StatusUnauthorized, `{"success":false,"error":"密钥无效","code":"invalid_key"}`, false, true, "invalid_key", "密钥无效"},
+		{"403非JSON", http.StatusForbidden, "forbidden", false, true, "", "Forbidden"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status, Body: io.NopCloser(strings.NewReader(tt.body))}
+			err := checkHTTPStatus(resp)
+			if tt.wantNil {
+				if err != nil {
+					t.Fatalf("err = %v, want nil", err)
+				}
+				return
+			}
+
+			var authErr *AuthError
+			if errors.As(err, &authErr) != tt.wantAuth {
+				t.Fatalf("err = %T, AuthError = %v", err, tt.wantAuth)
+			}
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("err = %T, want *APIError", err)
+			}
+			if apiErr.StatusCode != tt.status || apiErr.Code != tt.wantCode || apiErr.Message != tt.wantMessage {
+				t.Errorf("APIError = %+v", apiErr)
+			}
+		})
+	}
+}
+
+func TestAPIErrorMessages(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{&APIError{StatusCode: 500, Message: "boom"}, "API错误(HTTP 500): boom"},
+		{&APIError{StatusCode: 400, Code: "bad_param", Message: "参数错误"}, "API错误(HTTP 400, bad_param): 参数错误"},
+		{&AuthError{APIError: &APIError{StatusCode: 401, Message: "密钥无效"}}, "认证失败，请检查API密钥: 密钥无效"},
+		{&AuthError{APIError: &APIError{StatusCode: 403, Message: "Forbidden"}}, "无权访问: Forbidden"},
+	}
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("Error() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+// flakyServer 前 failures 次请求返回 status，之后返回200，记录每次请求的请求ID
+type flakyServer struct {
+	*httptest.Server
+	mu         sync.Mutex
+	requestIDs []string
+}
+
+func newFlakyServer(t *testing.T, failures, status int, retryAfter string) *flakyServer {
+	t.Helper()
+	fs := &flakyServer{}
+	fs.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fs.mu.Lock()
+		fs.requestIDs = append(fs.requestIDs, r.Header.Get("X-Request-ID"))
+		count := len(fs.requestIDs)
+		fs.mu.Unlock()
+		if count <= failures {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(status)
+			return
+		}
+		io.WriteString(w, `{"success":true}`)
+	}))
+	t.Cleanup(fs.Close)
+	return fs
+}
+
+func (fs *flakyServer) requests() []string {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return append([]string(nil), fs.requestIDs...)
+}
+
+// newRetryTestClient 指向测试服务器、重试等待很短的客户端
+func newRetryTestClient(baseURL string) *APIClient {
+	ac := NewAPIClient()
+	ac.baseURL = baseURL
+	ac.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond})
+	return ac
+}
+
+func TestSendRetriesIdempotentRequests(t *testing.T) {
+	for _, status := range []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusBadGateway} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			server := newFlakyServer(t, 2, status, "")
+			ac := newRetryTestClient(server.URL)
+
+			resp, err := ac.send(context.Background(), http.MethodGet, server.URL+"/servers", nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, want 200", resp.StatusCode)
+			}
+			ids := server.requests()
+			if len(ids) != 3 || ids[0] == "" || ids[0] != ids[1] || ids[1] != ids[2] {
+				t.Fatalf("requests = %q, want 3 attempts sharing one request ID", ids)
+			}
+		})
+	}
+}
+
+func TestSendGivesUpAfterMaxAttempts(t *testing.T) {
+	server := newFlakyServer(t, 10, http.StatusServiceUnavailable, "")
+	ac := newRetryTestClient(server.URL)
+
+	resp, err := ac.send(context.Background(), http.MethodGet, server.URL, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || len(server.requests()) != 3 {
+		t.Fatalf("status = %d after %d requests, want the last 503 after 3", resp.StatusCode, len(server.requests()))
+	}
+}
+
+func TestSendDoesNotRetryPost(t *testing.T) {
+	server := newFlakyServer(t, 1, http.StatusServiceUnavailable, "")
+	ac := newRetryTestClient(server.URL)
+
+	resp, err := ac.send(context.Background(), http.MethodPost, server.URL, []byte("{}"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || len(server.requests()) != 1 {
+		t.Fatalf("status = %d after %d requests, want one 503", resp.StatusCode, len(server.requests()))
+	}
+}
+
+func TestSendHonorsRetryAfter(t *testing.T) {
+	server := newFlakyServer(t, 1, http.StatusTooManyRequests, "1")
+	ac := newRetryTestClient(server.URL)
+
+	start := time.Now()
+	resp, err := ac.send(context.Background(), http.MethodGet, server.URL, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("重试前只等待了 %v，应遵守 Retry-After: 1", elapsed)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+}
+
+func TestSendStopsWhenContextCanceledDuringBackoff(t *testing.T) {
+	server := newFlakyServer(t, 10, http.StatusServiceUnavailable, "60")
+	ac := newRetryTestClient(server.URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		for len(server.requests()) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+
+	start := time.Now()
+	resp, err := ac.send(ctx, http.MethodGet, server.URL, nil, nil)
+	if !errors.Is(err, context.Canceled) || resp != nil {
+		t.Fatalf("resp = %v, err = %v; want context.Canceled", resp, err)
+	}
+	if time.Since(start) > 5*time.Second || len(server.requests()) != 1 {
+		t.Fatalf("取消后应立即停止等待，请求了 %d 次", len(server.requests()))
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		min, max time.Duration
+	}{
+		{"空", "", 0, 0},
+		{"秒数", "5", 5 * time.Second, 5 * time.Second},
+		{"零", "0", 0, 0},
+		{"负数", "-3", 0, 0},
+		{"超过上限", "86400", maxRetryAfter, maxRetryAfter},
+		{"HTTP日期", time.Now().Add(30 * time.Second).UTC().Format(http.TimeFormat), 28 * time.Second, 30 * time.Second},
+		{"过去的日期", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, 0},
+		{"遥远的日期", time.Now().Add(24 * time.Hour).UTC().Format(http.TimeFormat), maxRetryAfter, maxRetryAfter},
+		{"无法解析", "soon", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRetryAfter(tt.value); got < tt.min || got > tt.max {
+				t.Errorf("parseRetryAfter(%q) = %v, want [%v, %v]", tt.value, got, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+// TestReconfigureWhileRequesting 重新加载配置修改API地址和目录缓存时，进行中的请求不应产生数据竞争（配合 -race）
+func TestReconfigureWhileRequesting(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"success":true,"data":{"servers":[]}}`)
+	}))
+	defer server.Close()
+	ac := newRetryTestClient(server.URL)
+	cacheDir := t.TempDir()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if _, err := ac.GetServerList(context.Background()); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	for j := 0; j < 20; j++ {
+		if err := ac.SetBaseURL(server.URL + "/"); err != nil {
+			t.Fatal(err)
+		}
+		ac.SetCatalogCache(NewCatalogCache(cacheDir, time.Minute))
+	}
+	wg.Wait()
+}
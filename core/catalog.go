@@ -0,0 +1,345 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// catalogDirEnv 追加本地目录的环境变量，多个目录用系统路径分隔符分隔
+const catalogDirEnv = "SYSTEMREINSTALLER_CATALOG_DIR"
+
+// localCatalogManifest 本地目录中的清单文件名
+const localCatalogManifest = "catalog.json"
+
+// 目录来源类型
+const (
+	CatalogTypeAPI   = "api"
+	CatalogTypeLocal = "local"
+)
+
+// Catalog 镜像目录来源
+type Catalog interface {
+	// Name 来源名称，用于标记合并后的服务器
+	Name() string
+	// Servers 获取该来源的服务器列表
+	Servers(ctx context.Context) ([]ServerInfo, error)
+}
+
+// CatalogConfig 目录来源配置
+type CatalogConfig struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // api, local
+	URL  string `json:"url"`  // api: API地址
+	Path string `json:"path"` // local: 本地目录
+}
+
+// APICatalog 通过APIClient获取的在线目录
+type APICatalog struct {
+	name   string
+	client *APIClient
+}
+
+// NewAPICatalog 创建在线目录
+func NewAPICatalog(name string, client *APIClient) *APICatalog {
+	return &APICatalog{name: name, client: client}
+}
+
+// Name 来源名称
+func (c *APICatalog) Name() string {
+	return c.name
+}
+
+// Servers 获取服务器列表
+func (c *APICatalog) Servers(ctx context.Context) ([]ServerInfo, error) {
+	return c.client.GetServerList(ctx)
+}
+
+// LocalCatalog 从本地目录读取清单和镜像，用于离线环境
+//
+// 目录中的 catalog.json 与API的 data 字段格式相同，
+// download_urls 中镜像地址的相对路径相对于该目录解析。
+type LocalCatalog struct {
+	name string
+	dir  string
+}
+
+// NewLocalCatalog 创建本地目录
+func NewLocalCatalog(name, dir string) *LocalCatalog {
+	return &LocalCatalog{name: name, dir: dir}
+}
+
+// Name 来源名称
+func (c *LocalCatalog) Name() string {
+	return c.name
+}
+
+// Servers 读取清单并将镜像路径转换为file://地址
+func (c *LocalCatalog) Servers(ctx context.Context) ([]ServerInfo, error) {
+	root, err := filepath.Abs(c.dir)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(root, localCatalogManifest))
+	if err != nil {
+		return nil, fmt.Errorf("读取本地目录清单失败: %v", err)
+	}
+
+	var manifest ServerData
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("本地目录清单格式错误: %v", err)
+	}
+
+	for i := range manifest.Servers {
+		server := &manifest.Servers[i]
+		for name, image := range server.Images {
+			resolved, err := resolveLocalImage(root, image.URL)
+			if err != nil {
+				return nil, fmt.Errorf("镜像%s: %v", name, err)
+			}
+			image.URL = resolved
+			server.Images[name] = image
+		}
+	}
+	return manifest.Servers, nil
+}
+
+// resolveLocalImage 将清单中的镜像地址解析为本地目录内的file://地址
+func resolveLocalImage(root, location string) (string, error) {
+	if strings.Contains(location, "://") {
+		return location, nil
+	}
+	path := filepath.Clean(filepath.Join(root, filepath.FromSlash(location)))
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("路径超出目录范围: %s", location)
+	}
+	slashed := filepath.ToSlash(path)
+	if !strings.HasPrefix(slashed, "/") {
+		slashed = "/" + slashed // Windows盘符: file:///C:/...
+	}
+	return (&url.URL{Scheme: "file", Path: slashed}).String(), nil
+}
+
+// NewCatalog 根据配置创建目录来源，API来源的地址为空时使用defaultClient
+func NewCatalog(config CatalogConfig, defaultClient *APIClient) (Catalog, error) {
+	switch config.Type {
+	case CatalogTypeAPI, "":
+		client := defaultClient
+		if config.URL != "" {
+			var err error
+			if client, err = defaultClient.WithBaseURL(config.URL); err != nil {
+				return nil, err
+			}
+		}
+		name := config.Name
+		if name == "" {
+			name = client.BaseURL()
+		}
+		return NewAPICatalog(name, client), nil
+	case CatalogTypeLocal:
+		if config.Path == "" {
+			return nil, fmt.Errorf("本地目录来源必须指定路径")
+		}
+		name := config.Name
+		if name == "" {
+			name = filepath.Base(filepath.Clean(config.Path))
+		}
+		return NewLocalCatalog(name, config.Path), nil
+	default:
+		return nil, fmt.Errorf("不支持的目录来源类型: %s", config.Type)
+	}
+}
+
+// CatalogResult 合并后的目录
+type CatalogResult struct {
+	Servers []ServerInfo      `json:"servers"`
+	Errors  map[string]string `json:"errors,omitempty"` // 来源 -> 错误信息
+}
+
+// addError 记录来源的错误，同一来源的多条错误以分号连接
+func (r *CatalogResult) addError(source, message string) {
+	if r.Errors == nil {
+		r.Errors = make(map[string]string)
+	}
+	if existing := r.Errors[source]; existing != "" {
+		message = existing + "; " + message
+	}
+	r.Errors[source] = message
+}
+
+// CatalogSet 同时从多个来源获取目录并合并
+type CatalogSet struct {
+	catalogs []Catalog
+}
+
+// NewCatalogSet 创建目录集合
+func NewCatalogSet(catalogs ...Catalog) *CatalogSet {
+	return &CatalogSet{catalogs: catalogs}
+}
+
+// Servers 并发获取所有来源的服务器，并以来源名称标记
+// 部分来源失败时返回其余结果，只有全部失败才返回错误
+func (cs *CatalogSet) Servers(ctx context.Context) (*CatalogResult, error) {
+	if len(cs.catalogs) == 0 {
+		return nil, fmt.Errorf("%w: 没有配置目录来源", ErrServerNotFound)
+	}
+
+	type sourceResult struct {
+		servers []ServerInfo
+		err     error
+	}
+	results := make([]sourceResult, len(cs.catalogs))
+
+	var wg sync.WaitGroup
+	for i, catalog := range cs.catalogs {
+		wg.Add(1)
+		go func(i int, catalog Catalog) {
+			defer wg.Done()
+			servers, err := catalog.Servers(ctx)
+			results[i] = sourceResult{servers: servers, err: err}
+		}(i, catalog)
+	}
+	wg.Wait()
+
+	merged := &CatalogResult{Servers: []ServerInfo{}}
+	var errs []error
+	for i, result := range results {
+		name := cs.catalogs[i].Name()
+		if result.err != nil {
+			merged.addError(name, result.err.Error())
+			errs = append(errs, fmt.Errorf("%s: %w", name, result.err))
+			continue
+		}
+		for _, server := range result.servers {
+			server.Source = name
+			// 格式错误的镜像条目不展示，其余条目照常使用
+			imageNames := make([]string, 0, len(server.Images))
+			for imageName := range server.Images {
+				imageNames = append(imageNames, imageName)
+			}
+			sort.Strings(imageNames)
+			for _, imageName := range imageNames {
+				if err := server.Images[imageName].Validate(); err != nil {
+					merged.addError(name, fmt.Sprintf("%s/%s: %v", server.Key(), imageName, err))
+					delete(server.Images, imageName)
+				}
+			}
+			merged.Servers = append(merged.Servers, server)
+		}
+	}
+
+	if len(errs) == len(cs.catalogs) {
+		return nil, errors.Join(errs...)
+	}
+	return merged, nil
+}
+
+// VHDList 获取指定服务器的VHD列表，key为 ServerInfo.Key() 或服务器ID，为空时使用第一个服务器
+func (cs *CatalogSet) VHDList(ctx context.Context, key string) (*VHDList, error) {
+	result, err := cs.Servers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	server, err := findServer(result.Servers, key)
+	if err != nil {
+		return nil, err
+	}
+	return newVHDList(*server), nil
+}
+
+// findServer 按key或ID查找服务器
+func findServer(servers []ServerInfo, key string) (*ServerInfo, error) {
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("%w: 没有可用的服务器", ErrServerNotFound)
+	}
+	if key == "" {
+		return &servers[0], nil
+	}
+	for i := range servers {
+		if servers[i].Key() == key {
+			return &servers[i], nil
+		}
+	}
+	for i := range servers {
+		if servers[i].ID == key {
+			return &servers[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrServerNotFound, key)
+}
+
+// newVHDList 将服务器的下载地址转换为VHD列表
+func newVHDList(server ServerInfo) *VHDList {
+	names := make([]string, 0, len(server.Images))
+	for name := range server.Images {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := &VHDList{
+		Server: server,
+		VHDs:   make([]VHDImage, 0, len(names)),
+	}
+	for _, name := range names {
+		list.VHDs = append(list.VHDs, VHDImage{
+			Name:  name,
+			Image: server.Images[name],
+		})
+	}
+	return list
+}
+
+// CatalogOptions 命令行指定的本地目录，追加在配置文件的来源之后
+type CatalogOptions struct {
+	CatalogDirs []string
+}
+
+// BuildCatalogSet 按生效的配置设置API地址，并创建目录集合
+//
+// 配置文件中的 catalog.sources 列出所有来源，未配置时只使用默认API；
+// 命令行和环境变量指定的本地目录追加在其后。API目录缓存在工作目录的 cache/catalog 下，
+// 有效期由 catalog.cache_ttl（秒）配置。
+func (si *SystemInstaller) BuildCatalogSet(client *APIClient, options CatalogOptions) (*CatalogSet, error) {
+	config := si.Config()
+	baseURL := config.API.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultAPIBaseURL
+	}
+	if err := client.SetBaseURL(baseURL); err != nil {
+		return nil, err
+	}
+
+	ttl := time.Duration(config.Catalog.CacheTTL) * time.Second
+	client.SetCatalogCache(NewCatalogCache(filepath.Join(si.workingDir, "cache", "catalog"), ttl))
+
+	configs := append([]CatalogConfig{}, config.Catalog.Sources...)
+	if len(configs) == 0 {
+		configs = []CatalogConfig{{Type: CatalogTypeAPI}}
+	}
+	dirs := append([]string{}, options.CatalogDirs...)
+	if env := os.Getenv(catalogDirEnv); env != "" {
+		dirs = append(dirs, filepath.SplitList(env)...)
+	}
+	for _, dir := range dirs {
+		configs = append(configs, CatalogConfig{Type: CatalogTypeLocal, Path: dir})
+	}
+
+	catalogs := make([]Catalog, 0, len(configs))
+	for _, config := range configs {
+		catalog, err := NewCatalog(config, client)
+		if err != nil {
+			return nil, err
+		}
+		catalogs = append(catalogs, catalog)
+	}
+	return NewCatalogSet(catalogs...), nil
+}
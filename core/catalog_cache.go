@@ -0,0 +1,197 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// defaultCatalogCacheTTL 缓存的目录在此时间内直接使用，不再请求API
+const defaultCatalogCacheTTL = 10 * time.Minute
+
+// catalogCacheEntry 缓存的目录响应
+type catalogCacheEntry struct {
+	URL          string          `json:"url"`
+	ETag         string          `json:"etag,omitempty"`
+	LastModified string          `json:"last_modified,omitempty"`
+	FetchedAt    time.Time       `json:"fetched_at"` // 最近一次确认与服务器一致的时间
+	Body         json.RawMessage `json:"body"`       // API原始响应
+}
+
+// CatalogCache 目录响应的磁盘缓存，同时在内存中保留本次运行读到的内容
+type CatalogCache struct {
+	dir     string
+	ttl     time.Duration
+	mutex   sync.Mutex
+	entries map[string]*catalogCacheEntry
+}
+
+// NewCatalogCache 创建目录缓存，ttl<=0 时使用默认值
+func NewCatalogCache(dir string, ttl time.Duration) *CatalogCache {
+	if ttl <= 0 {
+		ttl = defaultCatalogCacheTTL
+	}
+	return &CatalogCache{
+		dir:     dir,
+		ttl:     ttl,
+		entries: make(map[string]*catalogCacheEntry),
+	}
+}
+
+// path 缓存文件路径，文件名取URL的哈希
+func (cc *CatalogCache) path(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(cc.dir, hex.EncodeToString(sum[:8])+".json")
+}
+
+// load 读取缓存，先查内存再查磁盘，没有缓存时返回nil
+func (cc *CatalogCache) load(url string) *catalogCacheEntry {
+	cc.mutex.Lock()
+	defer cc.mutex.Unlock()
+
+	if entry, ok := cc.entries[url]; ok {
+		copied := *entry
+		return &copied
+	}
+
+	data, err := os.ReadFile(cc.path(url))
+	if err != nil {
+		return nil
+	}
+	var entry catalogCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.URL != url {
+		return nil
+	}
+	cc.entries[url] = &entry
+	copied := entry
+	return &copied
+}
+
+// store 保存缓存到内存和磁盘
+func (cc *CatalogCache) store(entry *catalogCacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	cc.mutex.Lock()
+	defer cc.mutex.Unlock()
+
+	copied := *entry
+	cc.entries[entry.URL] = &copied
+	if err := os.MkdirAll(cc.dir, 0755); err != nil {
+		return fmt.Errorf("创建目录缓存失败: %v", err)
+	}
+	return writeFileAtomic(cc.path(entry.URL), data, 0644)
+}
+
+// fresh 缓存是否仍在有效期内
+func (cc *CatalogCache) fresh(entry *catalogCacheEntry) bool {
+	return time.Since(entry.FetchedAt) < cc.ttl
+}
+
+// Clear 清空缓存
+func (cc *CatalogCache) Clear() error {
+	cc.mutex.Lock()
+	defer cc.mutex.Unlock()
+
+	cc.entries = make(map[string]*catalogCacheEntry)
+	if err := os.RemoveAll(cc.dir); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// getCachedServerList 获取服务器列表，有效期内直接使用缓存，过期后带条件请求重新验证
+// 网络错误或服务器故障时返回上次成功的结果，并标记为过期
+func (ac *APIClient) getCachedServerList(ctx context.Context, cache *CatalogCache, url string) ([]ServerInfo, error) {
+	entry := cache.load(url)
+	if entry != nil && cache.fresh(entry) {
+		return decodeCachedServers(entry, false)
+	}
+
+	headers := map[string]string{"Accept": "application/json"}
+	if entry != nil {
+		if entry.ETag != "" {
+			headers["If-None-Match"] = entry.ETag
+		}
+		if entry.LastModified != "" {
+			headers["If-Modified-Since"] = entry.LastModified
+		}
+	}
+
+	// serveStale 使用上次成功的结果
+	serveStale := func(cause error) ([]ServerInfo, error) {
+		ac.logger.Warning("目录获取失败，使用本地缓存", "url", url, "fetched_at", entry.FetchedAt, "error", cause)
+		return decodeCachedServers(entry, true)
+	}
+
+	resp, err := ac.send(ctx, http.MethodGet, url, nil, headers)
+	if err != nil {
+		if entry != nil && ctx.Err() == nil {
+			return serveStale(err)
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		entry.FetchedAt = time.Now()
+		cache.store(entry)
+		return decodeCachedServers(entry, false)
+	}
+	if err := checkHTTPStatus(resp); err != nil {
+		// 认证等客户端错误需要用户处理，不用缓存掩盖
+		var apiErr *APIError
+		if entry != nil && errors.As(err, &apiErr) &&
+			(apiErr.StatusCode >= 500 || apiErr.StatusCode == http.StatusTooManyRequests) {
+			return serveStale(err)
+		}
+		return nil, err
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxAPIResponseSize))
+	if err != nil {
+		if entry != nil {
+			return serveStale(err)
+		}
+		return nil, err
+	}
+	var data ServerData
+	if err := decodeAPIResponse(resp.StatusCode, body, &data); err != nil {
+		return nil, err
+	}
+
+	if err := cache.store(&catalogCacheEntry{
+		URL:          url,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		FetchedAt:    time.Now(),
+		Body:         body,
+	}); err != nil {
+		ac.logger.Warning("保存目录缓存失败", "url", url, "error", err)
+	}
+	return data.Servers, nil
+}
+
+// decodeCachedServers 从缓存的响应中解析服务器列表
+func decodeCachedServers(entry *catalogCacheEntry, stale bool) ([]ServerInfo, error) {
+	var data ServerData
+	if err := decodeAPIResponse(http.StatusOK, entry.Body, &data); err != nil {
+		return nil, err
+	}
+	for i := range data.Servers {
+		data.Servers[i].Stale = stale
+	}
+	return data.Servers, nil
+}
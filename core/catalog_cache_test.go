@@ -0,0 +1,167 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+const catalogCacheTestBody = `{"success":true,"data":{"servers":[{"id":"hk","name":"Hong Kong","download_urls":{"win11":"https://cdn.example.com/win11.vhd"}}]}}`
+
+// catalogTestServer 返回带 ETag 的目录，请求头匹配时返回304；status 不为0时直接返回该状态码
+type catalogTestServer struct {
+	*httptest.Server
+	mu          sync.Mutex
+	status      int
+	requests    int
+	conditional []string // 每次请求的 If-None-Match
+}
+
+func newCatalogTestServer(t *testing.T) *catalogTestServer {
+	t.Helper()
+	cs := &catalogTestServer{}
+	cs.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cs.mu.Lock()
+		cs.requests++
+		cs.conditional = append(cs.conditional, r.Header.Get("If-None-Match"))
+		status := cs.status
+		cs.mu.Unlock()
+
+		if status != 0 {
+			w.WriteHeader(status)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		io.WriteString(w, catalogCacheTestBody)
+	}))
+	t.Cleanup(cs.Close)
+	return cs
+}
+
+func (cs *catalogTestServer) setStatus(status int) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.status = status
+}
+
+func (cs *catalogTestServer) stats() (int, []string) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.requests, append([]string(nil), cs.conditional...)
+}
+
+// newCachedTestClient 使用 dir 下目录缓存的客户端
+func newCachedTestClient(baseURL, dir string, ttl time.Duration) *APIClient {
+	ac := newRetryTestClient(baseURL)
+	ac.SetRetryPolicy(RetryPolicy{MaxAttempts: 1})
+	ac.SetCatalogCache(NewCatalogCache(dir, ttl))
+	return ac
+}
+
+func getTestServers(t *testing.T, ac *APIClient) []ServerInfo {
+	t.Helper()
+	servers, err := ac.GetServerList(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(servers) != 1 || servers[0].ID != "hk" || servers[0].Images["win11"].URL == "" {
+		t.Fatalf("servers = %+v", servers)
+	}
+	return servers
+}
+
+func TestCatalogCacheRevalidatesWithETag(t *testing.T) {
+	server := newCatalogTestServer(t)
+	// 有效期极短，每次都需要向服务器确认
+	ac := newCachedTestClient(server.URL, t.TempDir(), time.Nanosecond)
+
+	getTestServers(t, ac)
+	servers := getTestServers(t, ac)
+	if servers[0].Stale {
+		t.Fatal("304 确认后的缓存不应标记为过期")
+	}
+	requests, conditional := server.stats()
+	if requests != 2 || conditional[0] != "" || conditional[1] != `"v1"` {
+		t.Fatalf("requests = %d, If-None-Match = %q", requests, conditional)
+	}
+}
+
+func TestCatalogCacheTTL(t *testing.T) {
+	server := newCatalogTestServer(t)
+	dir := t.TempDir()
+	ac := newCachedTestClient(server.URL, dir, time.Hour)
+
+	getTestServers(t, ac)
+	getTestServers(t, ac)
+	if requests, _ := server.stats(); requests != 1 {
+		t.Fatalf("有效期内不应重复请求，requests = %d", requests)
+	}
+
+	// 缓存保存在磁盘上，新的缓存实例同样在有效期内直接使用
+	ac.SetCatalogCache(NewCatalogCache(dir, time.Hour))
+	getTestServers(t, ac)
+	if requests, _ := server.stats(); requests != 1 {
+		t.Fatalf("磁盘缓存在有效期内不应重复请求，requests = %d", requests)
+	}
+
+	// 过期后带条件请求重新验证
+	ac.SetCatalogCache(NewCatalogCache(dir, time.Nanosecond))
+	getTestServers(t, ac)
+	if requests, conditional := server.stats(); requests != 2 || conditional[1] != `"v1"` {
+		t.Fatalf("过期后应重新验证，requests = %d, If-None-Match = %q", requests, conditional)
+	}
+}
+
+func TestCatalogCacheServesStaleOnFailure(t *testing.T) {
+	tests := []struct {
+		name  string
+		fail  func(server *catalogTestServer)
+		stale bool
+	}{
+		{"网络错误", func(server *catalogTestServer) { server.Close() }, true},
+		{"服务器故障", func(server *catalogTestServer) { server.setStatus(http.StatusServiceUnavailable) }, true},
+		{"认证失败", func(server *catalogTestServer) { server.setStatus(http.StatusUnauthorized) }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newCatalogTestServer(t)
+			ac := newCachedTestClient(server.URL, t.TempDir(), time.Nanosecond)
+			getTestServers(t, ac)
+
+			tt.fail(server)
+			servers, err := ac.GetServerList(context.Background())
+			if !tt.stale {
+				var authErr *AuthError
+				if !errors.As(err, &authErr) {
+					t.Fatalf("认证错误不应被缓存掩盖: servers = %+v, err = %v", servers, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(servers) != 1 || !servers[0].Stale {
+				t.Fatalf("servers = %+v, want the cached list marked stale", servers)
+			}
+		})
+	}
+}
+
+func TestCatalogCacheWithoutEntryReturnsError(t *testing.T) {
+	server := newCatalogTestServer(t)
+	server.Close()
+	ac := newCachedTestClient(server.URL, t.TempDir(), time.Hour)
+
+	if servers, err := ac.GetServerList(context.Background()); err == nil {
+		t.Fatalf("没有缓存时网络错误应返回错误，servers = %+v", servers)
+	}
+}
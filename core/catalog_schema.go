@@ -0,0 +1,148 @@
+package core
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// 镜像启动模式，与SystemDetector的检测结果一致
+const (
+	BootModeUEFI   = "UEFI"
+	BootModeLegacy = "Legacy"
+)
+
+// ImageEntry 目录中的镜像条目
+//
+// 旧格式的条目只是一个下载地址字符串，解析后只有URL字段。
+type ImageEntry struct {
+	URL            string   `json:"url"`
+	OSFamily       string   `json:"os_family,omitempty"` // windows, linux
+	Edition        string   `json:"edition,omitempty"`   // 例如 Pro, Server Datacenter
+	Version        string   `json:"version,omitempty"`   // 例如 11, 2022, 12
+	Build          string   `json:"build,omitempty"`     // 例如 22631.2861
+	Arch           string   `json:"arch,omitempty"`      // amd64, arm64, 386
+	Language       string   `json:"language,omitempty"`  // 例如 zh-CN, en-US
+	BootModes      []string `json:"boot_modes,omitempty"`
+	Size           int64    `json:"size,omitempty"`            // 解压后字节数
+	CompressedSize int64    `json:"compressed_size,omitempty"` // 下载字节数
+	Format         string   `json:"format,omitempty"`          // vhd, vhdx, raw, qcow2, iso
+	Compression    string   `json:"compression,omitempty"`     // none, gz, xz, zst
+	SHA256         string   `json:"sha256,omitempty"`
+	ReleaseDate    string   `json:"release_date,omitempty"`  // YYYY-MM-DD
+	MinDiskSize    int64    `json:"min_disk_size,omitempty"` // 字节
+}
+
+// imageEntryFields 避免UnmarshalJSON递归
+type imageEntryFields ImageEntry
+
+// UnmarshalJSON 同时支持字符串地址和对象两种格式
+func (e *ImageEntry) UnmarshalJSON(data []byte) error {
+	var url string
+	if err := json.Unmarshal(data, &url); err == nil {
+		*e = ImageEntry{URL: url}
+		return nil
+	}
+
+	var fields imageEntryFields
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	*e = ImageEntry(fields)
+	e.normalize()
+	return nil
+}
+
+// normalize 统一大小写和别名
+func (e *ImageEntry) normalize() {
+	e.OSFamily = strings.ToLower(e.OSFamily)
+	e.Format = strings.ToLower(e.Format)
+	e.Compression = strings.ToLower(e.Compression)
+	e.SHA256 = strings.ToLower(e.SHA256)
+
+	switch strings.ToLower(e.Arch) {
+	case "x86_64", "x64", "amd64":
+		e.Arch = "amd64"
+	case "aarch64", "arm64":
+		e.Arch = "arm64"
+	case "x86", "i386", "i686", "386":
+		e.Arch = "386"
+	}
+
+	modes := make([]string, 0, len(e.BootModes))
+	for _, mode := range e.BootModes {
+		switch strings.ToLower(mode) {
+		case "uefi", "efi":
+			modes = append(modes, BootModeUEFI)
+		case "legacy", "bios":
+			modes = append(modes, BootModeLegacy)
+		default:
+			modes = append(modes, mode)
+		}
+	}
+	e.BootModes = modes
+}
+
+// Validate 检查条目字段
+func (e ImageEntry) Validate() error {
+	if e.URL == "" {
+		return fmt.Errorf("镜像缺少下载地址")
+	}
+	if e.SHA256 != "" {
+		if decoded, err := hex.DecodeString(e.SHA256); err != nil || len(decoded) != 32 {
+			return fmt.Errorf("镜像SHA-256格式错误: %s", e.SHA256)
+		}
+	}
+	for _, mode := range e.BootModes {
+		if mode != BootModeUEFI && mode != BootModeLegacy {
+			return fmt.Errorf("不支持的启动模式: %s", mode)
+		}
+	}
+	if e.ReleaseDate != "" {
+		if _, err := time.Parse("2006-01-02", e.ReleaseDate); err != nil {
+			return fmt.Errorf("发布日期格式错误: %s", e.ReleaseDate)
+		}
+	}
+	if e.Size < 0 || e.CompressedSize < 0 || e.MinDiskSize < 0 {
+		return fmt.Errorf("镜像大小不能为负数")
+	}
+	return nil
+}
+
+// SupportsBootMode 是否支持指定启动模式，未声明启动模式时视为都支持
+func (e ImageEntry) SupportsBootMode(mode string) bool {
+	if len(e.BootModes) == 0 {
+		return true
+	}
+	return containsString(e.BootModes, mode)
+}
+
+// RequiredDiskSize 安装所需的磁盘空间，未声明时使用解压后大小
+func (e ImageEntry) RequiredDiskSize() int64 {
+	if e.MinDiskSize > 0 {
+		return e.MinDiskSize
+	}
+	return e.Size
+}
+
+// CompatibilityIssues 检查镜像与本机的兼容性，返回不兼容的原因
+// arch 为空时使用当前架构，bootMode 或 diskBytes 未知时跳过对应检查
+func (e ImageEntry) CompatibilityIssues(arch, bootMode string, diskBytes int64) []string {
+	issues := []string{}
+	if arch == "" {
+		arch = runtime.GOARCH
+	}
+	if e.Arch != "" && e.Arch != arch {
+		issues = append(issues, fmt.Sprintf("镜像架构为%s，本机为%s", e.Arch, arch))
+	}
+	if (bootMode == BootModeUEFI || bootMode == BootModeLegacy) && !e.SupportsBootMode(bootMode) {
+		issues = append(issues, fmt.Sprintf("镜像不支持%s启动", bootMode))
+	}
+	if required := e.RequiredDiskSize(); diskBytes > 0 && required > diskBytes {
+		issues = append(issues, fmt.Sprintf("磁盘空间不足，至少需要%.1f GB", float64(required)/(1<<30)))
+	}
+	return issues
+}
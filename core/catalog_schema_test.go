@@ -0,0 +1,125 @@
+package core
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestImageEntryUnmarshal(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want ImageEntry
+	}{
+		{"旧格式字符串", `"https://cdn.example.com/win11.vhd"`, ImageEntry{URL: "https://cdn.example.com/win11.vhd"}},
+		{"只有地址的对象", `{"url":"https://cdn.example.com/debian.raw"}`, ImageEntry{URL: "https://cdn.example.com/debian.raw", BootModes: []string{}}},
+		{"完整对象", `{
+			"url": "https://cdn.example.com/win11.vhdx.zst",
+			"os_family": "Windows",
+			"edition": "Pro",
+			"version": "11",
+			"build": "22631.2861",
+			"arch": "x86_64",
+			"language": "zh-CN",
+			"boot_modes": ["efi", "BIOS"],
+			"size": 21474836480,
+			"compressed_size": 6442450944,
+			"format": "VHDX",
+			"compression": "ZST",
+			"sha256": "` + strings.Repeat("AB", 32) + `",
+			"release_date": "2024-01-09",
+			"min_disk_size": 32212254720
+		}`, ImageEntry{
+			URL:            "https://cdn.example.com/win11.vhdx.zst",
+			OSFamily:       "windows",
+			Edition:        "Pro",
+			Version:        "11",
+			Build:          "22631.2861",
+			Arch:           "amd64",
+			Language:       "zh-CN",
+			BootModes:      []string{BootModeUEFI, BootModeLegacy},
+			Size:           21474836480,
+			CompressedSize: 6442450944,
+			Format:         "vhdx",
+			Compression:    "zst",
+			SHA256:         strings.Repeat("ab", 32),
+			ReleaseDate:    "2024-01-09",
+			MinDiskSize:    32212254720,
+		}},
+		{"架构别名", `{"url":"https://cdn.example.com/arm.raw","arch":"aarch64"}`, ImageEntry{URL: "https://cdn.example.com/arm.raw", Arch: "arm64", BootModes: []string{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got ImageEntry
+			if err := json.Unmarshal([]byte(tt.data), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got  %+v\nwant %+v", got, tt.want)
+			}
+			if err := got.Validate(); err != nil {
+				t.Errorf("Validate: %v", err)
+			}
+		})
+	}
+
+	// 旧格式和新格式可以混在同一个目录里
+	var server ServerInfo
+	if err := json.Unmarshal([]byte(`{"id":"hk","download_urls":{"old":"https://a/old.vhd","new":{"url":"https://a/new.vhd","arch":"x64"}}}`), &server); err != nil {
+		t.Fatal(err)
+	}
+	if server.Images["old"].URL != "https://a/old.vhd" || server.Images["new"].Arch != "amd64" {
+		t.Fatalf("images = %+v", server.Images)
+	}
+
+	var invalid ImageEntry
+	for _, data := range []string{`42`, `["https://a/x.vhd"]`, `{"url": 1}`} {
+		if err := json.Unmarshal([]byte(data), &invalid); err == nil {
+			t.Errorf("Unmarshal(%s) 应失败", data)
+		}
+	}
+}
+
+func TestImageEntryValidate(t *testing.T) {
+	valid := ImageEntry{URL: "https://cdn.example.com/win11.vhd", SHA256: strings.Repeat("ab", 32), BootModes: []string{BootModeUEFI}, ReleaseDate: "2024-01-09"}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("valid entry: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(e *ImageEntry)
+		want   string
+	}{
+		{"缺少地址", func(e *ImageEntry) { e.URL = "" }, "下载地址"},
+		{"哈希不是十六进制", func(e *ImageEntry) { e.SHA256 = strings.Repeat("zz", 32) }, "SHA-256"},
+		{"哈希长度错误", func(e *ImageEntry) { e.SHA256 = "abcd" }, "SHA-256"},
+		{"未知启动模式", func(e *ImageEntry) { e.BootModes = []string{"coreboot"} }, "启动模式"},
+		{"日期格式错误", func(e *ImageEntry) { e.ReleaseDate = "09/01/2024" }, "发布日期"},
+		{"负数大小", func(e *ImageEntry) { e.Size = -1 }, "负数"},
+		{"负数下载大小", func(e *ImageEntry) { e.CompressedSize = -1 }, "负数"},
+		{"负数磁盘需求", func(e *ImageEntry) { e.MinDiskSize = -1 }, "负数"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := valid
+			entry.BootModes = append([]string(nil), valid.BootModes...)
+			tt.modify(&entry)
+			err := entry.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Validate() = %v, want error mentioning %q", err, tt.want)
+			}
+		})
+	}
+
+	// 未识别的启动模式在解析时原样保留，由 Validate 报告
+	var entry ImageEntry
+	if err := json.Unmarshal([]byte(`{"url":"https://a/x.vhd","boot_modes":["coreboot"]}`), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if err := entry.Validate(); err == nil {
+		t.Fatal("未知启动模式应在 Validate 中报错")
+	}
+}
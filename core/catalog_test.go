@@ -0,0 +1,39 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// staticCatalog 返回固定服务器列表的目录来源
+type staticCatalog struct {
+	name    string
+	servers []ServerInfo
+}
+
+func (c staticCatalog) Name() string { return c.name }
+
+func (c staticCatalog) Servers(ctx context.Context) ([]ServerInfo, error) {
+	return c.servers, nil
+}
+
+func TestCatalogSetAccumulatesImageErrors(t *testing.T) {
+	server := ServerInfo{ID: "s1", Name: "server", Images: map[string]ImageEntry{
+		"good":     {URL: "https://example.com/good.img"},
+		"no-url":   {},
+		"bad-hash": {URL: "https://example.com/bad.img", SHA256: "xyz"},
+	}}
+	result, err := NewCatalogSet(staticCatalog{name: "local", servers: []ServerInfo{server}}).Servers(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := result.Errors["local"]
+	if !strings.Contains(message, "no-url") || !strings.Contains(message, "bad-hash") {
+		t.Fatalf("错误信息应包含所有无效镜像: %q", message)
+	}
+	if len(result.Servers) != 1 || len(result.Servers[0].Images) != 1 {
+		t.Fatalf("应只保留有效镜像: %+v", result.Servers)
+	}
+}
@@ -0,0 +1,430 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"SystemReinstaller/utils"
+)
+
+// ConfigVersion 当前配置文件的结构版本，结构变化时递增并在 configMigrations 中添加迁移
+const ConfigVersion = 1
+
+// configFileName 配置文件名
+const configFileName = "config.json"
+
+// 配置取值范围
+const (
+	maxAPITimeout       = 600
+	maxDownloadParallel = 16
+)
+
+// 主题
+const (
+	ThemeLight = "light"
+	ThemeDark  = "dark"
+	ThemeAuto  = "auto"
+)
+
+// Config 程序配置
+type Config struct {
+	Version     int               `json:"version"`
+	API         APIConfig         `json:"api"`
+	Catalog     CatalogSettings   `json:"catalog"`
+	Download    DownloadConfig    `json:"download"`
+	Drivers     DriverSettings    `json:"drivers"`
+	Proxy       ProxyConfig       `json:"proxy"`
+	TLS         TLSConfig         `json:"tls"`
+	LogLevel    string            `json:"log_level"`
+	Theme       string            `json:"theme"`
+	Install     InstallDefaults   `json:"install"`
+	Diagnostics DiagnosticsPolicy `json:"diagnostics"`
+}
+
+// APIConfig API服务配置
+type APIConfig struct {
+	BaseURL         string `json:"base_url,omitempty"`          // 为空时使用默认地址
+	ScriptUpdateURL string `json:"script_update_url,omitempty"` // 为空时使用API默认地址
+	Timeout         int    `json:"timeout"`                     // 请求超时(秒)
+}
+
+// CatalogSettings 镜像目录配置
+type CatalogSettings struct {
+	Sources  []CatalogConfig `json:"sources,omitempty"` // 为空时只使用默认API
+	CacheTTL int             `json:"cache_ttl"`         // 目录缓存有效期(秒)
+}
+
+// DownloadConfig 下载配置
+type DownloadConfig struct {
+	Dir         string `json:"dir,omitempty"` // 镜像保存目录，为空时使用工作目录下的 vhd
+	Concurrency int    `json:"concurrency"`   // 同时进行的下载和探测数量
+	RateLimit   int64  `json:"rate_limit"`    // 下载限速(字节/秒)，0为不限速
+}
+
+// DriverSettings 驱动备份配置
+type DriverSettings struct {
+	BackupDir string `json:"backup_dir,omitempty"` // 驱动备份目录，为空时使用工作目录下的 driver_backups
+}
+
+// ProxyConfig 代理配置，URL为空时使用 HTTP_PROXY/HTTPS_PROXY/NO_PROXY 环境变量
+// 代理地址中只有用户名时，密码从密钥库的 proxy/password 读取
+type ProxyConfig struct {
+	URL     string `json:"url,omitempty"`      // http://、https:// 或 socks5:// 代理地址
+	NoProxy string `json:"no_proxy,omitempty"` // 不走代理的主机，逗号分隔
+}
+
+// TLSConfig TLS配置
+type TLSConfig struct {
+	CAFile     string   `json:"ca_file,omitempty"`     // 额外信任的CA证书(PEM)，在系统证书之外
+	PinnedKeys []string `json:"pinned_keys,omitempty"` // API主机证书链中公钥的SHA-256(base64)，设置后只接受匹配的证书
+}
+
+// InstallDefaults 安装选项的默认值，只在选项未设置时使用；不保存密码等敏感信息
+type InstallDefaults struct {
+	Language      string `json:"language,omitempty"`
+	SSHPort       int    `json:"ssh_port,omitempty"`
+	RDPPort       int    `json:"rdp_port,omitempty"`
+	WebPort       int    `json:"web_port,omitempty"`
+	Minimal       bool   `json:"minimal,omitempty"`
+	AllowPing     bool   `json:"allow_ping,omitempty"`
+	VerifyTimeout int    `json:"verify_timeout,omitempty"`
+}
+
+// DefaultConfig 默认配置
+func DefaultConfig() *Config {
+	return &Config{
+		Version: ConfigVersion,
+		API: APIConfig{
+			Timeout: 30,
+		},
+		Catalog: CatalogSettings{
+			CacheTTL: int(defaultCatalogCacheTTL.Seconds()),
+		},
+		Download: DownloadConfig{
+			Concurrency: 4,
+		},
+		LogLevel:    "info",
+		Theme:       ThemeLight,
+		Diagnostics: DefaultDiagnosticsPolicy(),
+	}
+}
+
+// DefaultConfigPath 默认的配置文件路径：环境变量 SYSTEMREINSTALLER_CONFIG 指定的路径，否则位于当前用户的配置目录
+func DefaultConfigPath() string {
+	if path := os.Getenv(configPathEnv); path != "" {
+		return path
+	}
+	return filepath.Join(UserConfigDir(), configFileName)
+}
+
+// Clone 深拷贝配置
+func (c *Config) Clone() *Config {
+	cloned := *c
+	cloned.Catalog.Sources = append([]CatalogConfig(nil), c.Catalog.Sources...)
+	cloned.Diagnostics.ExtraPatterns = append([]string(nil), c.Diagnostics.ExtraPatterns...)
+	cloned.TLS.PinnedKeys = append([]string(nil), c.TLS.PinnedKeys...)
+	return &cloned
+}
+
+// ConfigFieldError 单个配置项的错误
+type ConfigFieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ConfigErrors 配置校验失败的所有配置项
+type ConfigErrors []ConfigFieldError
+
+func (e ConfigErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldErr := range e {
+		messages[i] = fieldErr.Field + ": " + fieldErr.Message
+	}
+	return "配置无效: " + strings.Join(messages, "; ")
+}
+
+// add 记录一个配置项错误
+func (e *ConfigErrors) add(field, format string, args ...interface{}) {
+	*e = append(*e, ConfigFieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Validate 校验配置，返回的错误为 ConfigErrors，包含所有无效的配置项
+func (c *Config) Validate() error {
+	var errs ConfigErrors
+
+	if c.Version != ConfigVersion {
+		errs.add("version", "不支持的配置版本 %d", c.Version)
+	}
+
+	if c.API.BaseURL != "" {
+		if _, err := normalizeBaseURL(c.API.BaseURL); err != nil {
+			errs.add("api.base_url", "%v", err)
+		}
+	}
+	if c.API.ScriptUpdateURL != "" {
+		if _, err := normalizeBaseURL(c.API.ScriptUpdateURL); err != nil {
+			errs.add("api.script_update_url", "%v", err)
+		}
+	}
+	if c.API.Timeout < 0 || c.API.Timeout > maxAPITimeout {
+		errs.add("api.timeout", "应在0到%d秒之间", maxAPITimeout)
+	}
+
+	for i, source := range c.Catalog.Sources {
+		field := fmt.Sprintf("catalog.sources[%d]", i)
+		switch source.Type {
+		case "", CatalogTypeAPI:
+			if source.URL != "" {
+				if _, err := normalizeBaseURL(source.URL); err != nil {
+					errs.add(field+".url", "%v", err)
+				}
+			}
+		case CatalogTypeLocal:
+			if source.Path == "" {
+				errs.add(field+".path", "本地目录来源缺少路径")
+			}
+		default:
+			errs.add(field+".type", "未知的目录来源类型 %q", source.Type)
+		}
+	}
+	if c.Catalog.CacheTTL < 0 {
+		errs.add("catalog.cache_ttl", "不能为负数")
+	}
+
+	if c.Download.Concurrency < 1 || c.Download.Concurrency > maxDownloadParallel {
+		errs.add("download.concurrency", "应在1到%d之间", maxDownloadParallel)
+	}
+	if c.Download.RateLimit < 0 {
+		errs.add("download.rate_limit", "不能为负数")
+	}
+
+	if c.Proxy.URL != "" {
+		parsed, err := url.Parse(c.Proxy.URL)
+		if err != nil || parsed.Host == "" {
+			errs.add("proxy.url", "代理地址无效: %q", c.Proxy.URL)
+		} else if parsed.Scheme != "http" && parsed.Scheme != "https" && parsed.Scheme != "socks5" {
+			errs.add("proxy.url", "不支持的代理类型 %q", parsed.Scheme)
+		}
+	}
+	if c.TLS.CAFile != "" {
+		if _, err := os.Stat(c.TLS.CAFile); err != nil {
+			errs.add("tls.ca_file", "无法读取CA证书: %v", err)
+		}
+	}
+	for _, pin := range c.TLS.PinnedKeys {
+		if _, err := parsePinnedKey(pin); err != nil {
+			errs.add("tls.pinned_keys", "%v", err)
+		}
+	}
+	if host := NetworkOptionsFromConfig(c, nil).PinnedHost; host != "" && net.ParseIP(host) != nil {
+		errs.add("tls.pinned_keys", "公钥固定需要API地址使用域名: %s", host)
+	}
+
+	if _, err := utils.ParseLevel(c.LogLevel); err != nil {
+		errs.add("log_level", "%v", err)
+	}
+	if c.Theme != ThemeLight && c.Theme != ThemeDark && c.Theme != ThemeAuto {
+		errs.add("theme", "未知的主题 %q", c.Theme)
+	}
+
+	ports := []struct {
+		field string
+		port  int
+	}{
+		{"install.ssh_port", c.Install.SSHPort},
+		{"install.rdp_port", c.Install.RDPPort},
+		{"install.web_port", c.Install.WebPort},
+	}
+	for _, p := range ports {
+		if p.port < 0 || p.port > 65535 {
+			errs.add(p.field, "端口应在1到65535之间，0为使用默认端口")
+		}
+	}
+	if c.Install.VerifyTimeout < 0 {
+		errs.add("install.verify_timeout", "不能为负数")
+	}
+
+	for i, pattern := range c.Diagnostics.ExtraPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			errs.add(fmt.Sprintf("diagnostics.extra_patterns[%d]", i), "正则表达式无效: %v", err)
+		}
+	}
+	if c.Diagnostics.MaxSize < 0 || c.Diagnostics.ChunkSize < 0 {
+		errs.add("diagnostics", "大小限制不能为负数")
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ApplyInstallDefaults 用配置的默认值填充未设置的安装选项
+func (c *Config) ApplyInstallDefaults(options *InstallOptions) {
+	defaults := c.Install
+	if options.Language == "" {
+		options.Language = defaults.Language
+	}
+	if options.SSHPort == 0 {
+		options.SSHPort = defaults.SSHPort
+	}
+	if options.RDPPort == 0 {
+		options.RDPPort = defaults.RDPPort
+	}
+	if options.WebPort == 0 {
+		options.WebPort = defaults.WebPort
+	}
+	if options.VerifyTimeout == 0 {
+		options.VerifyTimeout = defaults.VerifyTimeout
+	}
+	options.Minimal = options.Minimal || defaults.Minimal
+	options.AllowPing = options.AllowPing || defaults.AllowPing
+}
+
+// configMigrations 第i项将版本i的配置迁移到版本i+1，直接修改解析后的JSON对象
+var configMigrations = []func(raw map[string]interface{}) error{
+	migrateConfigV0,
+}
+
+// migrateConfigV0 旧版本没有 version 字段，所有配置项都在顶层
+func migrateConfigV0(raw map[string]interface{}) error {
+	api := map[string]interface{}{}
+	if value, ok := raw["api_base_url"]; ok {
+		api["base_url"] = value
+		delete(raw, "api_base_url")
+	}
+	if value, ok := raw["script_update_url"]; ok {
+		api["script_update_url"] = value
+		delete(raw, "script_update_url")
+	}
+	if len(api) > 0 {
+		raw["api"] = api
+	}
+
+	catalog := map[string]interface{}{}
+	if value, ok := raw["catalogs"]; ok {
+		catalog["sources"] = value
+		delete(raw, "catalogs")
+	}
+	if value, ok := raw["catalog_cache_ttl"]; ok {
+		catalog["cache_ttl"] = value
+		delete(raw, "catalog_cache_ttl")
+	}
+	if len(catalog) > 0 {
+		raw["catalog"] = catalog
+	}
+	return nil
+}
+
+// migrateConfig 将配置迁移到当前版本，返回原来的版本
+func migrateConfig(raw map[string]interface{}) (int, error) {
+	version := 0
+	if value, ok := raw["version"]; ok {
+		number, ok := value.(float64)
+		if !ok || number < 0 || number != float64(int(number)) {
+			return 0, fmt.Errorf("配置版本格式错误: %v", value)
+		}
+		version = int(number)
+	}
+	if version > ConfigVersion {
+		return version, fmt.Errorf("配置文件版本(%d)高于程序支持的版本(%d)，请升级程序", version, ConfigVersion)
+	}
+
+	for v := version; v < ConfigVersion; v++ {
+		if err := configMigrations[v](raw); err != nil {
+			return version, fmt.Errorf("配置从版本%d迁移失败: %v", v, err)
+		}
+		raw["version"] = v + 1
+	}
+	return version, nil
+}
+
+// configFile 解析后的配置文件
+type configFile struct {
+	config   *Config
+	keys     map[string]bool // 文件中设置了的配置项
+	migrated bool            // 文件是旧版本格式
+	err      error           // 文件无法解析时的错误，此时 config 为默认配置
+}
+
+// parseConfigFile 解析配置，旧版本的配置先迁移到当前版本，未设置的项使用默认值
+func parseConfigFile(data []byte) (*configFile, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("配置文件格式错误: %v", err)
+	}
+	if raw == nil {
+		raw = map[string]interface{}{}
+	}
+	from, err := migrateConfig(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err = json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	config := DefaultConfig()
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("配置文件格式错误: %v", err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &configFile{config: config, keys: fileConfigKeys(raw), migrated: from != ConfigVersion}, nil
+}
+
+// readConfigFile 读取配置文件，文件不存在时返回默认配置
+func readConfigFile(path string) (*configFile, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &configFile{config: DefaultConfig(), keys: map[string]bool{}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取配置失败: %v", err)
+	}
+	return parseConfigFile(data)
+}
+
+// ParseConfig 解析配置，返回的 migrated 表示配置经过了迁移，调用方应保存新格式
+func ParseConfig(data []byte) (config *Config, migrated bool, err error) {
+	file, err := parseConfigFile(data)
+	if err != nil {
+		return nil, false, err
+	}
+	return file.config, file.migrated, nil
+}
+
+// LoadConfig 读取配置文件，文件不存在时返回默认配置
+func LoadConfig(path string) (*Config, bool, error) {
+	file, err := readConfigFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+	return file.config, file.migrated, nil
+}
+
+// SaveConfig 校验并原子地保存配置
+func SaveConfig(path string, config *Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("创建配置目录失败: %v", err)
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	// 代理地址可能包含账号密码，仅当前用户可读写
+	return writeFileAtomic(path, data, 0600)
+}
@@ -0,0 +1,274 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// configEnvPrefix 配置项环境变量的前缀，如 api.base_url 对应 SYSTEMREINSTALLER_API_BASE_URL
+const configEnvPrefix = "SYSTEMREINSTALLER_"
+
+// configPathEnv 指定配置文件路径的环境变量
+const configPathEnv = "SYSTEMREINSTALLER_CONFIG"
+
+// configEnvAliases 旧版本使用的环境变量
+var configEnvAliases = map[string]string{
+	"SYSTEMREINSTALLER_API_URL": "api.base_url",
+}
+
+// 配置值的来源，按优先级从低到高
+const (
+	ConfigSourceDefault = "default"
+	ConfigSourceFile    = "file"
+	ConfigSourceEnv     = "env"
+	ConfigSourceCLI     = "cli"
+)
+
+// ConfigOverride 环境变量或命令行对单个配置项的覆盖
+type ConfigOverride struct {
+	Key    string // 配置项，如 api.base_url
+	Value  string // 列表用逗号分隔，对象数组用JSON
+	Source string // env 或 cli
+	Origin string // 环境变量名或命令行参数
+}
+
+// ConfigOptions 启动时指定的配置文件和命令行覆盖
+type ConfigOptions struct {
+	Path      string // 为空时依次使用环境变量 SYSTEMREINSTALLER_CONFIG 和用户配置目录
+	Overrides []ConfigOverride
+}
+
+// ConfigValueSource 配置项的实际来源
+type ConfigValueSource struct {
+	Source string `json:"source"`
+	Origin string `json:"origin,omitempty"`
+}
+
+// configKey 配置项名称和在 Config 中的字段位置
+type configKey struct {
+	name  string
+	index []int
+}
+
+// configKeys 按JSON标签列出所有配置项，结构体展开为其字段，其余类型为一个配置项
+func configKeys() []configKey {
+	var keys []configKey
+	var walk func(t reflect.Type, prefix string, index []int)
+	walk = func(t reflect.Type, prefix string, index []int) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "" || name == "-" || name == "version" {
+				continue
+			}
+			fieldIndex := append(append([]int{}, index...), i)
+			if field.Type.Kind() == reflect.Struct {
+				walk(field.Type, prefix+name+".", fieldIndex)
+				continue
+			}
+			keys = append(keys, configKey{name: prefix + name, index: fieldIndex})
+		}
+	}
+	walk(reflect.TypeOf(Config{}), "", nil)
+	return keys
+}
+
+// findConfigKey 按名称查找配置项
+func findConfigKey(name string) (configKey, bool) {
+	for _, key := range configKeys() {
+		if key.name == name {
+			return key, true
+		}
+	}
+	return configKey{}, false
+}
+
+// configEnvName 配置项对应的环境变量名
+func configEnvName(key string) string {
+	return configEnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// EnvConfigOverrides 从环境变量中读取配置覆盖，environ 格式同 os.Environ()
+// 别名在前，同一配置项的正式变量名优先
+func EnvConfigOverrides(environ []string) []ConfigOverride {
+	values := make(map[string]string)
+	for _, entry := range environ {
+		if name, value, ok := strings.Cut(entry, "="); ok && strings.HasPrefix(name, configEnvPrefix) {
+			values[name] = value
+		}
+	}
+
+	var overrides []ConfigOverride
+	aliases := make([]string, 0, len(configEnvAliases))
+	for name := range configEnvAliases {
+		aliases = append(aliases, name)
+	}
+	sort.Strings(aliases)
+	for _, name := range aliases {
+		if value, ok := values[name]; ok && value != "" {
+			overrides = append(overrides, ConfigOverride{Key: configEnvAliases[name], Value: value, Source: ConfigSourceEnv, Origin: name})
+		}
+	}
+	for _, key := range configKeys() {
+		name := configEnvName(key.name)
+		if value, ok := values[name]; ok && value != "" {
+			overrides = append(overrides, ConfigOverride{Key: key.name, Value: value, Source: ConfigSourceEnv, Origin: name})
+		}
+	}
+	return overrides
+}
+
+// ParseConfigOverride 解析命令行的 key=value
+func ParseConfigOverride(arg string) (ConfigOverride, error) {
+	key, value, ok := strings.Cut(arg, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return ConfigOverride{}, fmt.Errorf("配置覆盖格式应为 key=value: %q", arg)
+	}
+	if _, ok := findConfigKey(key); !ok {
+		return ConfigOverride{}, fmt.Errorf("未知的配置项: %s", key)
+	}
+	return ConfigOverride{Key: key, Value: value, Source: ConfigSourceCLI, Origin: "-set " + arg}, nil
+}
+
+// setConfigValue 将字符串形式的值写入配置项
+func setConfigValue(config *Config, name, value string) error {
+	key, ok := findConfigKey(name)
+	if !ok {
+		return fmt.Errorf("未知的配置项: %s", name)
+	}
+	field := reflect.ValueOf(config).Elem().FieldByIndex(key.index)
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int, reflect.Int64:
+		number, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s 应为整数: %q", name, value)
+		}
+		field.SetInt(number)
+	case reflect.Bool:
+		flag, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%s 应为 true 或 false: %q", name, value)
+		}
+		field.SetBool(flag)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() == reflect.String && !strings.HasPrefix(strings.TrimSpace(value), "[") {
+			items := []string{}
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			field.Set(reflect.ValueOf(items))
+			return nil
+		}
+		if err := json.Unmarshal([]byte(value), field.Addr().Interface()); err != nil {
+			return fmt.Errorf("%s 应为JSON数组: %v", name, err)
+		}
+	default:
+		return fmt.Errorf("配置项 %s 不支持覆盖", name)
+	}
+	return nil
+}
+
+// fileConfigKeys 配置文件中设置了的配置项
+func fileConfigKeys(raw map[string]interface{}) map[string]bool {
+	present := make(map[string]bool)
+	for _, key := range configKeys() {
+		var node interface{} = raw
+		for _, part := range strings.Split(key.name, ".") {
+			object, ok := node.(map[string]interface{})
+			if !ok {
+				node = nil
+				break
+			}
+			if node, ok = object[part]; !ok {
+				break
+			}
+		}
+		if node != nil {
+			present[key.name] = true
+		}
+	}
+	return present
+}
+
+// ResolvedConfig 合并 默认值 → 配置文件 → 环境变量 → 命令行 后的配置
+type ResolvedConfig struct {
+	Config  *Config
+	Path    string                       // 配置文件路径
+	Sources map[string]ConfigValueSource // 每个配置项的来源
+}
+
+// resolveConfig 在配置文件的基础上依次应用覆盖，后面的覆盖优先
+func resolveConfig(file *Config, fileKeys map[string]bool, path string, overrides []ConfigOverride) (*ResolvedConfig, error) {
+	resolved := &ResolvedConfig{
+		Config:  file.Clone(),
+		Path:    path,
+		Sources: make(map[string]ConfigValueSource),
+	}
+	for _, key := range configKeys() {
+		if fileKeys[key.name] {
+			resolved.Sources[key.name] = ConfigValueSource{Source: ConfigSourceFile, Origin: path}
+		} else {
+			resolved.Sources[key.name] = ConfigValueSource{Source: ConfigSourceDefault}
+		}
+	}
+
+	for _, override := range overrides {
+		if err := setConfigValue(resolved.Config, override.Key, override.Value); err != nil {
+			return nil, fmt.Errorf("%s: %v", override.Origin, err)
+		}
+		resolved.Sources[override.Key] = ConfigValueSource{Source: override.Source, Origin: override.Origin}
+	}
+	if err := resolved.Config.Validate(); err != nil {
+		return nil, err
+	}
+	return resolved, nil
+}
+
+// defaultResolvedConfig 只有默认值的配置
+func defaultResolvedConfig() *ResolvedConfig {
+	resolved, err := resolveConfig(DefaultConfig(), nil, "", nil)
+	if err != nil {
+		panic(err) // 默认配置必须有效
+	}
+	return resolved
+}
+
+// Print 按配置项输出实际值和来源，代理地址中的密码会隐藏
+func (r *ResolvedConfig) Print(w io.Writer) error {
+	config := r.Config.Clone()
+	if proxy, err := url.Parse(config.Proxy.URL); err == nil && proxy.User != nil {
+		config.Proxy.URL = proxy.Redacted()
+	}
+	values := reflect.ValueOf(config).Elem()
+
+	if _, err := fmt.Fprintf(w, "# 配置文件: %s\n", r.Path); err != nil {
+		return err
+	}
+	for _, key := range configKeys() {
+		value, err := json.Marshal(values.FieldByIndex(key.index).Interface())
+		if err != nil {
+			return err
+		}
+		source := r.Sources[key.name]
+		origin := source.Source
+		if source.Origin != "" && source.Source != ConfigSourceFile {
+			origin += " " + source.Origin
+		}
+		if _, err := fmt.Fprintf(w, "%-32s = %-40s # %s\n", key.name, value, origin); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// appConfigDirName 用户配置目录下的程序目录名
+const appConfigDirName = "SystemReinstaller"
+
+// API凭据在密钥库中的键
+const (
+	secretKeyAPIKey        = "api/key"
+	secretKeySigningSecret = "api/signing_secret"
+)
+
+// Credentials API认证信息
+type Credentials struct {
+	APIKey        string `json:"api_key"`
+	SigningSecret string `json:"signing_secret,omitempty"` // 为空时不对请求签名
+}
+
+// UserConfigDir 当前用户的程序配置目录
+func UserConfigDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		// 获取失败时退回到当前目录
+		return appConfigDirName
+	}
+	return filepath.Join(dir, appConfigDirName)
+}
+
+// LegacyCredentialsPath 旧版本保存明文凭据的文件路径
+func LegacyCredentialsPath() string {
+	return filepath.Join(UserConfigDir(), "credentials.json")
+}
+
+// LoadCredentials 从密钥库读取API凭据，未保存时返回空凭据
+func LoadCredentials(store SecretStore) (*Credentials, error) {
+	creds := &Credentials{}
+	for key, target := range map[string]*string{
+		secretKeyAPIKey:        &creds.APIKey,
+		secretKeySigningSecret: &creds.SigningSecret,
+	} {
+		value, err := store.Get(key)
+		if errors.Is(err, ErrSecretNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取凭据失败: %w", err)
+		}
+		*target = value
+	}
+	return creds, nil
+}
+
+// SaveCredentials 将API凭据保存到密钥库，空字段会删除对应的键
+func SaveCredentials(store SecretStore, creds *Credentials) error {
+	if err := store.Set(secretKeyAPIKey, creds.APIKey); err != nil {
+		return fmt.Errorf("保存凭据失败: %w", err)
+	}
+	if err := store.Set(secretKeySigningSecret, creds.SigningSecret); err != nil {
+		return fmt.Errorf("保存凭据失败: %w", err)
+	}
+	return nil
+}
+
+// LoadLegacyCredentials 读取旧版本的明文凭据文件，文件不存在时返回nil
+func LoadLegacyCredentials(path string) (*Credentials, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取凭据失败: %v", err)
+	}
+
+	var creds Credentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("凭据文件格式错误: %v", err)
+	}
+	return &creds, nil
+}
+
+// MigrateLegacyCredentials 将旧版本的明文凭据移入密钥库并删除明文文件
+// 密钥库中已有API密钥时不覆盖。返回是否进行了迁移
+func MigrateLegacyCredentials(store SecretStore, path string) (bool, error) {
+	legacy, err := LoadLegacyCredentials(path)
+	if err != nil || legacy == nil {
+		return false, err
+	}
+	current, err := LoadCredentials(store)
+	if err != nil {
+		return false, err
+	}
+	if current.APIKey == "" && current.SigningSecret == "" {
+		if err := SaveCredentials(store, legacy); err != nil {
+			return false, err
+		}
+	}
+	if err := os.Remove(path); err != nil {
+		return false, fmt.Errorf("删除明文凭据文件失败: %v", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,405 @@
+package core
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 诊断上传的默认限制
+const (
+	defaultDiagnosticsMaxSize   = 50 << 20
+	defaultDiagnosticsChunkSize = 1 << 20
+	diagnosticsResumeWindow     = 24 * time.Hour
+	diagnosticsDirName          = "diagnostics"
+	diagnosticsStateFile        = "pending.json"
+)
+
+// ErrDiagnosticsConsent 用户没有同意上传诊断信息
+var ErrDiagnosticsConsent = errors.New("未同意上传诊断信息，请先在设置中开启")
+
+// 诊断包中需要脱敏的内容
+var (
+	ipv4Pattern   = regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b`)
+	ipv6Pattern   = regexp.MustCompile(`(?i)\b(?:[0-9a-f]{1,4}:){3,7}[0-9a-f]{1,4}\b|\b(?:[0-9a-f]{1,4}:){1,6}:(?:[0-9a-f]{1,4})?\b`)
+	secretPattern = regexp.MustCompile(`(?i)((?:password|passwd|secret|token|api[_-]?key|authorization)["']?\s*[:=]\s*["']?)([^\s"',]+)`)
+	bearerPattern = regexp.MustCompile(`(?i)\b(bearer)\s+[A-Za-z0-9._~+/=-]+`)
+)
+
+// DiagnosticsPolicy 诊断上传策略，来自配置的 diagnostics 项
+type DiagnosticsPolicy struct {
+	Consent         bool     `json:"consent"`                  // 用户同意上传，默认不上传
+	RedactIPs       bool     `json:"redact_ips"`               // 隐藏IP地址
+	RedactHostnames bool     `json:"redact_hostnames"`         // 隐藏本机主机名和用户名
+	ExtraPatterns   []string `json:"extra_patterns,omitempty"` // 额外需要隐藏的正则表达式
+	MaxSize         int64    `json:"max_size"`                 // 诊断包大小上限（字节）
+	ChunkSize       int64    `json:"chunk_size"`               // 分块大小（字节）
+}
+
+// DefaultDiagnosticsPolicy 默认策略：不上传，上传时隐藏IP和主机名
+func DefaultDiagnosticsPolicy() DiagnosticsPolicy {
+	return DiagnosticsPolicy{
+		RedactIPs:       true,
+		RedactHostnames: true,
+		MaxSize:         defaultDiagnosticsMaxSize,
+		ChunkSize:       defaultDiagnosticsChunkSize,
+	}
+}
+
+// DiagnosticsUploadResult 诊断上传结果
+type DiagnosticsUploadResult struct {
+	TicketID string `json:"ticket_id"`
+	UploadID string `json:"upload_id"`
+	Size     int64  `json:"size"`
+	SHA256   string `json:"sha256"`
+	Chunks   int    `json:"chunks"`
+	Resumed  bool   `json:"resumed"` // 续传了上次未完成的上传
+}
+
+// diagnosticsUploadState 未完成的上传，用于续传
+type diagnosticsUploadState struct {
+	UploadID  string    `json:"upload_id"`
+	Path      string    `json:"path"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+	ChunkSize int64     `json:"chunk_size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// diagnosticsSession 服务端的上传会话
+type diagnosticsSession struct {
+	UploadID string `json:"upload_id"`
+	Received int64  `json:"received"` // 已收到的字节数，按分块对齐
+	TicketID string `json:"ticket_id,omitempty"`
+}
+
+// diagnosticsPolicy 读取配置中的诊断上传策略，未设置的限制使用默认值
+func (si *SystemInstaller) diagnosticsPolicy() DiagnosticsPolicy {
+	policy := si.Config().Diagnostics
+	if policy.MaxSize <= 0 {
+		policy.MaxSize = defaultDiagnosticsMaxSize
+	}
+	if policy.ChunkSize <= 0 {
+		policy.ChunkSize = defaultDiagnosticsChunkSize
+	}
+	return policy
+}
+
+// newDiagnosticsRedactor 按策略创建诊断包的脱敏函数
+func newDiagnosticsRedactor(policy DiagnosticsPolicy, secrets ...string) (func(string) string, error) {
+	extra := make([]*regexp.Regexp, 0, len(policy.ExtraPatterns))
+	for _, pattern := range policy.ExtraPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("脱敏规则无效 %q: %v", pattern, err)
+		}
+		extra = append(extra, re)
+	}
+
+	if policy.RedactHostnames {
+		if hostname, err := os.Hostname(); err == nil && len(hostname) > 2 {
+			secrets = append(secrets, hostname)
+		}
+		if user := currentOSUser(); len(user) > 2 {
+			secrets = append(secrets, user)
+		}
+	}
+	redactor := NewRedactor(secrets...)
+
+	return func(text string) string {
+		text = redactor.Redact(text)
+		text = secretPattern.ReplaceAllString(text, "${1}"+redactedMask)
+		text = bearerPattern.ReplaceAllString(text, "${1} "+redactedMask)
+		if policy.RedactIPs {
+			text = ipv4Pattern.ReplaceAllString(text, "x.x.x.x")
+			text = ipv6Pattern.ReplaceAllString(text, "x:x::x")
+		}
+		for _, re := range extra {
+			text = re.ReplaceAllString(text, redactedMask)
+		}
+		return text
+	}, nil
+}
+
+// redactBundle 读取导出的zip，逐个文件脱敏后写入新的zip，gzip压缩的旧日志解压后处理
+func redactBundle(source, target string, redact func(string) string) error {
+	reader, err := zip.OpenReader(source)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	writer := zip.NewWriter(file)
+
+	for _, entry := range reader.File {
+		if err := redactBundleEntry(writer, entry, redact); err != nil {
+			writer.Close()
+			file.Close()
+			return fmt.Errorf("%s: %v", entry.Name, err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// redactBundleEntry 脱敏单个文件
+func redactBundleEntry(writer *zip.Writer, entry *zip.File, redact func(string) string) error {
+	rc, err := entry.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	name := entry.Name
+	var content io.Reader = rc
+	if strings.HasSuffix(name, ".gz") {
+		gz, err := gzip.NewReader(rc)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		content = gz
+		name = strings.TrimSuffix(name, ".gz")
+	}
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return err
+	}
+
+	w, err := writer.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: entry.Modified})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, redact(string(data)))
+	return err
+}
+
+// prepareDiagnostics 导出日志并按策略脱敏，返回诊断包路径
+func (si *SystemInstaller) prepareDiagnostics(policy DiagnosticsPolicy, dir string) (string, error) {
+	ac := si.apiClient
+	ac.authMutex.RLock()
+	secrets := []string{ac.apiKey, ac.signingSecret}
+	ac.authMutex.RUnlock()
+
+	redact, err := newDiagnosticsRedactor(policy, secrets...)
+	if err != nil {
+		return "", err
+	}
+
+	rawPath := filepath.Join(dir, "export.zip")
+	if _, err := si.ExportLogs(rawPath); err != nil {
+		return "", err
+	}
+	defer os.Remove(rawPath)
+
+	bundlePath := filepath.Join(dir, "diagnostics-"+time.Now().Format("20060102-150405")+".zip")
+	if err := redactBundle(rawPath, bundlePath, redact); err != nil {
+		os.Remove(bundlePath)
+		return "", fmt.Errorf("诊断信息脱敏失败: %v", err)
+	}
+	return bundlePath, nil
+}
+
+// UploadDiagnostics 收集诊断信息，脱敏后分块上传，返回工单号
+//
+// 只有配置中 diagnostics.consent 为 true 时才会上传。上传中断后再次调用会
+// 在24小时内续传同一个诊断包。
+func (si *SystemInstaller) UploadDiagnostics(ctx context.Context) (*DiagnosticsUploadResult, error) {
+	policy := si.diagnosticsPolicy()
+	if !policy.Consent {
+		return nil, ErrDiagnosticsConsent
+	}
+
+	dir := filepath.Join(si.workingDir, "cache", diagnosticsDirName)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("创建诊断目录失败: %v", err)
+	}
+	statePath := filepath.Join(dir, diagnosticsStateFile)
+
+	state, resumed := loadDiagnosticsState(statePath)
+	if state == nil {
+		bundlePath, err := si.prepareDiagnostics(policy, dir)
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(bundlePath)
+		if err != nil {
+			return nil, err
+		}
+		if info.Size() > policy.MaxSize {
+			os.Remove(bundlePath)
+			return nil, fmt.Errorf("诊断包过大(%d字节)，上限为%d字节", info.Size(), policy.MaxSize)
+		}
+		hash, err := fileSHA256(bundlePath)
+		if err != nil {
+			return nil, err
+		}
+		state = &diagnosticsUploadState{
+			Path:      bundlePath,
+			Size:      info.Size(),
+			SHA256:    hash,
+			ChunkSize: policy.ChunkSize,
+			CreatedAt: time.Now(),
+		}
+	}
+
+	result, err := si.apiClient.uploadDiagnostics(ctx, state, func() error {
+		data, err := json.Marshal(state)
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(statePath, data, 0600)
+	})
+	if err != nil {
+		si.logger.Warning("诊断上传未完成", "upload_id", state.UploadID, "error", err)
+		return nil, err
+	}
+
+	os.Remove(statePath)
+	os.Remove(state.Path)
+	result.Resumed = resumed
+	si.logger.Info("诊断信息已上传", "ticket_id", result.TicketID, "bytes", result.Size)
+	return result, nil
+}
+
+// loadDiagnosticsState 读取未完成的上传，过期或文件已变化时丢弃
+func loadDiagnosticsState(path string) (*diagnosticsUploadState, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var state diagnosticsUploadState
+	if err := json.Unmarshal(data, &state); err != nil || state.UploadID == "" {
+		os.Remove(path)
+		return nil, false
+	}
+	if time.Since(state.CreatedAt) > diagnosticsResumeWindow {
+		os.Remove(state.Path)
+		os.Remove(path)
+		return nil, false
+	}
+	if hash, err := fileSHA256(state.Path); err != nil || hash != state.SHA256 {
+		os.Remove(path)
+		return nil, false
+	}
+	return &state, true
+}
+
+// uploadDiagnostics 按分块上传诊断包
+//
+// 协议：POST /diagnostics/ 创建会话；GET /diagnostics/<id>/ 查询已收到的字节数；
+// PUT /diagnostics/<id>/chunks/<序号>/ 上传分块（幂等，可重试）；
+// POST /diagnostics/<id>/complete/ 校验整体SHA-256并返回工单号。
+func (ac *APIClient) uploadDiagnostics(ctx context.Context, state *diagnosticsUploadState, saveState func() error) (*DiagnosticsUploadResult, error) {
+	base := ac.BaseURL() + "/diagnostics/"
+	var offset int64
+
+	if state.UploadID != "" {
+		var session diagnosticsSession
+		err := ac.doJSON(ctx, http.MethodGet, base+state.UploadID+"/", nil, &session)
+		var apiErr *APIError
+		switch {
+		case err == nil:
+			offset = session.Received
+		case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound:
+			// 会话已过期，重新创建
+			state.UploadID = ""
+		default:
+			return nil, err
+		}
+	}
+
+	if state.UploadID == "" {
+		var session diagnosticsSession
+		err := ac.doJSON(ctx, http.MethodPost, base, map[string]interface{}{
+			"size":             state.Size,
+			"sha256":           state.SHA256,
+			"chunk_size":       state.ChunkSize,
+			"app_version":      AppVersion,
+			"host_fingerprint": HostFingerprint(),
+		}, &session)
+		if err != nil {
+			return nil, err
+		}
+		if session.UploadID == "" {
+			return nil, fmt.Errorf("服务器未返回上传ID")
+		}
+		state.UploadID = session.UploadID
+		offset = 0
+	}
+	if err := saveState(); err != nil {
+		return nil, fmt.Errorf("保存上传状态失败: %v", err)
+	}
+
+	file, err := os.Open(state.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	chunks := int((state.Size + state.ChunkSize - 1) / state.ChunkSize)
+	buffer := make([]byte, state.ChunkSize)
+	for index := int(offset / state.ChunkSize); index < chunks; index++ {
+		n, err := file.ReadAt(buffer, int64(index)*state.ChunkSize)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		chunk := buffer[:n]
+		headers := map[string]string{
+			"Accept":         "application/json",
+			"Content-Type":   "application/octet-stream",
+			"X-Chunk-SHA256": sha256Hex(chunk),
+		}
+		url := base + state.UploadID + "/chunks/" + strconv.Itoa(index) + "/"
+		if err := ac.doRequest(ctx, http.MethodPut, url, bytes.Clone(chunk), headers, nil); err != nil {
+			return nil, fmt.Errorf("上传第%d/%d块失败: %w", index+1, chunks, err)
+		}
+	}
+
+	var session diagnosticsSession
+	if err := ac.doJSON(ctx, http.MethodPost, base+state.UploadID+"/complete/", map[string]interface{}{
+		"sha256": state.SHA256,
+	}, &session); err != nil {
+		return nil, err
+	}
+	if session.TicketID == "" {
+		return nil, fmt.Errorf("服务器未返回工单号")
+	}
+
+	return &DiagnosticsUploadResult{
+		TicketID: session.TicketID,
+		UploadID: state.UploadID,
+		Size:     state.Size,
+		SHA256:   state.SHA256,
+		Chunks:   chunks,
+	}, nil
+}
+
+// CancelDiagnosticsUpload 放弃未完成的诊断上传并删除本地诊断包
+func (si *SystemInstaller) CancelDiagnosticsUpload() error {
+	dir := filepath.Join(si.workingDir, "cache", diagnosticsDirName)
+	if err := os.RemoveAll(dir); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
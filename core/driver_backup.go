@@ -0,0 +1,701 @@
+package core
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// driverManifestName 备份归档中清单文件的名称
+const driverManifestName = "manifest.json"
+
+// driverBackupVersion 备份清单的格式版本
+const driverBackupVersion = 1
+
+// 备份归档格式
+const (
+	DriverBackupZip    = "zip"
+	DriverBackupTarZst = "tar.zst"
+	DriverBackup7z     = "7z"
+)
+
+// sevenZipProgressPattern 7z -bsp1 输出中的百分比
+var sevenZipProgressPattern = regexp.MustCompile(`(\d{1,3})%`)
+
+// linuxFirmwareSuffixes 固件文件可能的压缩后缀
+var linuxFirmwareSuffixes = []string{"", ".zst", ".xz"}
+
+// DriverBackupOptions 驱动备份选项
+type DriverBackupOptions struct {
+	TargetDir string   `json:"target_dir"`
+	Format    string   `json:"format"`            // zip（默认）、tar.zst 或 7z
+	Drivers   []string `json:"drivers,omitempty"` // 只备份这些驱动（发布名、模块名或名称），为空时备份全部第三方驱动
+	Firmware  bool     `json:"firmware"`          // Linux 下同时备份随内核发布的模块和发行版软件包提供的固件
+}
+
+// DriverBackupProgress 备份和恢复的进度
+type DriverBackupProgress struct {
+	Stage      string `json:"stage"` // 备份: export, manifest, archive, done；恢复: extract, install, done
+	Percentage int    `json:"percentage"`
+	Message    string `json:"message"`
+}
+
+// DriverBackupFile 备份中的一个文件
+type DriverBackupFile struct {
+	Path   string `json:"path"` // 归档内的相对路径，使用 / 分隔
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// DriverBackupEntry 清单中的一个驱动及其文件
+type DriverBackupEntry struct {
+	DriverInfo
+	Files []DriverBackupFile `json:"files"`
+}
+
+// DriverFirmwareEntry 清单中的一个固件文件
+type DriverFirmwareEntry struct {
+	DriverBackupFile
+	Name      string   `json:"name"`                 // 相对于 /lib/firmware 的路径
+	Module    string   `json:"module"`               // 使用该固件的模块
+	DeviceIDs []string `json:"device_ids,omitempty"` // 使用该模块的设备
+}
+
+// DriverBackupManifest 备份清单，保存在归档根目录的 manifest.json
+type DriverBackupManifest struct {
+	Version         int                   `json:"version"`
+	CreatedAt       time.Time             `json:"created_at"`
+	Host            string                `json:"host"`
+	HostFingerprint string                `json:"host_fingerprint"`
+	OS              string                `json:"os"`
+	Arch            string                `json:"arch"`
+	Kernel          string                `json:"kernel,omitempty"`
+	Drivers         []DriverBackupEntry   `json:"drivers"`
+	Firmware        []DriverFirmwareEntry `json:"firmware,omitempty"`
+}
+
+// DriverBackupResult 备份结果
+type DriverBackupResult struct {
+	Path        string    `json:"path"`
+	Format      string    `json:"format"`
+	DriverCount int       `json:"driver_count"`
+	FileCount   int       `json:"file_count"`
+	Size        int64     `json:"size"` // 归档大小
+	CreatedAt   time.Time `json:"created_at"`
+	Failed      []string  `json:"failed,omitempty"` // 导出失败的驱动
+}
+
+// driverBackupExtension 归档格式对应的扩展名
+func driverBackupExtension(format string) (string, error) {
+	switch format {
+	case DriverBackupZip:
+		return ".zip", nil
+	case DriverBackupTarZst:
+		return ".tar.zst", nil
+	case DriverBackup7z:
+		return ".7z", nil
+	}
+	return "", fmt.Errorf("不支持的备份格式: %s", format)
+}
+
+// driverBackupReporter 按阶段换算总进度，阶段之间的比例固定
+type driverBackupReporter struct {
+	report func(DriverBackupProgress)
+}
+
+// stage 报告某阶段内的进度，[from, to] 为该阶段在总进度中的范围
+func (r driverBackupReporter) stage(stage string, from, to int, done, total int64, message string) {
+	if r.report == nil {
+		return
+	}
+	percentage := from
+	if total > 0 {
+		percentage = from + int(int64(to-from)*done/total)
+	}
+	r.report(DriverBackupProgress{Stage: stage, Percentage: percentage, Message: message})
+}
+
+// Backup 导出第三方驱动，生成带哈希的清单并打包到 options.TargetDir
+// Windows 从驱动库导出；Linux 备份内核源码树之外的模块，以及这些模块在使用的、不属于发行版软件包的固件
+func (dm *DriverManager) Backup(ctx context.Context, options DriverBackupOptions, progress func(DriverBackupProgress)) (*DriverBackupResult, error) {
+	if options.Format == "" {
+		options.Format = DriverBackupZip
+	}
+	extension, err := driverBackupExtension(options.Format)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(options.TargetDir) == "" {
+		return nil, fmt.Errorf("未指定备份目录")
+	}
+	if err := os.MkdirAll(options.TargetDir, 0755); err != nil {
+		return nil, fmt.Errorf("创建备份目录失败: %v", err)
+	}
+	reporter := driverBackupReporter{report: progress}
+
+	reporter.stage("export", 0, 0, 0, 0, "正在列出驱动")
+	drivers, err := dm.ListDrivers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	drivers = filterDrivers(drivers, options.Drivers)
+
+	staging, err := os.MkdirTemp(options.TargetDir, ".driver-backup-*")
+	if err != nil {
+		return nil, fmt.Errorf("创建临时目录失败: %v", err)
+	}
+	defer os.RemoveAll(staging)
+
+	hostname, _ := os.Hostname()
+	manifest := &DriverBackupManifest{
+		Version:         driverBackupVersion,
+		CreatedAt:       time.Now(),
+		Host:            hostname,
+		HostFingerprint: HostFingerprint(),
+		OS:              runtime.GOOS,
+		Arch:            runtime.GOARCH,
+	}
+	var failed []string
+	switch runtime.GOOS {
+	case "windows":
+		failed, err = dm.exportWindowsDrivers(ctx, drivers, staging, manifest, reporter)
+	case "linux":
+		manifest.Kernel = strings.TrimSpace(dm.readSysFile("proc/sys/kernel/osrelease"))
+		// 明确指定了驱动时视为要求备份其固件
+		err = dm.exportLinuxDrivers(ctx, drivers, staging, manifest, options.Firmware || len(options.Drivers) > 0, reporter)
+	default:
+		err = fmt.Errorf("不支持在 %s 上备份驱动", runtime.GOOS)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(manifest.Drivers) == 0 && len(manifest.Firmware) == 0 {
+		if len(failed) > 0 {
+			return nil, fmt.Errorf("%d 个驱动导出失败: %s", len(failed), strings.Join(failed, ", "))
+		}
+		return nil, fmt.Errorf("没有需要备份的第三方驱动")
+	}
+
+	reporter.stage("manifest", 60, 60, 0, 0, "正在写入清单")
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(staging, driverManifestName), data, 0644); err != nil {
+		return nil, fmt.Errorf("写入清单失败: %v", err)
+	}
+
+	name := fmt.Sprintf("drivers_%s_%s%s", sanitizeFileName(hostname), manifest.CreatedAt.Format("20060102_150405"), extension)
+	archivePath := filepath.Join(options.TargetDir, name)
+	if err := dm.writeDriverArchive(ctx, staging, archivePath, options.Format, reporter); err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DriverBackupResult{
+		Path:        archivePath,
+		Format:      options.Format,
+		DriverCount: len(manifest.Drivers),
+		FileCount:   manifest.fileCount(),
+		Size:        info.Size(),
+		CreatedAt:   manifest.CreatedAt,
+		Failed:      failed,
+	}
+	dm.logger.Info("驱动备份完成", "path", archivePath, "drivers", result.DriverCount, "files", result.FileCount, "size", result.Size, "failed", len(failed))
+	reporter.stage("done", 100, 100, 0, 0, "备份完成")
+	return result, nil
+}
+
+// fileCount 清单中的文件总数
+func (m *DriverBackupManifest) fileCount() int {
+	count := len(m.Firmware)
+	for _, driver := range m.Drivers {
+		count += len(driver.Files)
+	}
+	return count
+}
+
+// filterDrivers 按发布名、模块名或名称筛选驱动，names 为空时返回全部
+func filterDrivers(drivers []DriverInfo, names []string) []DriverInfo {
+	if len(names) == 0 {
+		return drivers
+	}
+	var selected []DriverInfo
+	for _, driver := range drivers {
+		for _, name := range names {
+			if strings.EqualFold(name, driver.InfName) || strings.EqualFold(name, driver.Module) || strings.EqualFold(name, driver.Name) {
+				selected = append(selected, driver)
+				break
+			}
+		}
+	}
+	return selected
+}
+
+// exportWindowsDrivers 用 pnputil /export-driver 逐个导出驱动库中的驱动到 drivers/<发布名>
+// 单个驱动导出失败不中断备份，返回导出失败的发布名
+func (dm *DriverManager) exportWindowsDrivers(ctx context.Context, drivers []DriverInfo, staging string, manifest *DriverBackupManifest, reporter driverBackupReporter) ([]string, error) {
+	var failed []string
+	for i, driver := range drivers {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		reporter.stage("export", 0, 60, int64(i), int64(len(drivers)), "正在导出 "+driver.Name)
+
+		relative := windowsDriverDir(driver)
+		target := filepath.Join(staging, filepath.FromSlash(relative))
+		if err := os.MkdirAll(target, 0755); err != nil {
+			return nil, err
+		}
+		if _, err := dm.run(ctx, "pnputil", "/export-driver", driver.InfName, target); err != nil {
+			dm.logger.Warning("导出驱动失败", "driver", driver.InfName, "error", err)
+			failed = append(failed, driver.InfName)
+			// 导出到一半的文件不能打包进归档
+			if err := os.RemoveAll(target); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		files, err := hashBackupFiles(staging, relative)
+		if err != nil {
+			return nil, err
+		}
+		manifest.Drivers = append(manifest.Drivers, DriverBackupEntry{DriverInfo: driver, Files: files})
+	}
+	return failed, nil
+}
+
+// windowsDriverDir 驱动在归档中的目录 drivers/<发布名>
+func windowsDriverDir(driver DriverInfo) string {
+	return path.Join("drivers", strings.TrimSuffix(strings.ToLower(driver.InfName), ".inf"))
+}
+
+// exportLinuxDrivers 复制内核源码树之外的模块，以及绑定到设备的模块所引用的固件
+//
+// 随内核发布的模块所用的固件和发行版软件包中的固件在重装后可以从软件源获取，只有 allFirmware 为 true 时才备份
+func (dm *DriverManager) exportLinuxDrivers(ctx context.Context, drivers []DriverInfo, staging string, manifest *DriverBackupManifest, allFirmware bool, reporter driverBackupReporter) error {
+	seenFirmware := make(map[string]bool)
+	for i, driver := range drivers {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if driver.Module == "" {
+			continue
+		}
+		reporter.stage("export", 0, 60, int64(i), int64(len(drivers)), "正在导出 "+driver.Name)
+
+		outOfTree := driver.Path != "" && !isInTreeModule(driver.Path, manifest.Kernel)
+		if outOfTree {
+			relative := path.Join("modules", driver.Module, filepath.Base(driver.Path))
+			file, err := copyBackupFile(driver.Path, staging, relative)
+			if err != nil {
+				return fmt.Errorf("复制模块 %s 失败: %v", driver.Module, err)
+			}
+			manifest.Drivers = append(manifest.Drivers, DriverBackupEntry{DriverInfo: driver, Files: []DriverBackupFile{file}})
+		}
+
+		if len(driver.DeviceIDs) == 0 {
+			continue // 未绑定设备，固件不在使用中
+		}
+		if !outOfTree && !allFirmware {
+			continue
+		}
+		for _, name := range dm.moduleFirmware(ctx, driver.Module) {
+			source, suffix := dm.findFirmware(name, manifest.Kernel)
+			if source == "" || seenFirmware[name+suffix] {
+				continue
+			}
+			seenFirmware[name+suffix] = true
+			if !allFirmware && dm.isPackagedFile(ctx, source) {
+				continue
+			}
+			file, err := copyBackupFile(source, staging, path.Join("firmware", name+suffix))
+			if err != nil {
+				return fmt.Errorf("复制固件 %s 失败: %v", name, err)
+			}
+			manifest.Firmware = append(manifest.Firmware, DriverFirmwareEntry{DriverBackupFile: file, Name: name + suffix, Module: driver.Module, DeviceIDs: driver.DeviceIDs})
+		}
+	}
+	return nil
+}
+
+// isInTreeModule 模块文件是否位于 /lib/modules/<版本>/kernel 下（随内核发布）
+func isInTreeModule(modulePath, kernel string) bool {
+	slashed := filepath.ToSlash(modulePath)
+	for _, root := range []string{"/lib/modules/", "/usr/lib/modules/"} {
+		if strings.HasPrefix(slashed, root+kernel+"/kernel/") {
+			return true
+		}
+	}
+	return false
+}
+
+// moduleFirmware 模块声明需要的固件
+func (dm *DriverManager) moduleFirmware(ctx context.Context, module string) []string {
+	output, err := dm.run(ctx, "modinfo", "-F", "firmware", module)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, line := range strings.Split(string(output), "\n") {
+		name := strings.TrimSpace(line)
+		if name != "" && !strings.Contains(name, "..") && !strings.HasPrefix(name, "/") {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// findFirmware 按内核的查找顺序定位固件文件，返回路径和压缩后缀
+func (dm *DriverManager) findFirmware(name, kernel string) (string, string) {
+	dirs := []string{
+		filepath.Join("lib", "firmware", "updates", kernel),
+		filepath.Join("lib", "firmware", "updates"),
+		filepath.Join("lib", "firmware", kernel),
+		filepath.Join("lib", "firmware"),
+	}
+	for _, dir := range dirs {
+		for _, suffix := range linuxFirmwareSuffixes {
+			candidate := filepath.Join(dm.sysRoot, dir, filepath.FromSlash(name)+suffix)
+			if info, err := os.Stat(candidate); err == nil && info.Mode().IsRegular() {
+				return candidate, suffix
+			}
+		}
+	}
+	return "", ""
+}
+
+// isPackagedFile sysRoot 下的文件是否属于发行版软件包（由 dpkg 或 rpm 登记）
+func (dm *DriverManager) isPackagedFile(ctx context.Context, filePath string) bool {
+	relative, err := filepath.Rel(dm.sysRoot, filePath)
+	if err != nil {
+		return false
+	}
+	target := "/" + filepath.ToSlash(relative)
+	candidates := []string{target}
+	if strings.HasPrefix(target, "/lib/") {
+		// 合并了 /usr 的系统上软件包登记的是 /usr/lib 下的路径
+		candidates = append(candidates, "/usr"+target)
+	}
+	for _, candidate := range candidates {
+		if _, err := dm.run(ctx, "dpkg-query", "-S", candidate); err == nil {
+			return true
+		}
+		if _, err := dm.run(ctx, "rpm", "-qf", candidate); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// copyBackupFile 将文件复制到临时目录的 relative 位置并计算哈希
+func copyBackupFile(source, staging, relative string) (DriverBackupFile, error) {
+	target := filepath.Join(staging, filepath.FromSlash(relative))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return DriverBackupFile{}, err
+	}
+	in, err := os.Open(source)
+	if err != nil {
+		return DriverBackupFile{}, err
+	}
+	defer in.Close()
+	out, err := os.Create(target)
+	if err != nil {
+		return DriverBackupFile{}, err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return DriverBackupFile{}, err
+	}
+	if err := out.Close(); err != nil {
+		return DriverBackupFile{}, err
+	}
+	return hashBackupFile(staging, relative)
+}
+
+// hashBackupFiles 计算临时目录中 dir 下所有文件的哈希
+func hashBackupFiles(staging, dir string) ([]DriverBackupFile, error) {
+	var files []DriverBackupFile
+	err := filepath.WalkDir(filepath.Join(staging, filepath.FromSlash(dir)), func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		relative, err := filepath.Rel(staging, filePath)
+		if err != nil {
+			return err
+		}
+		file, err := hashBackupFile(staging, filepath.ToSlash(relative))
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+		return nil
+	})
+	return files, err
+}
+
+// hashBackupFile 计算临时目录中单个文件的大小和哈希
+func hashBackupFile(staging, relative string) (DriverBackupFile, error) {
+	filePath := filepath.Join(staging, filepath.FromSlash(relative))
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return DriverBackupFile{}, err
+	}
+	sum, err := fileSHA256(filePath)
+	if err != nil {
+		return DriverBackupFile{}, err
+	}
+	return DriverBackupFile{Path: relative, Size: info.Size(), SHA256: sum}, nil
+}
+
+// sanitizeFileName 去掉文件名中不能使用的字符
+func sanitizeFileName(name string) string {
+	cleaned := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`<>:"/\|?*`, r) || r < 0x20 {
+			return '_'
+		}
+		return r
+	}, name)
+	if cleaned == "" {
+		return "host"
+	}
+	return cleaned
+}
+
+// writeDriverArchive 将临时目录打包为归档，先写入临时文件，完成后再重命名
+func (dm *DriverManager) writeDriverArchive(ctx context.Context, staging, archivePath, format string, reporter driverBackupReporter) error {
+	partial := archivePath + ".partial"
+	defer os.Remove(partial)
+
+	var err error
+	if format == DriverBackup7z {
+		err = dm.writeSevenZip(ctx, staging, partial, reporter)
+	} else {
+		err = writeStreamArchive(ctx, staging, partial, format, reporter)
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(partial, archivePath); err != nil {
+		return fmt.Errorf("保存备份失败: %v", err)
+	}
+	return nil
+}
+
+// stagedFile 待打包的文件
+type stagedFile struct {
+	path     string // 本地路径
+	name     string // 归档内的路径
+	info     fs.FileInfo
+	relative string
+}
+
+// listStagedFiles 列出临时目录中的文件，清单放在最前面
+func listStagedFiles(staging string) ([]stagedFile, int64, error) {
+	var files []stagedFile
+	var total int64
+	err := filepath.WalkDir(staging, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(staging, filePath)
+		if err != nil {
+			return err
+		}
+		file := stagedFile{path: filePath, name: filepath.ToSlash(relative), info: info}
+		if file.name == driverManifestName {
+			files = append([]stagedFile{file}, files...)
+		} else {
+			files = append(files, file)
+		}
+		total += info.Size()
+		return nil
+	})
+	return files, total, err
+}
+
+// writeStreamArchive 写入 zip 或 tar.zst 归档，按已写入的字节数报告进度
+func writeStreamArchive(ctx context.Context, staging, archivePath, format string, reporter driverBackupReporter) error {
+	files, total, err := listStagedFiles(staging)
+	if err != nil {
+		return err
+	}
+	out, err := os.Create(archivePath)
+	if err != nil {
+		return fmt.Errorf("创建备份文件失败: %v", err)
+	}
+	defer out.Close()
+	buffered := bufio.NewWriter(out)
+
+	var written int64
+	copyFile := func(w io.Writer, file stagedFile) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		in, err := os.Open(file.path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		n, err := io.Copy(w, in)
+		written += n
+		reporter.stage("archive", 60, 99, written, total, "正在打包 "+file.name)
+		return err
+	}
+
+	switch format {
+	case DriverBackupZip:
+		archive := zip.NewWriter(buffered)
+		for _, file := range files {
+			header, err := zip.FileInfoHeader(file.info)
+			if err != nil {
+				return err
+			}
+			header.Name = file.name
+			header.Method = zip.Deflate
+			w, err := archive.CreateHeader(header)
+			if err != nil {
+				return err
+			}
+			if err := copyFile(w, file); err != nil {
+				return err
+			}
+		}
+		if err := archive.Close(); err != nil {
+			return err
+		}
+	case DriverBackupTarZst:
+		encoder, err := zstd.NewWriter(buffered, zstd.WithEncoderLevel(zstd.SpeedBetterCompression))
+		if err != nil {
+			return err
+		}
+		archive := tar.NewWriter(encoder)
+		for _, file := range files {
+			header, err := tar.FileInfoHeader(file.info, "")
+			if err != nil {
+				return err
+			}
+			header.Name = file.name
+			header.Uname, header.Gname = "", ""
+			if err := archive.WriteHeader(header); err != nil {
+				return err
+			}
+			if err := copyFile(archive, file); err != nil {
+				return err
+			}
+		}
+		if err := archive.Close(); err != nil {
+			return err
+		}
+		if err := encoder.Close(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("不支持的备份格式: %s", format)
+	}
+
+	if err := buffered.Flush(); err != nil {
+		return fmt.Errorf("写入备份文件失败: %v", err)
+	}
+	return out.Close()
+}
+
+// find7z 查找 7-Zip：优先使用程序目录 bin 下的 7z.exe，其次是 PATH 中的 7z/7za/7zz
+func find7z() (string, error) {
+	candidates := []string{filepath.Join("bin", "7z.exe")}
+	if executable, err := os.Executable(); err == nil {
+		candidates = append(candidates, filepath.Join(filepath.Dir(executable), "bin", "7z.exe"))
+	}
+	if runtime.GOOS == "windows" {
+		for _, candidate := range candidates {
+			if _, err := os.Stat(candidate); err == nil {
+				return filepath.Abs(candidate)
+			}
+		}
+	}
+	for _, name := range []string{"7z", "7za", "7zz"} {
+		if found, err := exec.LookPath(name); err == nil {
+			return found, nil
+		}
+	}
+	return "", fmt.Errorf("未找到 7-Zip，请安装 7-Zip 或将 7z.exe 放在 bin 目录下")
+}
+
+// writeSevenZip 调用 7-Zip 打包，从 -bsp1 的输出中读取进度
+func (dm *DriverManager) writeSevenZip(ctx context.Context, staging, archivePath string, reporter driverBackupReporter) error {
+	sevenZip, err := find7z()
+	if err != nil {
+		return err
+	}
+	absolute, err := filepath.Abs(archivePath)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, sevenZip, "a", "-t7z", "-mx=7", "-bsp1", "-bso0", "-y", absolute, ".")
+	cmd.Dir = staging
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("启动 7-Zip 失败: %v", err)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Split(scanProgressTokens)
+	for scanner.Scan() {
+		if match := sevenZipProgressPattern.FindStringSubmatch(scanner.Text()); match != nil {
+			if percentage, err := strconv.Atoi(match[1]); err == nil && percentage <= 100 {
+				reporter.stage("archive", 60, 99, int64(percentage), 100, "正在压缩")
+			}
+		}
+	}
+	if err := cmd.Wait(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && stderr.Len() > 0 {
+			return fmt.Errorf("7-Zip 压缩失败: %s", strings.TrimSpace(stderr.String()))
+		}
+		return fmt.Errorf("7-Zip 压缩失败: %v", err)
+	}
+	return nil
+}
+
+// scanProgressTokens 按换行、回车和退格分隔 7-Zip 的进度输出
+func scanProgressTokens(data []byte, atEOF bool) (int, []byte, error) {
+	for i, b := range data {
+		if b == '\n' || b == '\r' || b == '\b' {
+			return i + 1, data[:i], nil
+		}
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
@@ -0,0 +1,515 @@
+package core
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"SystemReinstaller/utils"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// defaultDriverBackupDir 未配置备份目录时，工作目录下的备份目录名
+const defaultDriverBackupDir = "driver_backups"
+
+// driverBackupIndexName 备份目录中索引文件的名称
+const driverBackupIndexName = "driver_backups.json"
+
+// driverBackupIndexVersion 索引文件的格式版本
+const driverBackupIndexVersion = 1
+
+// driverBackupPrefix 备份归档文件名的前缀
+const driverBackupPrefix = "drivers_"
+
+// 备份校验状态
+const (
+	BackupStatusUnverified = "unverified"
+	BackupStatusOK         = "ok"
+	BackupStatusCorrupted  = "corrupted"
+	BackupStatusModified   = "modified" // 索引后文件大小或修改时间变化，校验前不可信
+)
+
+// DriverBackupRecord 备份索引中的一条记录
+type DriverBackupRecord struct {
+	Name            string    `json:"name"` // 相对于备份目录的文件名
+	Path            string    `json:"path"` // 加载索引时按备份目录重新计算
+	CreatedAt       time.Time `json:"created_at"`
+	Host            string    `json:"host"`
+	HostFingerprint string    `json:"host_fingerprint"`
+	OS              string    `json:"os"`
+	DriverCount     int       `json:"driver_count"`
+	Format          string    `json:"format"`
+	Compressed      bool      `json:"compressed"`
+	Size            int64     `json:"size"`
+	ModTime         time.Time `json:"mod_time"` // 重新扫描时据此判断文件是否被替换
+	SHA256          string    `json:"sha256"`   // 归档文件的哈希
+	Status          string    `json:"status"`   // unverified, ok, corrupted, modified
+	VerifiedAt      time.Time `json:"verified_at"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// driverBackupIndexDocument 索引文件内容
+type driverBackupIndexDocument struct {
+	Version int                  `json:"version"`
+	Backups []DriverBackupRecord `json:"backups"`
+}
+
+// DriverBackupIndex 备份目录的索引，保存在目录下的 driver_backups.json
+//
+// 索引只记录目录第一层的备份归档，删除也只允许删除这些文件。
+type DriverBackupIndex struct {
+	root   string
+	mutex  sync.Mutex
+	logger *utils.Logger
+}
+
+// DriverBackupDir 配置的驱动备份目录，未配置时使用工作目录下的 driver_backups
+func DriverBackupDir(config *Config) string {
+	if config.Drivers.BackupDir != "" {
+		if abs, err := filepath.Abs(config.Drivers.BackupDir); err == nil {
+			return abs
+		}
+		return filepath.Clean(config.Drivers.BackupDir)
+	}
+	workingDir, err := os.Getwd()
+	if err != nil {
+		workingDir = "." // 如果获取失败，使用当前目录
+	}
+	return filepath.Join(workingDir, defaultDriverBackupDir)
+}
+
+// NewDriverBackupIndex 创建备份目录的索引
+func NewDriverBackupIndex(root string) *DriverBackupIndex {
+	return &DriverBackupIndex{root: root, logger: utils.NewNopLogger()}
+}
+
+// SetLogger 设置日志记录器
+func (ix *DriverBackupIndex) SetLogger(logger *utils.Logger) {
+	ix.logger = logger.With("component", "driver_backup_index")
+}
+
+// Root 备份目录
+func (ix *DriverBackupIndex) Root() string {
+	return ix.root
+}
+
+// load 读取索引，文件不存在时返回空列表
+func (ix *DriverBackupIndex) load() ([]DriverBackupRecord, error) {
+	data, err := os.ReadFile(filepath.Join(ix.root, driverBackupIndexName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取备份索引失败: %v", err)
+	}
+	var document driverBackupIndexDocument
+	if err := json.Unmarshal(data, &document); err != nil {
+		// 索引损坏时重新扫描即可恢复
+		ix.logger.Warning("备份索引格式错误，将重新扫描", "error", err)
+		return nil, nil
+	}
+	for i := range document.Backups {
+		document.Backups[i].Path = filepath.Join(ix.root, document.Backups[i].Name)
+	}
+	return document.Backups, nil
+}
+
+// save 按创建时间倒序原子地写入索引
+func (ix *DriverBackupIndex) save(records []DriverBackupRecord) error {
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].CreatedAt.After(records[j].CreatedAt)
+	})
+	data, err := json.MarshalIndent(driverBackupIndexDocument{Version: driverBackupIndexVersion, Backups: records}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(ix.root, 0755); err != nil {
+		return fmt.Errorf("创建备份目录失败: %v", err)
+	}
+	return writeFileAtomic(filepath.Join(ix.root, driverBackupIndexName), data, 0644)
+}
+
+// findBackupRecord 按文件名查找记录
+func findBackupRecord(records []DriverBackupRecord, name string) int {
+	for i, record := range records {
+		if record.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// List 按创建时间倒序返回索引中的备份
+func (ix *DriverBackupIndex) List() ([]DriverBackupRecord, error) {
+	ix.mutex.Lock()
+	defer ix.mutex.Unlock()
+	records, err := ix.load()
+	if err != nil {
+		return nil, err
+	}
+	if records == nil {
+		records = []DriverBackupRecord{}
+	}
+	return records, nil
+}
+
+// Add 将备份目录中的归档加入索引，已存在时更新
+func (ix *DriverBackupIndex) Add(ctx context.Context, archivePath string) (*DriverBackupRecord, error) {
+	ix.mutex.Lock()
+	defer ix.mutex.Unlock()
+
+	name, err := ix.backupName(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	records, err := ix.load()
+	if err != nil {
+		return nil, err
+	}
+	record, err := ix.newRecord(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if i := findBackupRecord(records, name); i >= 0 {
+		records[i] = *record
+	} else {
+		records = append(records, *record)
+	}
+	if err := ix.save(records); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Rescan 扫描备份目录：加入新发现的归档，标记被修改的归档，移除已不存在的记录
+//
+// 被修改的归档保留索引时的哈希，不重新计算，否则被替换的文件会被当作可信的备份；
+// 校验通过（内容与索引时一致）后恢复为正常状态
+func (ix *DriverBackupIndex) Rescan(ctx context.Context) ([]DriverBackupRecord, error) {
+	ix.mutex.Lock()
+	defer ix.mutex.Unlock()
+
+	records, err := ix.load()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(ix.root)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []DriverBackupRecord{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取备份目录失败: %v", err)
+	}
+
+	scanned := []DriverBackupRecord{}
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if !isDriverBackupName(entry.Name()) || !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if i := findBackupRecord(records, entry.Name()); i >= 0 {
+			existing := records[i]
+			if existing.Size != info.Size() || !existing.ModTime.Equal(info.ModTime()) {
+				if existing.Status != BackupStatusModified {
+					ix.logger.Warning("备份在索引后被修改", "name", existing.Name, "size", info.Size(), "mod_time", info.ModTime())
+				}
+				existing.Status = BackupStatusModified
+				existing.Error = "备份文件在加入索引后被修改，请校验"
+			}
+			scanned = append(scanned, existing)
+			continue
+		}
+		record, err := ix.newRecord(ctx, entry.Name())
+		if err != nil {
+			ix.logger.Warning("无法索引备份", "name", entry.Name(), "error", err)
+			continue
+		}
+		scanned = append(scanned, *record)
+	}
+
+	if len(scanned) != len(records) {
+		ix.logger.Info("备份索引已更新", "root", ix.root, "before", len(records), "after", len(scanned))
+	}
+	if err := ix.save(scanned); err != nil {
+		return nil, err
+	}
+	return scanned, nil
+}
+
+// Verify 校验归档哈希与索引是否一致，并解压校验清单中所有文件，结果写回索引
+func (ix *DriverBackupIndex) Verify(ctx context.Context, archivePath string) (*DriverBackupRecord, error) {
+	ix.mutex.Lock()
+	defer ix.mutex.Unlock()
+
+	name, err := ix.backupName(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	records, err := ix.load()
+	if err != nil {
+		return nil, err
+	}
+	i := findBackupRecord(records, name)
+	if i < 0 {
+		return nil, fmt.Errorf("备份不在索引中: %s", name)
+	}
+	record := &records[i]
+
+	// 在计算哈希前取大小和修改时间，之后再被修改的文件下次扫描时仍会被标记
+	info, statErr := os.Stat(record.Path)
+	record.Status, record.Error = BackupStatusOK, ""
+	if err := verifyDriverArchive(ctx, record); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		record.Status, record.Error = BackupStatusCorrupted, err.Error()
+		ix.logger.Warning("备份校验失败", "name", name, "error", err)
+	}
+	record.VerifiedAt = time.Now()
+	if record.Status == BackupStatusOK && statErr == nil {
+		// 内容与索引时一致，之后的扫描不再标记为已修改
+		record.Size, record.ModTime = info.Size(), info.ModTime()
+	}
+	verified := *record // save 会重新排序 records
+	if err := ix.save(records); err != nil {
+		return nil, err
+	}
+	return &verified, nil
+}
+
+// verifyDriverArchive 校验归档哈希和归档内的文件
+func verifyDriverArchive(ctx context.Context, record *DriverBackupRecord) error {
+	sum, err := fileSHA256(record.Path)
+	if err != nil {
+		return fmt.Errorf("读取备份失败: %v", err)
+	}
+	if !strings.EqualFold(sum, record.SHA256) {
+		return fmt.Errorf("归档哈希与索引不一致")
+	}
+	dir, err := os.MkdirTemp("", "driver-verify-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	_, err = ExtractDriverBackup(ctx, record.Path, dir)
+	return err
+}
+
+// Delete 删除备份目录中的归档并移除索引记录，拒绝删除备份目录之外的文件
+func (ix *DriverBackupIndex) Delete(archivePath string) error {
+	ix.mutex.Lock()
+	defer ix.mutex.Unlock()
+
+	name, err := ix.backupName(archivePath)
+	if err != nil {
+		return err
+	}
+	info, err := os.Lstat(filepath.Join(ix.root, name))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("拒绝删除: %s 不是普通文件", name)
+		}
+		if err := os.Remove(filepath.Join(ix.root, name)); err != nil {
+			return fmt.Errorf("删除备份失败: %v", err)
+		}
+	}
+
+	records, err := ix.load()
+	if err != nil {
+		return err
+	}
+	if i := findBackupRecord(records, name); i >= 0 {
+		records = append(records[:i], records[i+1:]...)
+	}
+	ix.logger.Info("已删除备份", "name", name)
+	return ix.save(records)
+}
+
+// backupName 确认路径是备份目录第一层的备份归档，返回文件名
+// 比较前解析符号链接，避免通过链接或 .. 指向目录之外
+func (ix *DriverBackupIndex) backupName(archivePath string) (string, error) {
+	root, err := resolvePath(ix.root)
+	if err != nil {
+		return "", fmt.Errorf("备份目录无效: %v", err)
+	}
+	dir, err := resolvePath(filepath.Dir(archivePath))
+	if err != nil {
+		return "", fmt.Errorf("备份路径无效: %v", err)
+	}
+	name := filepath.Base(archivePath)
+	if !samePath(root, dir) {
+		return "", fmt.Errorf("拒绝操作备份目录之外的文件: %s", archivePath)
+	}
+	if !isDriverBackupName(name) {
+		return "", fmt.Errorf("不是驱动备份文件: %s", name)
+	}
+	return name, nil
+}
+
+// resolvePath 转换为绝对路径并解析符号链接
+func resolvePath(p string) (string, error) {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
+
+// samePath 比较两个已解析的路径，Windows 不区分大小写
+func samePath(a, b string) bool {
+	if filepath.Separator == '\\' {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// isDriverBackupName 文件名是否为 Backup 生成的备份归档
+func isDriverBackupName(name string) bool {
+	if !strings.HasPrefix(name, driverBackupPrefix) {
+		return false
+	}
+	_, err := driverBackupFormat(name)
+	return err == nil
+}
+
+// newRecord 读取归档中的清单并计算哈希，生成索引记录
+func (ix *DriverBackupIndex) newRecord(ctx context.Context, name string) (*DriverBackupRecord, error) {
+	archivePath := filepath.Join(ix.root, name)
+	info, err := os.Stat(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	format, err := driverBackupFormat(name)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := readArchiveManifest(ctx, archivePath, format)
+	if err != nil {
+		return nil, err
+	}
+	sum, err := fileSHA256(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	return &DriverBackupRecord{
+		Name:            name,
+		Path:            archivePath,
+		CreatedAt:       manifest.CreatedAt,
+		Host:            manifest.Host,
+		HostFingerprint: manifest.HostFingerprint,
+		OS:              manifest.OS,
+		DriverCount:     len(manifest.Drivers),
+		Format:          format,
+		Compressed:      true, // 三种格式都是压缩归档
+		Size:            info.Size(),
+		ModTime:         info.ModTime(),
+		SHA256:          sum,
+		Status:          BackupStatusUnverified,
+	}, nil
+}
+
+// readArchiveManifest 不解压整个归档，只读取其中的清单
+func readArchiveManifest(ctx context.Context, archivePath, format string) (*DriverBackupManifest, error) {
+	var data []byte
+	var err error
+	switch format {
+	case DriverBackupZip:
+		data, err = readZipManifest(archivePath)
+	case DriverBackupTarZst:
+		data, err = readTarZstManifest(archivePath)
+	case DriverBackup7z:
+		data, err = readSevenZipManifest(ctx, archivePath)
+	default:
+		err = fmt.Errorf("不支持的备份格式: %s", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取备份清单失败: %v", err)
+	}
+
+	var manifest DriverBackupManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("解析备份清单失败: %v", err)
+	}
+	return &manifest, nil
+}
+
+// readZipManifest 读取 zip 归档中的清单
+func readZipManifest(archivePath string) ([]byte, error) {
+	archive, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+	r, err := archive.Open(driverManifestName)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// readTarZstManifest 读取 tar.zst 归档中的清单，Backup 把清单写在最前面
+func readTarZstManifest(archivePath string) ([]byte, error) {
+	in, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	decoder, err := zstd.NewReader(in)
+	if err != nil {
+		return nil, err
+	}
+	defer decoder.Close()
+
+	archive := tar.NewReader(decoder)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("归档中没有 %s", driverManifestName)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Name == driverManifestName {
+			return io.ReadAll(archive)
+		}
+	}
+}
+
+// readSevenZipManifest 用 7-Zip 将清单输出到标准输出
+func readSevenZipManifest(ctx context.Context, archivePath string) ([]byte, error) {
+	sevenZip, err := find7z()
+	if err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, sevenZip, "e", "-so", archivePath, driverManifestName)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,169 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestBackupIndex 在临时备份目录中放入一个 zip 备份，返回索引和备份路径
+func newTestBackupIndex(t *testing.T) (*DriverBackupIndex, string) {
+	t.Helper()
+	source := newWindowsBackupFixture(t, DriverBackupZip)
+	data, err := os.ReadFile(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := t.TempDir()
+	archivePath := filepath.Join(root, "drivers_host_20240101_120000.zip")
+	if err := os.WriteFile(archivePath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return NewDriverBackupIndex(root), archivePath
+}
+
+func TestDriverBackupIndexRescan(t *testing.T) {
+	ix, archivePath := newTestBackupIndex(t)
+	if err := os.WriteFile(filepath.Join(ix.Root(), "notes.txt"), []byte("not a backup"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := ix.Rescan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Path != archivePath || records[0].Status != BackupStatusUnverified {
+		t.Fatalf("records = %+v, want the zip backup only", records)
+	}
+	if records[0].OS != "windows" || records[0].DriverCount != 2 || records[0].SHA256 == "" {
+		t.Errorf("record = %+v", records[0])
+	}
+
+	// 索引持久化在目录中，新的实例读取同样的记录
+	listed, err := NewDriverBackupIndex(ix.Root()).List()
+	if err != nil || len(listed) != 1 || listed[0].SHA256 != records[0].SHA256 {
+		t.Fatalf("List = %+v, %v", listed, err)
+	}
+
+	if err := os.Remove(archivePath); err != nil {
+		t.Fatal(err)
+	}
+	records, err = ix.Rescan(context.Background())
+	if err != nil || len(records) != 0 {
+		t.Fatalf("records after removal = %+v, %v", records, err)
+	}
+}
+
+func TestDriverBackupIndexRescanKeepsHashOfModifiedArchive(t *testing.T) {
+	ix, archivePath := newTestBackupIndex(t)
+	records, err := ix.Rescan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := records[0]
+	data, err := os.ReadFile(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 替换为另一个有效的备份：重新扫描不能把新文件的哈希当作可信值
+	replacement, err := os.ReadFile(writeTestDriverBackup(t, &DriverBackupManifest{Version: driverBackupVersion, OS: "windows"}, nil, DriverBackupZip))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(archivePath, replacement, 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := original.ModTime.Add(time.Minute)
+	if err := os.Chtimes(archivePath, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err = ix.Rescan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	modified := records[0]
+	if modified.Status != BackupStatusModified || modified.SHA256 != original.SHA256 || modified.DriverCount != original.DriverCount {
+		t.Fatalf("record = %+v, want modified with the original hash", modified)
+	}
+	if records, _ := ix.Rescan(context.Background()); records[0].Status != BackupStatusModified {
+		t.Fatalf("second rescan cleared the modified status: %+v", records[0])
+	}
+
+	verified, err := ix.Verify(context.Background(), archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verified.Status != BackupStatusCorrupted {
+		t.Fatalf("Verify status = %s, want corrupted", verified.Status)
+	}
+
+	// 恢复原来的内容后校验通过，之后的扫描不再标记
+	if err := os.WriteFile(archivePath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	verified, err = ix.Verify(context.Background(), archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verified.Status != BackupStatusOK {
+		t.Fatalf("Verify status = %s (%s), want ok", verified.Status, verified.Error)
+	}
+	records, err = ix.Rescan(context.Background())
+	if err != nil || records[0].Status != BackupStatusOK {
+		t.Fatalf("records = %+v, %v; want ok after verification", records, err)
+	}
+}
+
+func TestDriverBackupIndexDeleteStaysInRoot(t *testing.T) {
+	ix, archivePath := newTestBackupIndex(t)
+	if _, err := ix.Rescan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	outside := filepath.Join(t.TempDir(), "drivers_other_20240101_120000.zip")
+	if err := os.WriteFile(outside, []byte("outside"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(ix.Root(), "drivers_link_20240101_120000.zip")
+	if err := os.Symlink(outside, link); err != nil {
+		t.Fatal(err)
+	}
+	for _, target := range []string{outside, filepath.Join(ix.Root(), "..", filepath.Base(outside)), link, filepath.Join(ix.Root(), driverBackupIndexName)} {
+		if err := ix.Delete(target); err == nil {
+			t.Errorf("Delete(%s) succeeded", target)
+		}
+	}
+	if _, err := os.Stat(outside); err != nil {
+		t.Fatalf("file outside the backup directory was removed: %v", err)
+	}
+
+	if err := ix.Delete(archivePath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(archivePath); !os.IsNotExist(err) {
+		t.Fatalf("backup not removed: %v", err)
+	}
+	if records, err := ix.List(); err != nil || len(records) != 0 {
+		t.Fatalf("records after delete = %+v, %v", records, err)
+	}
+}
+
+func TestDriverBackupDir(t *testing.T) {
+	config := DefaultConfig()
+	workingDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := DriverBackupDir(config); got != filepath.Join(workingDir, defaultDriverBackupDir) {
+		t.Errorf("default DriverBackupDir = %s", got)
+	}
+
+	config.Drivers.BackupDir = filepath.Join(t.TempDir(), "backups", "..", "drivers")
+	if got, want := DriverBackupDir(config), filepath.Clean(config.Drivers.BackupDir); got != want {
+		t.Errorf("DriverBackupDir = %s, want %s", got, want)
+	}
+}
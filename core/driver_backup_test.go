@@ -0,0 +1,261 @@
+package core
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"SystemReinstaller/utils"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const testKernel = "6.8.0-45-generic"
+
+// newLinuxBackupHost 带一个源码树外模块（绑定到网卡）和它所需固件的假 Linux 主机
+func newLinuxBackupHost(t *testing.T) (*DriverManager, map[string]string) {
+	t.Helper()
+	if runtime.GOOS != "linux" {
+		t.Skip("driver backup round trip uses a fake /sys tree and only runs on Linux")
+	}
+	root := newFakeSysRoot(t, []fakeSysfsDevice{
+		{bus: "pci", name: "0000:03:00.0", driver: "r8125", module: "r8125", modalias: "pci:v000010ECd00008125sv00001849sd00008125bc02sc00i00", class: "0x020000"},
+		{bus: "pci", name: "0000:00:14.0", driver: "xhci_hcd", modalias: "pci:v00008086d0000A36Dsv00001028sd000008B9bc0Csc03i30", class: "0x0c0330"},
+	}, "r8125 118784 0 - Live 0x0000000000000000\n", testKernel)
+
+	contents := map[string]string{
+		"module":   "\x7fELF r8125 module",
+		"firmware": "rtl8125b firmware",
+	}
+	modulePath := filepath.Join(root, "lib", "modules", testKernel, "updates", "dkms", "r8125.ko")
+	firmwarePath := filepath.Join(root, "lib", "firmware", "rtl_nic", "rtl8125b-2.fw.zst")
+	for file, content := range map[string]string{modulePath: contents["module"], firmwarePath: contents["firmware"]} {
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dm := &DriverManager{
+		sysRoot: root,
+		logger:  utils.NewNopLogger(),
+		run: fakeDriverCommands(map[string]string{
+			"modinfo r8125": "filename:       " + modulePath + "\nversion:        9.012.04-NAPI\n" +
+				"description:    Realtek r8125 Ethernet controller driver\nauthor:         Realtek and the Linux r8125 crew <netdev@vger.kernel.org>\n",
+			"modinfo -F firmware r8125": "rtl_nic/rtl8125b-2.fw\n../escape.fw\n",
+		}),
+	}
+	return dm, contents
+}
+
+// readTestArchive 读取 zip 或 tar.zst 归档中所有文件的内容
+func readTestArchive(t *testing.T, archivePath, format string) map[string][]byte {
+	t.Helper()
+	files := make(map[string][]byte)
+	switch format {
+	case DriverBackupZip:
+		archive, err := zip.OpenReader(archivePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer archive.Close()
+		for _, file := range archive.File {
+			r, err := file.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := io.ReadAll(r)
+			r.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			files[file.Name] = data
+		}
+	case DriverBackupTarZst:
+		in, err := os.Open(archivePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer in.Close()
+		decoder, err := zstd.NewReader(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer decoder.Close()
+		archive := tar.NewReader(decoder)
+		for {
+			header, err := archive.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := io.ReadAll(archive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			files[header.Name] = data
+		}
+	}
+	return files
+}
+
+func TestDriverBackupRoundTrip(t *testing.T) {
+	for _, format := range []string{DriverBackupZip, DriverBackupTarZst} {
+		t.Run(format, func(t *testing.T) {
+			dm, contents := newLinuxBackupHost(t)
+			var stages []string
+			result, err := dm.Backup(context.Background(), DriverBackupOptions{TargetDir: t.TempDir(), Format: format}, func(p DriverBackupProgress) {
+				if len(stages) == 0 || stages[len(stages)-1] != p.Stage {
+					stages = append(stages, p.Stage)
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.DriverCount != 1 || result.FileCount != 2 {
+				t.Fatalf("result = %+v, want 1 driver and 2 files", result)
+			}
+			if !strings.HasSuffix(result.Path, "."+format) || stages[len(stages)-1] != "done" {
+				t.Errorf("path = %s, stages = %v", result.Path, stages)
+			}
+			if _, err := os.Stat(result.Path + ".partial"); !os.IsNotExist(err) {
+				t.Errorf("partial archive left behind: %v", err)
+			}
+
+			files := readTestArchive(t, result.Path, format)
+			var manifest DriverBackupManifest
+			if err := json.Unmarshal(files[driverManifestName], &manifest); err != nil {
+				t.Fatalf("manifest: %v", err)
+			}
+			if manifest.OS != "linux" || manifest.Kernel != testKernel || len(manifest.Drivers) != 1 || len(manifest.Firmware) != 1 {
+				t.Fatalf("manifest = %+v", manifest)
+			}
+			driver := manifest.Drivers[0]
+			if driver.Module != "r8125" || driver.Version != "9.012.04-NAPI" || len(driver.DeviceIDs) != 1 {
+				t.Errorf("driver = %+v", driver)
+			}
+			firmware := manifest.Firmware[0]
+			if firmware.Name != "rtl_nic/rtl8125b-2.fw.zst" || firmware.Module != "r8125" {
+				t.Errorf("firmware = %+v", firmware)
+			}
+			for _, item := range []struct {
+				file DriverBackupFile
+				want string
+			}{{driver.Files[0], contents["module"]}, {firmware.DriverBackupFile, contents["firmware"]}} {
+				data := files[item.file.Path]
+				if string(data) != item.want || item.file.Size != int64(len(data)) || item.file.SHA256 != sha256Hex(data) {
+					t.Errorf("%s = %q, manifest %+v; want %q", item.file.Path, data, item.file, item.want)
+				}
+			}
+			if len(files) != 3 {
+				t.Errorf("archive has %d files, want manifest, module and firmware", len(files))
+			}
+		})
+	}
+}
+
+func TestExportLinuxFirmwareSelection(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{
+		filepath.Join("lib", "modules", testKernel, "updates", "dkms", "r8125.ko"),
+		filepath.Join("lib", "firmware", "rtl_nic", "rtl8125b-2.fw"),
+		filepath.Join("lib", "firmware", "updates", "r8125-custom.bin"),
+		filepath.Join("lib", "firmware", "iwlwifi-ty-a0-gf-a0-83.ucode"),
+	} {
+		if err := os.MkdirAll(filepath.Join(root, filepath.Dir(name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	drivers := []DriverInfo{
+		{Name: "r8125", Module: "r8125", Path: filepath.Join(root, "lib", "modules", testKernel, "updates", "dkms", "r8125.ko"), DeviceIDs: []string{"PCI\\VEN_10EC&DEV_8125"}},
+		{Name: "iwlwifi", Module: "iwlwifi", Path: "/lib/modules/" + testKernel + "/kernel/drivers/net/wireless/intel/iwlwifi/iwlwifi.ko.zst", DeviceIDs: []string{"PCI\\VEN_8086&DEV_2725"}},
+	}
+	dm := &DriverManager{
+		sysRoot: root,
+		logger:  utils.NewNopLogger(),
+		run: fakeDriverCommands(map[string]string{
+			"modinfo -F firmware r8125":   "rtl_nic/rtl8125b-2.fw\nr8125-custom.bin\n",
+			"modinfo -F firmware iwlwifi": "iwlwifi-ty-a0-gf-a0-83.ucode\n",
+			// 合并了 /usr 的系统上软件包登记的是 /usr/lib 下的路径
+			"dpkg-query -S /usr/lib/firmware/rtl_nic/rtl8125b-2.fw": "firmware-realtek: /usr/lib/firmware/rtl_nic/rtl8125b-2.fw\n",
+		}),
+	}
+
+	tests := []struct {
+		name        string
+		allFirmware bool
+		want        []string
+	}{
+		{"只备份不属于软件包的固件", false, []string{"r8125-custom.bin"}},
+		{"要求备份全部固件", true, []string{"rtl_nic/rtl8125b-2.fw", "r8125-custom.bin", "iwlwifi-ty-a0-gf-a0-83.ucode"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest := &DriverBackupManifest{Kernel: testKernel}
+			if err := dm.exportLinuxDrivers(context.Background(), drivers, t.TempDir(), manifest, tt.allFirmware, driverBackupReporter{}); err != nil {
+				t.Fatal(err)
+			}
+			if len(manifest.Drivers) != 1 || manifest.Drivers[0].Module != "r8125" {
+				t.Errorf("drivers = %+v, want only the out-of-tree module", manifest.Drivers)
+			}
+			var names []string
+			for _, firmware := range manifest.Firmware {
+				names = append(names, firmware.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("firmware = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportWindowsDriversReportsFailures(t *testing.T) {
+	dm := &DriverManager{
+		logger: utils.NewNopLogger(),
+		run: func(ctx context.Context, name string, args ...string) ([]byte, error) {
+			if name != "pnputil" || len(args) != 3 || args[0] != "/export-driver" {
+				return nil, fmt.Errorf("unexpected command %s %v", name, args)
+			}
+			// 失败的导出也可能留下部分文件
+			if err := os.WriteFile(filepath.Join(args[2], args[1]), []byte(args[1]), 0644); err != nil {
+				return nil, err
+			}
+			if args[1] == "oem2.inf" {
+				return nil, fmt.Errorf("执行 pnputil 失败: exit status 5")
+			}
+			return nil, nil
+		},
+	}
+	drivers := []DriverInfo{{Name: "Realtek", InfName: "oem1.inf"}, {Name: "Intel", InfName: "oem2.inf"}}
+	staging := t.TempDir()
+	manifest := &DriverBackupManifest{}
+
+	failed, err := dm.exportWindowsDrivers(context.Background(), drivers, staging, manifest, driverBackupReporter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 1 || failed[0] != "oem2.inf" {
+		t.Errorf("failed = %v, want [oem2.inf]", failed)
+	}
+	if len(manifest.Drivers) != 1 || manifest.Drivers[0].InfName != "oem1.inf" {
+		t.Errorf("drivers = %+v", manifest.Drivers)
+	}
+	if _, err := os.Stat(filepath.Join(staging, "drivers", "oem2")); !os.IsNotExist(err) {
+		t.Errorf("导出失败的驱动目录应被删除: %v", err)
+	}
+}
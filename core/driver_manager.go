@@ -0,0 +1,522 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"SystemReinstaller/utils"
+)
+
+// driverCommandTimeout 单个驱动查询命令的超时
+const driverCommandTimeout = 2 * time.Minute
+
+var (
+	// pnputil 输出中的字段值格式，标签会随系统语言变化，只按值识别
+	pnpGUIDPattern    = regexp.MustCompile(`^\{[0-9A-Fa-f]{8}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{12}\}$`)
+	pnpVersionPattern = regexp.MustCompile(`^(\d{1,4})[/.-](\d{1,2})[/.-](\d{1,4})\s+(\S+)$`) // 日期格式随区域设置变化
+)
+
+// pciClassNames PCI设备基础类别对应的驱动类别，与Windows的设备类名称一致
+var pciClassNames = map[string]string{
+	"01": "SCSIAdapter",
+	"02": "Net",
+	"03": "Display",
+	"04": "Media",
+	"05": "Memory",
+	"06": "System",
+	"07": "Ports",
+	"08": "System",
+	"09": "HIDClass",
+	"0c": "USB",
+	"0d": "Net",
+	"10": "Security",
+	"11": "DataAcquisition",
+}
+
+// DriverInfo 已安装的驱动
+type DriverInfo struct {
+	Name         string   `json:"name"`                    // 设备描述或模块名
+	InfName      string   `json:"inf_name,omitempty"`      // Windows 驱动库中的发布名，如 oem12.inf
+	OriginalName string   `json:"original_name,omitempty"` // Windows 原始INF文件名
+	Module       string   `json:"module,omitempty"`        // Linux 内核模块名，内置驱动为空
+	Version      string   `json:"version,omitempty"`
+	Date         string   `json:"date,omitempty"` // YYYY-MM-DD
+	Class        string   `json:"class,omitempty"`
+	ClassGUID    string   `json:"class_guid,omitempty"`
+	Provider     string   `json:"provider,omitempty"`
+	Signer       string   `json:"signer,omitempty"`
+	DeviceIDs    []string `json:"device_ids,omitempty"` // Windows 硬件ID，Linux modalias
+	Path         string   `json:"path,omitempty"`       // Linux 模块文件
+}
+
+// DriverManager 驱动管理器：列出已安装的驱动
+type DriverManager struct {
+	sysRoot string // Linux 下 /sys 和 /proc 所在的根目录
+	run     func(ctx context.Context, name string, args ...string) ([]byte, error)
+	logger  *utils.Logger
+}
+
+// NewDriverManager 创建驱动管理器
+func NewDriverManager() *DriverManager {
+	return &DriverManager{
+		sysRoot: "/",
+		run:     runDriverCommand,
+		logger:  utils.NewNopLogger(),
+	}
+}
+
+// SetLogger 设置日志记录器
+func (dm *DriverManager) SetLogger(logger *utils.Logger) {
+	dm.logger = logger.With("component", "driver")
+}
+
+// runDriverCommand 执行查询命令并返回标准输出
+func runDriverCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, driverCommandTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, name, args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("执行 %s 失败: %v", name, err)
+	}
+	return output, nil
+}
+
+// ListDrivers 列出已安装的驱动，按类别和名称排序
+func (dm *DriverManager) ListDrivers(ctx context.Context) ([]DriverInfo, error) {
+	var drivers []DriverInfo
+	var err error
+	switch runtime.GOOS {
+	case "windows":
+		drivers, err = dm.listWindowsDrivers(ctx)
+	case "linux":
+		drivers, err = dm.listLinuxDrivers(ctx)
+	default:
+		return nil, fmt.Errorf("不支持在 %s 上列出驱动", runtime.GOOS)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(drivers, func(i, j int) bool {
+		if drivers[i].Class != drivers[j].Class {
+			return drivers[i].Class < drivers[j].Class
+		}
+		return strings.ToLower(drivers[i].Name) < strings.ToLower(drivers[j].Name)
+	})
+	dm.logger.Debug("已列出驱动", "count", len(drivers))
+	return drivers, nil
+}
+
+// listWindowsDrivers 通过 pnputil 列出驱动库中的第三方驱动，并关联使用它们的设备
+func (dm *DriverManager) listWindowsDrivers(ctx context.Context) ([]DriverInfo, error) {
+	output, err := dm.run(ctx, "pnputil", "/enum-drivers")
+	if err != nil {
+		return nil, err
+	}
+	drivers := parsePnputilDrivers(string(output))
+
+	// /enum-devices 需要 Windows 10 2004 及以上，失败时只缺少设备信息
+	devicesOutput, err := dm.run(ctx, "pnputil", "/enum-devices", "/ids")
+	if err != nil {
+		dm.logger.Warning("获取设备硬件ID失败", "error", err)
+		return drivers, nil
+	}
+	devices := parsePnputilDevices(string(devicesOutput))
+	for i := range drivers {
+		for _, device := range devices {
+			if !strings.EqualFold(device.driver, drivers[i].InfName) {
+				continue
+			}
+			if drivers[i].Name == drivers[i].OriginalName && device.description != "" {
+				drivers[i].Name = device.description
+			}
+			drivers[i].DeviceIDs = appendUnique(drivers[i].DeviceIDs, device.hardwareIDs...)
+		}
+	}
+	return drivers, nil
+}
+
+// pnputilBlocks 将 pnputil 输出按空行分段，每段为按顺序排列的字段
+// 以空白开头且没有标签的行是上一字段的后续值
+func pnputilBlocks(output string) [][]pnputilField {
+	var blocks [][]pnputilField
+	var current []pnputilField
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r ")
+		if strings.TrimSpace(line) == "" {
+			if len(current) > 0 {
+				blocks = append(blocks, current)
+				current = nil
+			}
+			continue
+		}
+
+		label, value, ok := cutPnputilLabel(line)
+		if !ok {
+			if len(current) > 0 && (line[0] == ' ' || line[0] == '\t') {
+				last := &current[len(current)-1]
+				last.values = append(last.values, strings.TrimSpace(line))
+			}
+			continue
+		}
+		field := pnputilField{label: label}
+		if value != "" {
+			field.values = []string{value}
+		}
+		current = append(current, field)
+	}
+	if len(current) > 0 {
+		blocks = append(blocks, current)
+	}
+	return blocks
+}
+
+// pnputilField pnputil 输出中的一个字段
+type pnputilField struct {
+	label  string
+	values []string
+}
+
+// value 字段的第一个值
+func (f pnputilField) value() string {
+	if len(f.values) == 0 {
+		return ""
+	}
+	return f.values[0]
+}
+
+// cutPnputilLabel 拆分 "标签: 值"，支持中文全角冒号
+func cutPnputilLabel(line string) (string, string, bool) {
+	if line[0] == ' ' || line[0] == '\t' {
+		return "", "", false
+	}
+	index := strings.IndexAny(line, ":：")
+	if index <= 0 {
+		return "", "", false
+	}
+	separator := ":"
+	if strings.HasPrefix(line[index:], "：") {
+		separator = "："
+	}
+	return strings.TrimSpace(line[:index]), strings.TrimSpace(line[index+len(separator):]), true
+}
+
+// parsePnputilDrivers 解析 pnputil /enum-drivers 的输出
+//
+// 字段顺序固定：发布名、原始名、提供者、类名、类GUID、[扩展ID]、驱动日期和版本、签名者。
+func parsePnputilDrivers(output string) []DriverInfo {
+	var drivers []DriverInfo
+	for _, block := range pnputilBlocks(output) {
+		var infs []int
+		guidIndex, versionIndex := -1, -1
+		for i, field := range block {
+			value := field.value()
+			switch {
+			case strings.HasSuffix(strings.ToLower(value), ".inf"):
+				infs = append(infs, i)
+			case guidIndex < 0 && pnpGUIDPattern.MatchString(value):
+				guidIndex = i
+			case pnpVersionPattern.MatchString(value):
+				versionIndex = i
+			}
+		}
+		if len(infs) == 0 {
+			continue
+		}
+
+		driver := DriverInfo{InfName: block[infs[0]].value()}
+		next := infs[0] + 1
+		if len(infs) > 1 {
+			driver.OriginalName = block[infs[1]].value()
+			next = infs[1] + 1
+		}
+		providerIndex := -1
+		if next < len(block) && next != guidIndex && next != versionIndex {
+			providerIndex = next
+			driver.Provider = block[next].value()
+		}
+		if guidIndex >= 0 {
+			driver.ClassGUID = block[guidIndex].value()
+			if classIndex := guidIndex - 1; classIndex > providerIndex && classIndex > infs[len(infs)-1] {
+				driver.Class = block[classIndex].value()
+			}
+		}
+		if versionIndex >= 0 {
+			match := pnpVersionPattern.FindStringSubmatch(block[versionIndex].value())
+			driver.Date = pnputilDate(match[1], match[2], match[3])
+			driver.Version = match[4]
+			if versionIndex+1 < len(block) {
+				driver.Signer = block[versionIndex+1].value()
+			}
+		}
+		driver.Name = driver.OriginalName
+		if driver.Name == "" {
+			driver.Name = driver.InfName
+		}
+		drivers = append(drivers, driver)
+	}
+	return drivers
+}
+
+// pnputilDate 将 月/日/年 或 年/月/日 格式的日期转换为 YYYY-MM-DD
+func pnputilDate(first, second, third string) string {
+	layout, value := "1/2/2006", first+"/"+second+"/"+third
+	if len(first) == 4 {
+		layout = "2006/1/2"
+	}
+	date, err := time.Parse(layout, value)
+	if err != nil {
+		return ""
+	}
+	return date.Format("2006-01-02")
+}
+
+// pnpDevice pnputil /enum-devices 中的一个设备
+type pnpDevice struct {
+	instanceID  string
+	description string
+	driver      string // 发布名，如 oem12.inf
+	hardwareIDs []string
+}
+
+// parsePnputilDevices 解析 pnputil /enum-devices /ids 的输出
+//
+// 每个设备的第一个字段是实例ID，第二个是设备描述；第一个多值字段是硬件ID，之后的是兼容ID。
+func parsePnputilDevices(output string) []pnpDevice {
+	var devices []pnpDevice
+	for _, block := range pnputilBlocks(output) {
+		if len(block) < 2 || !strings.Contains(block[0].value(), `\`) {
+			continue
+		}
+		device := pnpDevice{
+			instanceID:  block[0].value(),
+			description: block[1].value(),
+		}
+		for _, field := range block[2:] {
+			value := field.value()
+			switch {
+			case device.driver == "" && strings.HasSuffix(strings.ToLower(value), ".inf"):
+				device.driver = value
+			case device.hardwareIDs == nil && strings.Contains(value, `\`):
+				device.hardwareIDs = append([]string{}, field.values...)
+			}
+		}
+		devices = append(devices, device)
+	}
+	return devices
+}
+
+// linuxDevice 绑定了驱动的设备
+type linuxDevice struct {
+	bus      string
+	driver   string
+	module   string
+	modalias string
+	class    string
+}
+
+// listLinuxDrivers 从 /sys/bus/*/devices 和 /proc/modules 列出驱动
+// 绑定到设备的驱动按模块（内置驱动按驱动名）合并，未绑定设备的已加载模块单独列出
+func (dm *DriverManager) listLinuxDrivers(ctx context.Context) ([]DriverInfo, error) {
+	devices, err := dm.linuxDevices()
+	if err != nil {
+		return nil, err
+	}
+	modules, err := dm.loadedModules()
+	if err != nil {
+		dm.logger.Warning("读取已加载模块失败", "error", err)
+	}
+	kernel := strings.TrimSpace(dm.readSysFile("proc/sys/kernel/osrelease"))
+
+	byKey := make(map[string]*DriverInfo)
+	var keys []string
+	add := func(key string, driver DriverInfo) *DriverInfo {
+		if existing, ok := byKey[key]; ok {
+			return existing
+		}
+		byKey[key] = &driver
+		keys = append(keys, key)
+		return byKey[key]
+	}
+
+	for _, device := range devices {
+		key := device.module
+		if key == "" {
+			key = "builtin:" + device.driver
+		}
+		driver := add(key, DriverInfo{Name: device.driver, Module: device.module, Class: device.class})
+		if driver.Class == "" {
+			driver.Class = device.class
+		}
+		if device.modalias != "" {
+			driver.DeviceIDs = appendUnique(driver.DeviceIDs, device.modalias)
+		}
+	}
+	for _, module := range modules {
+		add(module, DriverInfo{Name: module, Module: module})
+	}
+
+	drivers := make([]DriverInfo, 0, len(keys))
+	for _, key := range keys {
+		driver := byKey[key]
+		if driver.Module != "" {
+			dm.fillModuleInfo(ctx, driver)
+		}
+		if driver.Version == "" {
+			driver.Version = kernel
+		}
+		if driver.Class == "" {
+			driver.Class = "System"
+		}
+		drivers = append(drivers, *driver)
+	}
+	return drivers, nil
+}
+
+// linuxDevices 遍历 /sys/bus/*/devices 中绑定了驱动的设备
+func (dm *DriverManager) linuxDevices() ([]linuxDevice, error) {
+	busDirs, err := filepath.Glob(filepath.Join(dm.sysRoot, "sys", "bus", "*", "devices", "*"))
+	if err != nil {
+		return nil, err
+	}
+	if len(busDirs) == 0 {
+		return nil, fmt.Errorf("未找到 /sys/bus 设备信息")
+	}
+
+	var devices []linuxDevice
+	for _, dir := range busDirs {
+		driverPath, err := filepath.EvalSymlinks(filepath.Join(dir, "driver"))
+		if err != nil {
+			continue // 未绑定驱动
+		}
+		device := linuxDevice{
+			bus:      filepath.Base(filepath.Dir(filepath.Dir(dir))),
+			driver:   filepath.Base(driverPath),
+			modalias: strings.TrimSpace(dm.readFile(filepath.Join(dir, "modalias"))),
+		}
+		if modulePath, err := filepath.EvalSymlinks(filepath.Join(driverPath, "module")); err == nil {
+			device.module = filepath.Base(modulePath)
+		}
+		device.class = linuxDeviceClass(device.bus, strings.TrimSpace(dm.readFile(filepath.Join(dir, "class"))))
+		devices = append(devices, device)
+	}
+	return devices, nil
+}
+
+// linuxDeviceClass 按总线和PCI类别码推断驱动类别
+func linuxDeviceClass(bus, classCode string) string {
+	if bus == "pci" {
+		code := strings.TrimPrefix(strings.ToLower(classCode), "0x")
+		if len(code) >= 2 {
+			if name, ok := pciClassNames[code[:2]]; ok {
+				return name
+			}
+		}
+		return "System"
+	}
+	switch bus {
+	case "usb":
+		return "USB"
+	case "hid":
+		return "HIDClass"
+	case "scsi", "nvme", "ata":
+		return "DiskDrive"
+	case "sound":
+		return "Media"
+	}
+	return ""
+}
+
+// loadedModules 读取 /proc/modules 中已加载的模块名
+func (dm *DriverManager) loadedModules() ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(dm.sysRoot, "proc", "modules"))
+	if err != nil {
+		return nil, err
+	}
+	var modules []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			modules = append(modules, fields[0])
+		}
+	}
+	return modules, nil
+}
+
+// fillModuleInfo 用 modinfo 补充模块的版本、描述、作者和文件，不可用时读取 /sys/module
+func (dm *DriverManager) fillModuleInfo(ctx context.Context, driver *DriverInfo) {
+	if version := strings.TrimSpace(dm.readFile(filepath.Join(dm.sysRoot, "sys", "module", driver.Module, "version"))); version != "" {
+		driver.Version = version
+	}
+
+	output, err := dm.run(ctx, "modinfo", driver.Module)
+	if err != nil {
+		return
+	}
+	info := parseModinfo(string(output))
+	if info["version"] != "" {
+		driver.Version = info["version"]
+	}
+	if info["description"] != "" && driver.Name == driver.Module {
+		driver.Name = info["description"]
+	}
+	driver.Provider = info["author"]
+	if file := info["filename"]; file != "" && filepath.IsAbs(file) {
+		driver.Path = file
+		if stat, err := os.Stat(file); err == nil {
+			driver.Date = stat.ModTime().Format("2006-01-02")
+		}
+	}
+	if driver.Signer == "" {
+		driver.Signer = info["signer"]
+	}
+}
+
+// parseModinfo 解析 modinfo 输出，同名字段只取第一个
+func parseModinfo(output string) map[string]string {
+	info := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		if _, exists := info[key]; !exists {
+			info[key] = strings.TrimSpace(value)
+		}
+	}
+	return info
+}
+
+// readSysFile 读取 sysRoot 下的文件，失败时返回空字符串
+func (dm *DriverManager) readSysFile(path string) string {
+	return dm.readFile(filepath.Join(dm.sysRoot, path))
+}
+
+// readFile 读取文件，失败时返回空字符串
+func (dm *DriverManager) readFile(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// appendUnique 追加不重复的值
+func appendUnique(values []string, items ...string) []string {
+	for _, item := range items {
+		if !containsString(values, item) {
+			values = append(values, item)
+		}
+	}
+	return values
+}
@@ -0,0 +1,304 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"SystemReinstaller/utils"
+)
+
+// readPnputilFixture 读取 testdata/pnputil 中的 pnputil 输出，crlf 为真时转换为 Windows 换行
+func readPnputilFixture(t *testing.T, name string, crlf bool) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "pnputil", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := string(data)
+	if crlf {
+		output = strings.ReplaceAll(output, "\n", "\r\n")
+	}
+	return output
+}
+
+func TestParsePnputilDrivers(t *testing.T) {
+	want := []DriverInfo{
+		{
+			Name: "e1d68x64.inf", InfName: "oem3.inf", OriginalName: "e1d68x64.inf", Provider: "Intel",
+			ClassGUID: "{4d36e972-e325-11ce-bfc1-08002be10318}", Date: "2022-03-24", Version: "12.19.2.45",
+			Signer: "Microsoft Windows Hardware Compatibility Publisher",
+		},
+		{
+			Name: "nv_dispig.inf", InfName: "oem12.inf", OriginalName: "nv_dispig.inf", Provider: "NVIDIA",
+			ClassGUID: "{4d36e968-e325-11ce-bfc1-08002be10318}", Date: "2023-09-13", Version: "31.0.15.3742",
+			Signer: "Microsoft Windows Hardware Compatibility Publisher",
+		},
+		{
+			Name: "nvhdcx.inf", InfName: "oem15.inf", OriginalName: "nvhdcx.inf", Provider: "NVIDIA",
+			ClassGUID: "{e2f84ce7-8efa-411c-aa69-97454ca4cb57}", Date: "2023-07-12", Version: "1.3.40.14",
+			Signer: "Microsoft Windows Hardware Compatibility Publisher",
+		},
+	}
+	tests := []struct {
+		fixture string
+		classes []string
+	}{
+		{"enum-drivers-en.txt", []string{"Network adapters", "Display adapters", "Extensions"}},
+		{"enum-drivers-zh.txt", []string{"网络适配器", "显示适配器", "扩展"}},
+	}
+	for _, tt := range tests {
+		for _, crlf := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/crlf=%v", tt.fixture, crlf), func(t *testing.T) {
+				got := parsePnputilDrivers(readPnputilFixture(t, tt.fixture, crlf))
+				if len(got) != len(want) {
+					t.Fatalf("parsed %d drivers, want %d: %+v", len(got), len(want), got)
+				}
+				for i := range want {
+					expected := want[i]
+					expected.Class = tt.classes[i]
+					if !reflect.DeepEqual(got[i], expected) {
+						t.Errorf("driver %d = %+v, want %+v", i, got[i], expected)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestParsePnputilDriversFullWidthColon(t *testing.T) {
+	output := "发布名称：     oem3.inf\n原始名称：      e1d68x64.inf\n提供程序名称：      Intel\n类名：         网络适配器\n" +
+		"类 GUID：         {4d36e972-e325-11ce-bfc1-08002be10318}\n驱动程序版本：     2022/3/24 12.19.2.45\n签名者姓名：        Microsoft Windows\n"
+	got := parsePnputilDrivers(output)
+	if len(got) != 1 {
+		t.Fatalf("parsed %d drivers, want 1", len(got))
+	}
+	if got[0].InfName != "oem3.inf" || got[0].Class != "网络适配器" || got[0].Date != "2022-03-24" || got[0].Signer != "Microsoft Windows" {
+		t.Fatalf("driver = %+v", got[0])
+	}
+}
+
+func TestParsePnputilDevices(t *testing.T) {
+	want := []pnpDevice{
+		{
+			instanceID:  `PCI\VEN_8086&DEV_15F3&SUBSYS_00008086&REV_03\6&2f4b1f3a&0&00E0`,
+			description: "Intel(R) Ethernet Controller I225-V",
+			driver:      "oem3.inf",
+			hardwareIDs: []string{
+				`PCI\VEN_8086&DEV_15F3&SUBSYS_00008086&REV_03`,
+				`PCI\VEN_8086&DEV_15F3&SUBSYS_00008086`,
+				`PCI\VEN_8086&DEV_15F3&CC_020000`,
+				`PCI\VEN_8086&DEV_15F3&CC_0200`,
+			},
+		},
+		{
+			instanceID:  `PCI\VEN_10DE&DEV_2684&SUBSYS_889D1043&REV_A1\4&1b2e3c4d&0&0008`,
+			description: "NVIDIA GeForce RTX 4090",
+			driver:      "oem12.inf",
+			hardwareIDs: []string{
+				`PCI\VEN_10DE&DEV_2684&SUBSYS_889D1043&REV_A1`,
+				`PCI\VEN_10DE&DEV_2684&SUBSYS_889D1043`,
+				`PCI\VEN_10DE&DEV_2684&CC_030000`,
+				`PCI\VEN_10DE&DEV_2684&CC_0300`,
+			},
+		},
+		{
+			instanceID:  `USB\VID_046D&PID_C52B\5&3a1b2c3d&0&2`,
+			description: "USB Composite Device",
+			driver:      "usb.inf",
+			hardwareIDs: []string{`USB\VID_046D&PID_C52B&REV_1211`, `USB\VID_046D&PID_C52B`},
+		},
+		{
+			instanceID:  `ROOT\BasicDisplay\0000`,
+			description: "Microsoft Basic Display Driver",
+			driver:      "machine.inf",
+			hardwareIDs: []string{`ROOT\BasicDisplay`},
+		},
+	}
+	for _, fixture := range []string{"enum-devices-en.txt", "enum-devices-zh.txt"} {
+		for _, crlf := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/crlf=%v", fixture, crlf), func(t *testing.T) {
+				got := parsePnputilDevices(readPnputilFixture(t, fixture, crlf))
+				if !reflect.DeepEqual(got, want) {
+					t.Fatalf("devices = %+v, want %+v", got, want)
+				}
+			})
+		}
+	}
+}
+
+// fakeDriverCommands 按 "命令 参数" 返回预设输出的命令执行器，未预设的命令返回错误
+func fakeDriverCommands(outputs map[string]string) func(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		command := strings.Join(append([]string{name}, args...), " ")
+		output, ok := outputs[command]
+		if !ok {
+			return nil, fmt.Errorf("执行 %s 失败: 未找到命令", name)
+		}
+		return []byte(output), nil
+	}
+}
+
+func TestListWindowsDrivers(t *testing.T) {
+	dm := &DriverManager{
+		logger: utils.NewNopLogger(),
+		run: fakeDriverCommands(map[string]string{
+			"pnputil /enum-drivers":      readPnputilFixture(t, "enum-drivers-zh.txt", true),
+			"pnputil /enum-devices /ids": readPnputilFixture(t, "enum-devices-zh.txt", true),
+		}),
+	}
+	drivers, err := dm.listWindowsDrivers(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drivers) != 3 {
+		t.Fatalf("listed %d drivers, want 3", len(drivers))
+	}
+	if drivers[0].Name != "Intel(R) Ethernet Controller I225-V" || len(drivers[0].DeviceIDs) != 4 ||
+		drivers[0].DeviceIDs[0] != `PCI\VEN_8086&DEV_15F3&SUBSYS_00008086&REV_03` {
+		t.Errorf("oem3.inf = %+v", drivers[0])
+	}
+	if drivers[1].Name != "NVIDIA GeForce RTX 4090" || len(drivers[1].DeviceIDs) != 4 {
+		t.Errorf("oem12.inf = %+v", drivers[1])
+	}
+	if drivers[2].Name != "nvhdcx.inf" || drivers[2].DeviceIDs != nil {
+		t.Errorf("oem15.inf = %+v", drivers[2])
+	}
+
+	// 旧版 pnputil 不支持 /enum-devices 时仍返回驱动列表
+	dm.run = fakeDriverCommands(map[string]string{"pnputil /enum-drivers": readPnputilFixture(t, "enum-drivers-en.txt", true)})
+	drivers, err = dm.listWindowsDrivers(context.Background())
+	if err != nil || len(drivers) != 3 || drivers[0].DeviceIDs != nil {
+		t.Fatalf("drivers = %+v, err = %v", drivers, err)
+	}
+}
+
+// fakeSysfsDevice 假 /sys 树中的一个设备
+type fakeSysfsDevice struct {
+	bus      string
+	name     string
+	driver   string // 为空表示未绑定驱动
+	module   string // 为空表示内置驱动
+	modalias string
+	class    string
+}
+
+// newFakeSysRoot 按真实 sysfs 的布局创建设备、驱动和模块的符号链接，以及 /proc 中的文件
+func newFakeSysRoot(t *testing.T, devices []fakeSysfsDevice, modules, kernel string) string {
+	t.Helper()
+	root := t.TempDir()
+	mkdir := func(dir string) {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write := func(name, content string) {
+		mkdir(filepath.Dir(name))
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	symlink := func(target, name string) {
+		mkdir(filepath.Dir(name))
+		if err := os.Symlink(target, name); err != nil && !os.IsExist(err) {
+			t.Fatal(err)
+		}
+	}
+
+	for _, device := range devices {
+		deviceDir := filepath.Join(root, "sys", "devices", "platform", device.name)
+		mkdir(deviceDir)
+		symlink(deviceDir, filepath.Join(root, "sys", "bus", device.bus, "devices", device.name))
+		if device.modalias != "" {
+			write(filepath.Join(deviceDir, "modalias"), device.modalias+"\n")
+		}
+		if device.class != "" {
+			write(filepath.Join(deviceDir, "class"), device.class+"\n")
+		}
+		if device.driver == "" {
+			continue
+		}
+		driverDir := filepath.Join(root, "sys", "bus", device.bus, "drivers", device.driver)
+		mkdir(driverDir)
+		symlink(driverDir, filepath.Join(deviceDir, "driver"))
+		if device.module != "" {
+			moduleDir := filepath.Join(root, "sys", "module", device.module)
+			mkdir(moduleDir)
+			symlink(moduleDir, filepath.Join(driverDir, "module"))
+		}
+	}
+	write(filepath.Join(root, "proc", "modules"), modules)
+	write(filepath.Join(root, "proc", "sys", "kernel", "osrelease"), kernel+"\n")
+	return root
+}
+
+func TestListLinuxDrivers(t *testing.T) {
+	root := newFakeSysRoot(t, []fakeSysfsDevice{
+		{bus: "pci", name: "0000:00:1f.6", driver: "e1000e", module: "e1000e", modalias: "pci:v00008086d000015BCsv00001028sd000008B9bc02sc00i00", class: "0x020000"},
+		{bus: "pci", name: "0000:00:14.0", driver: "xhci_hcd", modalias: "pci:v00008086d0000A36Dsv00001028sd000008B9bc0Csc03i30", class: "0x0c0330"},
+		{bus: "pci", name: "0000:00:16.0", modalias: "pci:v00008086d0000A360sv00001028sd000008B9bc07sc80i00", class: "0x078000"},
+		{bus: "usb", name: "1-3:1.0", driver: "usbhid", module: "usbhid", modalias: "usb:v046DpC52Bd1211dc00dsc00dp00ic03isc01ip01in00"},
+	}, "e1000e 327680 0 - Live 0x0000000000000000\nusbhid 77824 0 - Live 0x0000000000000000\nsnd_hda_intel 61440 4 - Live 0x0000000000000000\n", "6.8.0-45-generic")
+	if err := os.MkdirAll(filepath.Join(root, "sys", "module", "usbhid"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "sys", "module", "usbhid", "version"), []byte("1.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dm := &DriverManager{
+		sysRoot: root,
+		logger:  utils.NewNopLogger(),
+		run: fakeDriverCommands(map[string]string{
+			"modinfo e1000e": "filename:       /lib/modules/6.8.0-45-generic/kernel/drivers/net/ethernet/intel/e1000e/e1000e.ko.zst\n" +
+				"version:        3.2.6-k\nlicense:        GPL v2\ndescription:    Intel(R) PRO/1000 Network Driver\n" +
+				"author:         Intel Corporation, <linux.nics@intel.com>\nalias:          pci:v00008086d000015BCsv*sd*bc*sc*i*\n" +
+				"signer:         Build time autogenerated kernel key\n",
+		}),
+	}
+	drivers, err := dm.listLinuxDrivers(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byName := make(map[string]DriverInfo)
+	for _, driver := range drivers {
+		byName[driver.Name] = driver
+	}
+	if len(byName) != 4 {
+		t.Fatalf("drivers = %+v, want e1000e, xhci_hcd, usbhid and snd_hda_intel", drivers)
+	}
+	want := map[string]DriverInfo{
+		"Intel(R) PRO/1000 Network Driver": {
+			Name: "Intel(R) PRO/1000 Network Driver", Module: "e1000e", Version: "3.2.6-k", Class: "Net",
+			Provider: "Intel Corporation, <linux.nics@intel.com>", Signer: "Build time autogenerated kernel key",
+			DeviceIDs: []string{"pci:v00008086d000015BCsv00001028sd000008B9bc02sc00i00"},
+			Path:      "/lib/modules/6.8.0-45-generic/kernel/drivers/net/ethernet/intel/e1000e/e1000e.ko.zst",
+		},
+		"xhci_hcd": {
+			Name: "xhci_hcd", Version: "6.8.0-45-generic", Class: "USB",
+			DeviceIDs: []string{"pci:v00008086d0000A36Dsv00001028sd000008B9bc0Csc03i30"},
+		},
+		"usbhid": {
+			Name: "usbhid", Module: "usbhid", Version: "1.0", Class: "USB",
+			DeviceIDs: []string{"usb:v046DpC52Bd1211dc00dsc00dp00ic03isc01ip01in00"},
+		},
+		"snd_hda_intel": {Name: "snd_hda_intel", Module: "snd_hda_intel", Version: "6.8.0-45-generic", Class: "System"},
+	}
+	for name, expected := range want {
+		if got, ok := byName[name]; !ok || !reflect.DeepEqual(got, expected) {
+			t.Errorf("driver %s = %+v, want %+v", name, got, expected)
+		}
+	}
+}
+
+func TestListLinuxDriversWithoutSysfs(t *testing.T) {
+	dm := &DriverManager{sysRoot: t.TempDir(), logger: utils.NewNopLogger(), run: fakeDriverCommands(nil)}
+	if _, err := dm.listLinuxDrivers(context.Background()); err == nil {
+		t.Fatal("listLinuxDrivers succeeded without /sys/bus")
+	}
+}
@@ -0,0 +1,548 @@
+package core
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	// 硬件ID中的总线、厂商和设备ID，忽略子系统、类别和版本
+	windowsHardwareIDPattern = regexp.MustCompile(`(?i)^(pci)\\ven_([0-9a-f]{4})&dev_([0-9a-f]{4})|^(usb)\\vid_([0-9a-f]{4})&pid_([0-9a-f]{4})`)
+	linuxHardwareIDPattern   = regexp.MustCompile(`(?i)^(pci):v0000([0-9a-f]{4})d0000([0-9a-f]{4})|^(usb):v([0-9a-f]{4})p([0-9a-f]{4})`)
+
+	// 内核模块名，恢复时用于拼接路径和调用 modprobe
+	moduleNamePattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_-]*$`)
+)
+
+// DriverBackup 解压后的驱动备份
+type DriverBackup struct {
+	Path     string                `json:"path"` // 归档路径
+	Dir      string                `json:"dir"`  // 解压目录
+	Manifest *DriverBackupManifest `json:"manifest"`
+}
+
+// DriverRestoreItem 恢复时每个驱动或固件的处理结果
+type DriverRestoreItem struct {
+	Name    string `json:"name"`
+	InfName string `json:"inf_name,omitempty"`
+	Module  string `json:"module,omitempty"`
+	Status  string `json:"status"` // installed, skipped, failed
+	Message string `json:"message,omitempty"`
+}
+
+// DriverRestoreResult 恢复结果
+type DriverRestoreResult struct {
+	Path      string              `json:"path"`
+	Installed int                 `json:"installed"`
+	Skipped   int                 `json:"skipped"`
+	Failed    int                 `json:"failed"`
+	Items     []DriverRestoreItem `json:"items"`
+}
+
+// add 记录一项结果
+func (r *DriverRestoreResult) add(item DriverRestoreItem) {
+	switch item.Status {
+	case "installed":
+		r.Installed++
+	case "skipped":
+		r.Skipped++
+	case "failed":
+		r.Failed++
+	}
+	r.Items = append(r.Items, item)
+}
+
+// driverBackupFormat 按扩展名判断归档格式
+func driverBackupFormat(archivePath string) (string, error) {
+	name := strings.ToLower(archivePath)
+	for _, format := range []string{DriverBackupTarZst, DriverBackupZip, DriverBackup7z} {
+		if strings.HasSuffix(name, "."+format) {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("无法识别的备份格式: %s", filepath.Base(archivePath))
+}
+
+// ExtractDriverBackup 将备份归档解压到 dir，读取清单并校验所有文件的哈希
+func ExtractDriverBackup(ctx context.Context, archivePath, dir string) (*DriverBackup, error) {
+	format, err := driverBackupFormat(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case DriverBackupZip:
+		err = extractZip(ctx, archivePath, dir)
+	case DriverBackupTarZst:
+		err = extractTarZst(ctx, archivePath, dir)
+	case DriverBackup7z:
+		err = extractSevenZip(ctx, archivePath, dir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解压备份失败: %v", err)
+	}
+
+	backup := &DriverBackup{Path: archivePath, Dir: dir}
+	if backup.Manifest, err = readDriverManifest(dir); err != nil {
+		return nil, err
+	}
+	if err := backup.Verify(); err != nil {
+		return nil, err
+	}
+	return backup, nil
+}
+
+// readDriverManifest 读取解压目录中的清单
+func readDriverManifest(dir string) (*DriverBackupManifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, driverManifestName))
+	if err != nil {
+		return nil, fmt.Errorf("备份中没有清单文件: %v", err)
+	}
+	var manifest DriverBackupManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("解析备份清单失败: %v", err)
+	}
+	if manifest.Version > driverBackupVersion {
+		return nil, fmt.Errorf("不支持的备份清单版本: %d", manifest.Version)
+	}
+	// 清单没有签名，模块名会被拼进安装路径并作为 modprobe 的参数
+	for _, driver := range manifest.Drivers {
+		if err := validateModuleName(driver.Module); err != nil {
+			return nil, err
+		}
+	}
+	for _, firmware := range manifest.Firmware {
+		if err := validateModuleName(firmware.Module); err != nil {
+			return nil, err
+		}
+	}
+	return &manifest, nil
+}
+
+// validateModuleName 校验清单中的模块名，Windows 驱动没有模块名
+func validateModuleName(module string) error {
+	if module != "" && !moduleNamePattern.MatchString(module) {
+		return fmt.Errorf("备份清单中的模块名无效: %q", module)
+	}
+	return nil
+}
+
+// Verify 校验清单中所有文件的大小和哈希
+func (b *DriverBackup) Verify() error {
+	var files []DriverBackupFile
+	for _, driver := range b.Manifest.Drivers {
+		files = append(files, driver.Files...)
+	}
+	for _, firmware := range b.Manifest.Firmware {
+		files = append(files, firmware.DriverBackupFile)
+	}
+
+	for _, file := range files {
+		local, err := backupLocalPath(b.Dir, file.Path)
+		if err != nil {
+			return err
+		}
+		info, err := os.Stat(local)
+		if err != nil {
+			return fmt.Errorf("备份缺少文件 %s", file.Path)
+		}
+		sum, err := fileSHA256(local)
+		if err != nil {
+			return err
+		}
+		if info.Size() != file.Size || !strings.EqualFold(sum, file.SHA256) {
+			return fmt.Errorf("备份文件 %s 校验失败，备份可能已损坏", file.Path)
+		}
+	}
+	return nil
+}
+
+// backupLocalPath 将归档内的相对路径转换为 dir 下的本地路径，拒绝越出 dir 的路径
+func backupLocalPath(dir, name string) (string, error) {
+	local := filepath.FromSlash(name)
+	if !filepath.IsLocal(local) {
+		return "", fmt.Errorf("备份中的路径无效: %s", name)
+	}
+	return filepath.Join(dir, local), nil
+}
+
+// extractFile 将 r 写入 dir 下的 name
+func extractFile(dir, name string, r io.Reader) error {
+	target, err := backupLocalPath(dir, name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// extractZip 解压 zip 归档
+func extractZip(ctx context.Context, archivePath, dir string) error {
+	archive, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	for _, file := range archive.File {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		r, err := file.Open()
+		if err != nil {
+			return err
+		}
+		err = extractFile(dir, file.Name, r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractTarZst 解压 tar.zst 归档，只处理普通文件
+func extractTarZst(ctx context.Context, archivePath, dir string) error {
+	in, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	decoder, err := zstd.NewReader(in)
+	if err != nil {
+		return err
+	}
+	defer decoder.Close()
+
+	archive := tar.NewReader(decoder)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := extractFile(dir, header.Name, archive); err != nil {
+			return err
+		}
+	}
+}
+
+// extractSevenZip 调用 7-Zip 解压
+func extractSevenZip(ctx context.Context, archivePath, dir string) error {
+	sevenZip, err := find7z()
+	if err != nil {
+		return err
+	}
+	output, err := exec.CommandContext(ctx, sevenZip, "x", "-y", "-bso0", "-bsp0", "-o"+dir, archivePath).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// hardwareIDKey 用于比较的硬件ID
+// PCI 和 USB 设备统一为 "总线:厂商:设备"，使 Windows 硬件ID与 Linux modalias 可以互相匹配，
+// 例如 PCI\VEN_8086&DEV_15F3&SUBSYS_00008086 和 pci:v00008086d000015F3sv... 都是 pci:8086:15f3；
+// 其他ID原样比较（不区分大小写）
+func hardwareIDKey(id string) string {
+	id = strings.TrimSpace(id)
+	match := windowsHardwareIDPattern.FindStringSubmatch(id)
+	if match == nil {
+		match = linuxHardwareIDPattern.FindStringSubmatch(id)
+	}
+	if match == nil {
+		return strings.ToLower(id)
+	}
+	for i := 1; i+2 < len(match); i += 3 {
+		if match[i] != "" {
+			return strings.ToLower(match[i] + ":" + match[i+1] + ":" + match[i+2])
+		}
+	}
+	return strings.ToLower(id)
+}
+
+// hardwareIDSet 当前硬件ID的集合
+type hardwareIDSet map[string]bool
+
+// newHardwareIDSet 由硬件ID列表创建集合
+func newHardwareIDSet(ids []string) hardwareIDSet {
+	set := make(hardwareIDSet, len(ids))
+	for _, id := range ids {
+		if key := hardwareIDKey(id); key != "" {
+			set[key] = true
+		}
+	}
+	return set
+}
+
+// matches 是否有任意一个ID存在于当前硬件中
+func (s hardwareIDSet) matches(ids []string) bool {
+	for _, id := range ids {
+		if s[hardwareIDKey(id)] {
+			return true
+		}
+	}
+	return false
+}
+
+// HardwareIDs 列出本机所有设备（包括未安装驱动的设备）的硬件ID
+func (dm *DriverManager) HardwareIDs(ctx context.Context) ([]string, error) {
+	switch runtime.GOOS {
+	case "windows":
+		output, err := dm.run(ctx, "pnputil", "/enum-devices", "/ids")
+		if err != nil {
+			return nil, fmt.Errorf("获取设备硬件ID失败: %v", err)
+		}
+		var ids []string
+		for _, device := range parsePnputilDevices(string(output)) {
+			ids = appendUnique(ids, device.hardwareIDs...)
+		}
+		return ids, nil
+	case "linux":
+		files, err := filepath.Glob(filepath.Join(dm.sysRoot, "sys", "bus", "*", "devices", "*", "modalias"))
+		if err != nil {
+			return nil, err
+		}
+		var ids []string
+		for _, file := range files {
+			if modalias := strings.TrimSpace(dm.readFile(file)); modalias != "" {
+				ids = appendUnique(ids, modalias)
+			}
+		}
+		return ids, nil
+	}
+	return nil, fmt.Errorf("不支持在 %s 上获取硬件ID", runtime.GOOS)
+}
+
+// MatchedDriverDirs 解压备份到 dir，返回与本机硬件匹配的驱动目录，用于 Windows 安装时注入
+func (dm *DriverManager) MatchedDriverDirs(ctx context.Context, archivePath, dir string) ([]string, error) {
+	backup, err := ExtractDriverBackup(ctx, archivePath, dir)
+	if err != nil {
+		return nil, err
+	}
+	if backup.Manifest.OS != "windows" {
+		return nil, fmt.Errorf("备份来自 %s 系统，不能注入到 Windows 安装", backup.Manifest.OS)
+	}
+	ids, err := dm.HardwareIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	present := newHardwareIDSet(ids)
+
+	var dirs []string
+	for _, driver := range backup.Manifest.Drivers {
+		if !present.matches(driver.DeviceIDs) {
+			continue
+		}
+		driverDir, err := backupLocalPath(backup.Dir, windowsDriverDir(driver.DriverInfo))
+		if err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, driverDir)
+		dm.logger.Debug("驱动与本机硬件匹配", "driver", driver.InfName, "name", driver.Name)
+	}
+	// 一个都不匹配通常是选错了备份，继续安装会让新系统缺少驱动
+	if len(dirs) == 0 && len(backup.Manifest.Drivers) > 0 {
+		dm.logger.Error("驱动备份与本机硬件都不匹配", "backup", archivePath, "drivers", len(backup.Manifest.Drivers), "hardware_ids", len(ids))
+		return nil, fmt.Errorf("备份中的 %d 个驱动都与本机硬件不匹配，请确认选择了本机的驱动备份", len(backup.Manifest.Drivers))
+	}
+	return dirs, nil
+}
+
+// Restore 从备份恢复与本机硬件匹配的驱动，没有匹配硬件的驱动会被跳过
+func (dm *DriverManager) Restore(ctx context.Context, archivePath string, progress func(DriverBackupProgress)) (*DriverRestoreResult, error) {
+	reporter := driverBackupReporter{report: progress}
+
+	reporter.stage("extract", 0, 0, 0, 0, "正在解压备份")
+	dir, err := os.MkdirTemp("", "driver-restore-*")
+	if err != nil {
+		return nil, fmt.Errorf("创建临时目录失败: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	backup, err := ExtractDriverBackup(ctx, archivePath, dir)
+	if err != nil {
+		return nil, err
+	}
+	if backup.Manifest.OS != runtime.GOOS {
+		return nil, fmt.Errorf("备份来自 %s 系统，不能在 %s 上恢复", backup.Manifest.OS, runtime.GOOS)
+	}
+
+	reporter.stage("install", 30, 30, 0, 0, "正在匹配硬件")
+	ids, err := dm.HardwareIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	present := newHardwareIDSet(ids)
+
+	result := &DriverRestoreResult{Path: archivePath}
+	switch runtime.GOOS {
+	case "windows":
+		dm.restoreWindowsDrivers(ctx, backup, present, result, reporter)
+	case "linux":
+		dm.restoreLinuxDrivers(ctx, backup, present, result, reporter)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	dm.logger.Info("驱动恢复完成", "path", archivePath, "installed", result.Installed, "skipped", result.Skipped, "failed", result.Failed)
+	reporter.stage("done", 100, 100, 0, 0, "恢复完成")
+	return result, nil
+}
+
+// restoreWindowsDrivers 用 pnputil /add-driver /install 安装匹配的驱动
+func (dm *DriverManager) restoreWindowsDrivers(ctx context.Context, backup *DriverBackup, present hardwareIDSet, result *DriverRestoreResult, reporter driverBackupReporter) {
+	drivers := backup.Manifest.Drivers
+	for i, driver := range drivers {
+		if ctx.Err() != nil {
+			return
+		}
+		reporter.stage("install", 30, 99, int64(i), int64(len(drivers)), "正在安装 "+driver.Name)
+
+		item := DriverRestoreItem{Name: driver.Name, InfName: driver.InfName}
+		driverDir, err := backupLocalPath(backup.Dir, windowsDriverDir(driver.DriverInfo))
+		switch {
+		case err != nil:
+			item.Status, item.Message = "failed", err.Error()
+		case !present.matches(driver.DeviceIDs):
+			item.Status, item.Message = "skipped", "本机没有匹配的硬件"
+		default:
+			item.Status = "installed"
+			if _, err := dm.run(ctx, "pnputil", "/add-driver", filepath.Join(driverDir, "*.inf"), "/subdirs", "/install"); err != nil {
+				item.Status, item.Message = "failed", err.Error()
+				dm.logger.Warning("安装驱动失败", "driver", driver.InfName, "error", err)
+			}
+		}
+		result.add(item)
+	}
+}
+
+// restoreLinuxDrivers 将匹配的模块复制到 /lib/modules/<内核>/updates，固件复制到 /lib/firmware，再加载模块
+func (dm *DriverManager) restoreLinuxDrivers(ctx context.Context, backup *DriverBackup, present hardwareIDSet, result *DriverRestoreResult, reporter driverBackupReporter) {
+	kernel := strings.TrimSpace(dm.readSysFile("proc/sys/kernel/osrelease"))
+	manifest := backup.Manifest
+	total := int64(len(manifest.Drivers) + len(manifest.Firmware))
+
+	for i, firmware := range manifest.Firmware {
+		if ctx.Err() != nil {
+			return
+		}
+		reporter.stage("install", 30, 60, int64(i), total, "正在恢复固件 "+firmware.Name)
+		item := DriverRestoreItem{Name: firmware.Name, Module: firmware.Module}
+		target, err := backupLocalPath(filepath.Join(dm.sysRoot, "lib", "firmware"), firmware.Name)
+		switch {
+		case err != nil:
+			item.Status, item.Message = "failed", err.Error()
+		case !present.matches(firmware.DeviceIDs):
+			item.Status, item.Message = "skipped", "本机没有匹配的硬件"
+		case fileExists(target):
+			item.Status, item.Message = "skipped", "固件已存在"
+		default:
+			item.Status = "installed"
+			if err := copyRestoreFile(backup.Dir, firmware.Path, target); err != nil {
+				item.Status, item.Message = "failed", err.Error()
+			}
+		}
+		result.add(item)
+	}
+
+	var installed []int
+	for _, driver := range manifest.Drivers {
+		if ctx.Err() != nil {
+			return
+		}
+		item := DriverRestoreItem{Name: driver.Name, Module: driver.Module}
+		switch {
+		case !present.matches(driver.DeviceIDs):
+			item.Status, item.Message = "skipped", "本机没有匹配的硬件"
+		case manifest.Kernel != kernel:
+			item.Status, item.Message = "skipped", fmt.Sprintf("模块为内核 %s 编译，当前内核为 %s", manifest.Kernel, kernel)
+		default:
+			item.Status = "installed"
+			for _, file := range driver.Files {
+				target := filepath.Join(dm.sysRoot, "lib", "modules", kernel, "updates", driver.Module, path.Base(file.Path))
+				if err := copyRestoreFile(backup.Dir, file.Path, target); err != nil {
+					item.Status, item.Message = "failed", err.Error()
+					break
+				}
+			}
+		}
+		if item.Status == "installed" {
+			installed = append(installed, len(result.Items))
+		}
+		result.add(item)
+	}
+	if len(installed) == 0 {
+		return
+	}
+
+	reporter.stage("install", 30, 99, total-1, total, "正在加载模块")
+	if _, err := dm.run(ctx, "depmod", "-a", kernel); err != nil {
+		dm.logger.Warning("更新模块依赖失败", "error", err)
+	}
+	for _, index := range installed {
+		item := &result.Items[index]
+		if _, err := dm.run(ctx, "modprobe", item.Module); err != nil {
+			item.Status, item.Message = "failed", fmt.Sprintf("加载模块失败: %v", err)
+			result.Installed--
+			result.Failed++
+		}
+	}
+}
+
+// copyRestoreFile 将解压目录中的文件复制到 target
+func copyRestoreFile(dir, name, target string) error {
+	source, err := backupLocalPath(dir, name)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return extractFile(filepath.Dir(target), filepath.Base(target), in)
+}
+
+// fileExists 文件是否存在
+func fileExists(filePath string) bool {
+	_, err := os.Stat(filePath)
+	return err == nil
+}
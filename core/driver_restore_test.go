@@ -0,0 +1,380 @@
+package core
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"SystemReinstaller/utils"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// writeTestDriverBackup 按清单和文件内容打包一个驱动备份，文件的大小和哈希自动写入清单
+func writeTestDriverBackup(t *testing.T, manifest *DriverBackupManifest, files map[string]string, format string) string {
+	t.Helper()
+	staging := t.TempDir()
+	for name, content := range files {
+		target := filepath.Join(staging, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(target, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range manifest.Drivers {
+		for j, file := range manifest.Drivers[i].Files {
+			hashed, err := hashBackupFile(staging, file.Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			manifest.Drivers[i].Files[j] = hashed
+		}
+	}
+	for i, firmware := range manifest.Firmware {
+		hashed, err := hashBackupFile(staging, firmware.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		manifest.Firmware[i].DriverBackupFile = hashed
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(staging, driverManifestName), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	extension, err := driverBackupExtension(format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archivePath := filepath.Join(t.TempDir(), "drivers_test"+extension)
+	if err := writeStreamArchive(context.Background(), staging, archivePath, format, driverBackupReporter{}); err != nil {
+		t.Fatal(err)
+	}
+	return archivePath
+}
+
+func TestHardwareIDKey(t *testing.T) {
+	tests := []struct {
+		id   string
+		want string
+	}{
+		{`PCI\VEN_8086&DEV_15F3&SUBSYS_00008086&REV_03`, "pci:8086:15f3"},
+		{`PCI\VEN_8086&DEV_15F3&CC_0200`, "pci:8086:15f3"},
+		{`pci\ven_10de&dev_2684`, "pci:10de:2684"},
+		{"pci:v00008086d000015F3sv00008086sd00000000bc02sc00i00", "pci:8086:15f3"},
+		{`USB\VID_046D&PID_C52B&REV_1211`, "usb:046d:c52b"},
+		{"usb:v046DpC52Bd1211dc00dsc00dp00ic03isc01ip01in00", "usb:046d:c52b"},
+		{`PCI\VEN_8086&CC_0200`, `pci\ven_8086&cc_0200`},
+		{` ROOT\BasicDisplay `, `root\basicdisplay`},
+		{"acpi:PNP0A08:", "acpi:pnp0a08:"},
+	}
+	for _, tt := range tests {
+		if got := hardwareIDKey(tt.id); got != tt.want {
+			t.Errorf("hardwareIDKey(%q) = %q, want %q", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestHardwareIDSetMatchesAcrossSystems(t *testing.T) {
+	linux := newHardwareIDSet([]string{
+		"pci:v00008086d000015F3sv00008086sd00000000bc02sc00i00",
+		"usb:v046DpC52Bd1211dc00dsc00dp00ic03isc01ip01in00",
+	})
+	if !linux.matches([]string{`PCI\VEN_8086&DEV_15F3&SUBSYS_12345678&REV_03`}) {
+		t.Error("Windows PCI ID did not match Linux modalias")
+	}
+	if !linux.matches([]string{`USB\VID_046D&PID_C52B`}) {
+		t.Error("Windows USB ID did not match Linux modalias")
+	}
+	if linux.matches([]string{`PCI\VEN_10DE&DEV_2684&SUBSYS_889D1043`, `PCI\VEN_8086&CC_0200`}) {
+		t.Error("unrelated device matched")
+	}
+
+	windows := newHardwareIDSet([]string{`PCI\VEN_8086&DEV_15F3&SUBSYS_00008086&REV_03`})
+	if !windows.matches([]string{"pci:v00008086d000015F3sv*sd*bc*sc*i*"}) {
+		t.Error("Linux modalias did not match Windows ID")
+	}
+}
+
+// newWindowsBackupFixture 包含 Intel 网卡和 NVIDIA 显卡驱动的 Windows 备份
+func newWindowsBackupFixture(t *testing.T, format string) string {
+	t.Helper()
+	manifest := &DriverBackupManifest{
+		Version: driverBackupVersion,
+		OS:      "windows",
+		Arch:    "amd64",
+		Drivers: []DriverBackupEntry{
+			{
+				DriverInfo: DriverInfo{Name: "Intel(R) Ethernet Controller I225-V", InfName: "oem3.inf", OriginalName: "e1d68x64.inf",
+					DeviceIDs: []string{`PCI\VEN_8086&DEV_15F3&SUBSYS_00008086&REV_03`, `PCI\VEN_8086&DEV_15F3&CC_0200`}},
+				Files: []DriverBackupFile{{Path: "drivers/oem3/e1d68x64.inf"}, {Path: "drivers/oem3/e1d68x64.sys"}},
+			},
+			{
+				DriverInfo: DriverInfo{Name: "NVIDIA GeForce RTX 4090", InfName: "oem12.inf", OriginalName: "nv_dispig.inf",
+					DeviceIDs: []string{`PCI\VEN_10DE&DEV_2684&SUBSYS_889D1043&REV_A1`}},
+				Files: []DriverBackupFile{{Path: "drivers/oem12/nv_dispig.inf"}},
+			},
+		},
+	}
+	return writeTestDriverBackup(t, manifest, map[string]string{
+		"drivers/oem3/e1d68x64.inf":   "[Version]\r\nSignature=\"$WINDOWS NT$\"\r\n",
+		"drivers/oem3/e1d68x64.sys":   strings.Repeat("\x00driver", 512),
+		"drivers/oem12/nv_dispig.inf": "[Version]\r\nSignature=\"$WINDOWS NT$\"\r\n",
+	}, format)
+}
+
+// newHardwareDriverManager 本机只有 Intel 网卡的驱动管理器，Linux 读取假 /sys，Windows 使用假 pnputil
+func newHardwareDriverManager(t *testing.T, present bool) *DriverManager {
+	t.Helper()
+	var devices []fakeSysfsDevice
+	pnputil := "Microsoft PnP Utility\r\n\r\n"
+	if present {
+		devices = append(devices, fakeSysfsDevice{bus: "pci", name: "0000:03:00.0", driver: "igc", module: "igc",
+			modalias: "pci:v00008086d000015F3sv00008086sd00000000bc02sc00i00", class: "0x020000"})
+		pnputil += "Instance ID:                PCI\\VEN_8086&DEV_15F3&SUBSYS_00008086&REV_03\\6&2f4b1f3a&0&00E0\r\n" +
+			"Device Description:         Intel(R) Ethernet Controller I225-V\r\n" +
+			"Hardware IDs:               PCI\\VEN_8086&DEV_15F3&SUBSYS_00008086&REV_03\r\n" +
+			"                            PCI\\VEN_8086&DEV_15F3&SUBSYS_00008086\r\n\r\n"
+	}
+	devices = append(devices, fakeSysfsDevice{bus: "usb", name: "1-3:1.0", driver: "usbhid", module: "usbhid",
+		modalias: "usb:v046DpC52Bd1211dc00dsc00dp00ic03isc01ip01in00"})
+	pnputil += "Instance ID:                USB\\VID_046D&PID_C52B\\5&3a1b2c3d&0&2\r\n" +
+		"Device Description:         USB Composite Device\r\n" +
+		"Hardware IDs:               USB\\VID_046D&PID_C52B&REV_1211\r\n" +
+		"                            USB\\VID_046D&PID_C52B\r\n"
+
+	return &DriverManager{
+		sysRoot: newFakeSysRoot(t, devices, "", "6.8.0-45-generic"),
+		logger:  utils.NewNopLogger(),
+		run:     fakeDriverCommands(map[string]string{"pnputil /enum-devices /ids": pnputil}),
+	}
+}
+
+func TestMatchedDriverDirs(t *testing.T) {
+	archivePath := newWindowsBackupFixture(t, DriverBackupZip)
+
+	dm := newHardwareDriverManager(t, true)
+	dir := t.TempDir()
+	dirs, err := dm.MatchedDriverDirs(context.Background(), archivePath, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) != 1 || dirs[0] != filepath.Join(dir, "drivers", "oem3") {
+		t.Fatalf("dirs = %v, want only drivers/oem3", dirs)
+	}
+	if _, err := os.Stat(filepath.Join(dirs[0], "e1d68x64.sys")); err != nil {
+		t.Fatalf("matched driver not extracted: %v", err)
+	}
+}
+
+func TestMatchedDriverDirsNoMatch(t *testing.T) {
+	archivePath := newWindowsBackupFixture(t, DriverBackupZip)
+
+	dm := newHardwareDriverManager(t, false)
+	dirs, err := dm.MatchedDriverDirs(context.Background(), archivePath, t.TempDir())
+	if err == nil {
+		t.Fatalf("MatchedDriverDirs = %v, want error when no driver matches", dirs)
+	}
+}
+
+func TestMatchedDriverDirsRejectsLinuxBackup(t *testing.T) {
+	manifest := &DriverBackupManifest{Version: driverBackupVersion, OS: "linux", Kernel: "6.8.0-45-generic"}
+	archivePath := writeTestDriverBackup(t, manifest, nil, DriverBackupZip)
+
+	dm := newHardwareDriverManager(t, true)
+	if _, err := dm.MatchedDriverDirs(context.Background(), archivePath, t.TempDir()); err == nil {
+		t.Fatal("MatchedDriverDirs accepted a Linux backup")
+	}
+}
+
+func TestDriverBackupVerifyDetectsTampering(t *testing.T) {
+	dm, _ := newLinuxBackupHost(t)
+	result, err := dm.Backup(context.Background(), DriverBackupOptions{TargetDir: t.TempDir()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	backup, err := ExtractDriverBackup(context.Background(), result.Path, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	module := filepath.Join(dir, filepath.FromSlash(backup.Manifest.Drivers[0].Files[0].Path))
+
+	// 大小相同但内容不同
+	original, err := os.ReadFile(module)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := bytes.ToUpper(original)
+	if err := os.WriteFile(module, tampered, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := backup.Verify(); err == nil {
+		t.Fatal("Verify accepted a modified file")
+	}
+
+	if err := os.Remove(module); err != nil {
+		t.Fatal(err)
+	}
+	if err := backup.Verify(); err == nil {
+		t.Fatal("Verify accepted a missing file")
+	}
+}
+
+func TestDriverBackupRestoreRoundTrip(t *testing.T) {
+	dm, contents := newLinuxBackupHost(t)
+	result, err := dm.Backup(context.Background(), DriverBackupOptions{TargetDir: t.TempDir(), Format: DriverBackupTarZst}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 恢复到重装后的系统：同样的网卡，但模块和固件都不在
+	target := newFakeSysRoot(t, []fakeSysfsDevice{
+		{bus: "pci", name: "0000:03:00.0", modalias: "pci:v000010ECd00008125sv00001849sd00008125bc02sc00i00", class: "0x020000"},
+	}, "", testKernel)
+	var commands []string
+	restorer := &DriverManager{
+		sysRoot: target,
+		logger:  utils.NewNopLogger(),
+		run: func(ctx context.Context, name string, args ...string) ([]byte, error) {
+			commands = append(commands, name)
+			return nil, nil
+		},
+	}
+	restored, err := restorer.Restore(context.Background(), result.Path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Installed != 2 || restored.Failed != 0 {
+		t.Fatalf("restore result = %+v, want module and firmware installed", restored)
+	}
+	for file, want := range map[string]string{
+		filepath.Join(target, "lib", "modules", testKernel, "updates", "r8125", "r8125.ko"): contents["module"],
+		filepath.Join(target, "lib", "firmware", "rtl_nic", "rtl8125b-2.fw.zst"):            contents["firmware"],
+	} {
+		data, err := os.ReadFile(file)
+		if err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v; want %q", file, data, err, want)
+		}
+	}
+	if len(commands) != 2 || commands[0] != "depmod" || commands[1] != "modprobe" {
+		t.Errorf("commands = %v, want depmod then modprobe", commands)
+	}
+}
+
+func TestExtractDriverBackupRejectsPathTraversal(t *testing.T) {
+	names := []string{"../escape.txt", "drivers/../../escape.txt"}
+	if runtime.GOOS != "windows" {
+		names = append(names, "/tmp/escape.txt")
+	}
+	for _, name := range names {
+		t.Run("zip "+name, func(t *testing.T) {
+			archivePath := filepath.Join(t.TempDir(), "evil.zip")
+			out, err := os.Create(archivePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			archive := zip.NewWriter(out)
+			w, err := archive.Create(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.Write([]byte("escaped"))
+			if err := archive.Close(); err != nil {
+				t.Fatal(err)
+			}
+			out.Close()
+			assertExtractRejected(t, archivePath)
+		})
+		t.Run("tar.zst "+name, func(t *testing.T) {
+			archivePath := filepath.Join(t.TempDir(), "evil.tar.zst")
+			out, err := os.Create(archivePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			encoder, err := zstd.NewWriter(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			archive := tar.NewWriter(encoder)
+			content := []byte("escaped")
+			if err := archive.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+				t.Fatal(err)
+			}
+			archive.Write(content)
+			archive.Close()
+			encoder.Close()
+			out.Close()
+			assertExtractRejected(t, archivePath)
+		})
+	}
+}
+
+// assertExtractRejected 解压应当失败，且不能在解压目录之外写入文件
+func assertExtractRejected(t *testing.T, archivePath string) {
+	t.Helper()
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "extract")
+	if _, err := ExtractDriverBackup(context.Background(), archivePath, dir); err == nil {
+		t.Fatal("ExtractDriverBackup accepted a path outside the extraction directory")
+	}
+	if _, err := os.Stat(filepath.Join(parent, "escape.txt")); !os.IsNotExist(err) {
+		t.Fatalf("file written outside the extraction directory: %v", err)
+	}
+}
+
+func TestExtractDriverBackupRejectsManifestPathTraversal(t *testing.T) {
+	// 清单中的路径和模块名同样不能越出解压目录或注入参数，校验和恢复都会按它们访问文件、调用 modprobe
+	module := "\x7fELF r8125"
+	file := fmt.Sprintf(`{"path":"modules/r8125/r8125.ko","size":%d,"sha256":"%s"}`, len(module), sha256Hex([]byte(module)))
+	tests := []struct {
+		name     string
+		manifest string
+		valid    bool
+	}{
+		{"有效的清单", `{"version":1,"os":"linux","drivers":[{"name":"r8125","module":"r8125-dkms_2","files":[` + file + `]}]}`, true},
+		{"文件路径", `{"version":1,"os":"linux","drivers":[{"name":"r8125","module":"r8125","files":[{"path":"../r8125.ko","size":6,"sha256":"x"}]}]}`, false},
+		{"模块名包含路径", `{"version":1,"os":"linux","drivers":[{"name":"r8125","module":"../../../etc/cron.d","files":[` + file + `]}]}`, false},
+		{"模块名以-开头", `{"version":1,"os":"linux","drivers":[{"name":"r8125","module":"--dry-run","files":[` + file + `]}]}`, false},
+		{"模块名包含空格", `{"version":1,"os":"linux","drivers":[{"name":"r8125","module":"r8125 evil=1","files":[` + file + `]}]}`, false},
+		{"固件的模块名", `{"version":1,"os":"linux","drivers":[],"firmware":[{"name":"r8125.ko","module":"-v",` + strings.TrimPrefix(file, "{") + `]}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			staging := t.TempDir()
+			for name, content := range map[string]string{driverManifestName: tt.manifest, "modules/r8125/r8125.ko": module} {
+				target := filepath.Join(staging, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(target, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			archivePath := filepath.Join(t.TempDir(), "evil.zip")
+			if err := writeStreamArchive(context.Background(), staging, archivePath, DriverBackupZip, driverBackupReporter{}); err != nil {
+				t.Fatal(err)
+			}
+			_, err := ExtractDriverBackup(context.Background(), archivePath, t.TempDir())
+			if tt.valid && err != nil {
+				t.Fatal(err)
+			}
+			if !tt.valid && err == nil {
+				t.Fatal("ExtractDriverBackup accepted an unsafe manifest")
+			}
+		})
+	}
+}
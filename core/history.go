@@ -0,0 +1,335 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// historyFileName 安装历史文件名（JSON Lines，只追加）
+const historyFileName = "install_history.jsonl"
+
+// InstallRecord 安装审计记录
+type InstallRecord struct {
+	ID              string             `json:"id"`
+	Timestamp       time.Time          `json:"timestamp"`
+	OSUser          string             `json:"os_user"`
+	HostFingerprint string             `json:"host_fingerprint"`
+	Options         InstallOptions     `json:"options"` // 已脱敏
+	ImageHash       string             `json:"image_hash,omitempty"`
+	ScriptVersion   string             `json:"script_version,omitempty"`
+	ScriptSource    string             `json:"script_source,omitempty"`
+	DurationMs      int64              `json:"duration_ms"`
+	Outcome         string             `json:"outcome"` // success, error, stopped
+	Error           string             `json:"error,omitempty"`
+	TranscriptPath  string             `json:"transcript_path,omitempty"`
+	Verification    *PostInstallResult `json:"verification,omitempty"`
+}
+
+// HistoryQuery 安装历史查询条件，零值表示不过滤
+type HistoryQuery struct {
+	Since   time.Time `json:"since"`
+	Until   time.Time `json:"until"`
+	OSType  string    `json:"os_type"`
+	Outcome string    `json:"outcome"`
+	Limit   int       `json:"limit"`
+}
+
+// HistoryStore 本地只追加的安装历史
+type HistoryStore struct {
+	path  string
+	mutex sync.Mutex
+}
+
+// NewHistoryStore 创建安装历史存储
+func NewHistoryStore(dir string) *HistoryStore {
+	return &HistoryStore{
+		path: filepath.Join(dir, historyFileName),
+	}
+}
+
+// Path 历史文件路径
+func (hs *HistoryStore) Path() string {
+	return hs.path
+}
+
+// Append 追加一条记录
+func (hs *HistoryStore) Append(record InstallRecord) error {
+	// 写入前再次脱敏，避免调用方遗漏
+	record.Options = record.Options.Redacted()
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	hs.mutex.Lock()
+	defer hs.mutex.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(hs.path), 0755); err != nil {
+		return fmt.Errorf("创建历史目录失败: %v", err)
+	}
+	file, err := os.OpenFile(hs.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("打开历史文件失败: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("写入历史记录失败: %v", err)
+	}
+	return file.Sync()
+}
+
+// List 按时间倒序返回符合条件的记录
+func (hs *HistoryStore) List(query HistoryQuery) ([]InstallRecord, error) {
+	hs.mutex.Lock()
+	defer hs.mutex.Unlock()
+
+	file, err := os.Open(hs.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []InstallRecord{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records := []InstallRecord{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var record InstallRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			// 跳过损坏的行（例如写入时断电）
+			continue
+		}
+		if query.matches(record) {
+			records = append(records, record)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Timestamp.After(records[j].Timestamp)
+	})
+	if query.Limit > 0 && len(records) > query.Limit {
+		records = records[:query.Limit]
+	}
+	return records, nil
+}
+
+// matches 判断记录是否符合查询条件
+func (q HistoryQuery) matches(record InstallRecord) bool {
+	if !q.Since.IsZero() && record.Timestamp.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && record.Timestamp.After(q.Until) {
+		return false
+	}
+	if q.OSType != "" && record.Options.OSType != q.OSType {
+		return false
+	}
+	if q.Outcome != "" && record.Outcome != q.Outcome {
+		return false
+	}
+	return true
+}
+
+// Export 导出符合条件的记录，format 为 json 或 csv
+func (hs *HistoryStore) Export(w io.Writer, format string, query HistoryQuery) error {
+	records, err := hs.List(query)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(format) {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(records)
+	case "csv":
+		return writeHistoryCSV(w, records)
+	default:
+		return fmt.Errorf("不支持的导出格式: %s", format)
+	}
+}
+
+// ExportFile 导出到文件
+func (hs *HistoryStore) ExportFile(path, format string, query HistoryQuery) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("创建导出文件失败: %v", err)
+	}
+	if err := hs.Export(file, format, query); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// writeHistoryCSV 以CSV格式写出记录
+func writeHistoryCSV(w io.Writer, records []InstallRecord) error {
+	writer := csv.NewWriter(w)
+	header := []string{
+		"id", "timestamp", "os_user", "host_fingerprint", "os_type", "system", "version",
+		"image", "image_hash", "script_version", "duration_ms", "outcome", "error",
+		"transcript_path", "verified", "verify_elapsed_ms",
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		image := record.Options.ImageURL
+		if image == "" {
+			image = record.Options.ISOURL
+		}
+		if image == "" {
+			image = record.Options.ImageName
+		}
+		verified, verifyElapsed := "", ""
+		if record.Verification != nil {
+			verified = strconv.FormatBool(record.Verification.Success)
+			verifyElapsed = strconv.FormatInt(record.Verification.ElapsedMs, 10)
+		}
+
+		row := []string{
+			record.ID,
+			record.Timestamp.Format(time.RFC3339),
+			record.OSUser,
+			record.HostFingerprint,
+			record.Options.OSType,
+			record.Options.System,
+			record.Options.Version,
+			image,
+			record.ImageHash,
+			record.ScriptVersion,
+			strconv.FormatInt(record.DurationMs, 10),
+			record.Outcome,
+			record.Error,
+			record.TranscriptPath,
+			verified,
+			verifyElapsed,
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// newRecordID 生成记录ID
+func newRecordID() string {
+	buf := make([]byte, 4)
+	rand.Read(buf)
+	return time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(buf)
+}
+
+// currentOSUser 当前操作系统用户
+func currentOSUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USERNAME"); name != "" {
+		return name
+	}
+	return os.Getenv("USER")
+}
+
+// HostFingerprint 根据主机名和网卡MAC地址生成主机指纹
+func HostFingerprint() string {
+	hostname, _ := os.Hostname()
+	parts := []string{hostname}
+
+	if interfaces, err := net.Interfaces(); err == nil {
+		var macs []string
+		for _, iface := range interfaces {
+			if iface.Flags&net.FlagLoopback != 0 || len(iface.HardwareAddr) == 0 {
+				continue
+			}
+			macs = append(macs, iface.HardwareAddr.String())
+		}
+		sort.Strings(macs)
+		parts = append(parts, macs...)
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(parts, "|")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// imageHash 安装镜像的SHA-256
+// 远程镜像使用目录中登记的值，本地镜像流式计算，ctx取消时中断
+func imageHash(ctx context.Context, options InstallOptions) (string, error) {
+	for _, location := range []string{options.ImageURL, options.ISOURL} {
+		if location == "" {
+			continue
+		}
+		if !strings.HasPrefix(location, "file://") {
+			return strings.ToLower(options.ImageSHA256), nil
+		}
+		file, err := os.Open(strings.TrimPrefix(location, "file://"))
+		if err != nil {
+			return "", err
+		}
+		defer file.Close()
+		hash := sha256.New()
+		if _, err := io.Copy(hash, &contextReader{ctx: ctx, r: file}); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(hash.Sum(nil)), nil
+	}
+	return "", nil
+}
+
+// contextReader ctx取消后读取返回错误
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// Read 实现io.Reader
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
+
+// syncWriter 供多个goroutine同时写入的Writer
+type syncWriter struct {
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+// Write 实现io.Writer
+func (sw *syncWriter) Write(p []byte) (int, error) {
+	sw.mutex.Lock()
+	defer sw.mutex.Unlock()
+	return sw.w.Write(p)
+}
@@ -0,0 +1,236 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultProbeTTL 探测结果的缓存时间
+const defaultProbeTTL = 30 * time.Minute
+
+// ImageProbe 镜像地址的探测结果
+type ImageProbe struct {
+	URL          string    `json:"url"`
+	Size         int64     `json:"size"` // 字节数，未知时为-1
+	AcceptRanges bool      `json:"accept_ranges"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Resumable    bool      `json:"resumable"` // 支持断点续传：支持Range且有校验标识
+	Segmented    bool      `json:"segmented"` // 支持分段并发下载：支持Range且大小已知
+	Method       string    `json:"method"`    // HEAD, GET, FILE
+	ProbedAt     time.Time `json:"probed_at"`
+}
+
+// ImageProber 通过HEAD（不支持时用Range GET）探测镜像大小和下载能力，结果缓存在内存中
+type ImageProber struct {
+	httpClient *http.Client
+	ttl        time.Duration
+	mutex      sync.Mutex
+	cache      map[string]*ImageProbe
+}
+
+// NewImageProber 创建镜像探测器，httpClient为nil时使用共享传输层的客户端
+func NewImageProber(httpClient *http.Client) *ImageProber {
+	if httpClient == nil {
+		httpClient = DefaultHTTPTransport().Client(30 * time.Second)
+	}
+	return &ImageProber{
+		httpClient: httpClient,
+		ttl:        defaultProbeTTL,
+		cache:      make(map[string]*ImageProbe),
+	}
+}
+
+// Probe 探测镜像地址，缓存有效期内直接返回缓存
+func (ip *ImageProber) Probe(ctx context.Context, location string) (*ImageProbe, error) {
+	ip.mutex.Lock()
+	if cached, ok := ip.cache[location]; ok && time.Since(cached.ProbedAt) < ip.ttl {
+		ip.mutex.Unlock()
+		result := *cached
+		return &result, nil
+	}
+	ip.mutex.Unlock()
+
+	var result *ImageProbe
+	var err error
+	if strings.HasPrefix(location, "file://") {
+		result, err = probeLocalImage(location)
+	} else {
+		result, err = ip.probeHTTP(ctx, location)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result.URL = location
+	result.ProbedAt = time.Now()
+	result.Segmented = result.AcceptRanges && result.Size > 0
+	result.Resumable = result.Segmented && (result.ETag != "" || result.LastModified != "")
+
+	ip.mutex.Lock()
+	cached := *result
+	ip.cache[location] = &cached
+	ip.mutex.Unlock()
+	return result, nil
+}
+
+// ProbeAll 并发探测多个地址，失败的地址不出现在结果中
+func (ip *ImageProber) ProbeAll(ctx context.Context, locations []string, concurrency int) map[string]*ImageProbe {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	results := make(map[string]*ImageProbe, len(locations))
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, concurrency)
+
+	for _, location := range locations {
+		wg.Add(1)
+		go func(location string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			if result, err := ip.Probe(ctx, location); err == nil {
+				mutex.Lock()
+				results[location] = result
+				mutex.Unlock()
+			}
+		}(location)
+	}
+	wg.Wait()
+	return results
+}
+
+// probeHTTP 先发HEAD请求，服务器不支持HEAD或未返回长度时改用 Range: bytes=0-0 的GET请求
+func (ip *ImageProber) probeHTTP(ctx context.Context, location string) (*ImageProbe, error) {
+	result, err := ip.probeHead(ctx, location)
+	if err == nil && result.Size >= 0 {
+		return result, nil
+	}
+
+	ranged, rangeErr := ip.probeRange(ctx, location)
+	if rangeErr != nil {
+		if err != nil {
+			return nil, err
+		}
+		// HEAD成功但没有长度，仍返回HEAD的结果
+		return result, nil
+	}
+	return ranged, nil
+}
+
+// probeHead 发送HEAD请求
+func (ip *ImageProber) probeHead(ctx context.Context, location string) (*ImageProbe, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "SystemReinstaller/"+AppVersion)
+
+	resp, err := ip.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP错误: %d", resp.StatusCode)
+	}
+
+	return &ImageProbe{
+		Size:         resp.ContentLength,
+		AcceptRanges: strings.EqualFold(resp.Header.Get("Accept-Ranges"), "bytes"),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Method:       http.MethodHead,
+	}, nil
+}
+
+// probeRange 请求第一个字节，从Content-Range中取得总大小
+func (ip *ImageProber) probeRange(ctx context.Context, location string) (*ImageProbe, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "SystemReinstaller/"+AppVersion)
+	req.Header.Set("Range", "bytes=0-0")
+
+	resp, err := ip.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	// 不读取正文，服务器忽略Range时避免下载整个镜像
+	defer resp.Body.Close()
+
+	result := &ImageProbe{
+		Size:         -1,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Method:       http.MethodGet,
+	}
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		result.AcceptRanges = true
+		result.Size = parseContentRangeTotal(resp.Header.Get("Content-Range"))
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 1))
+	case http.StatusOK:
+		result.Size = resp.ContentLength
+	default:
+		return nil, fmt.Errorf("HTTP错误: %d", resp.StatusCode)
+	}
+	return result, nil
+}
+
+// parseContentRangeTotal 解析 "bytes 0-0/12345" 中的总大小，未知时返回-1
+func parseContentRangeTotal(value string) int64 {
+	slash := strings.LastIndex(value, "/")
+	if slash < 0 {
+		return -1
+	}
+	total, err := strconv.ParseInt(strings.TrimSpace(value[slash+1:]), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return total
+}
+
+// fileURLPath 将file://地址转换为本地路径
+func fileURLPath(location string) (string, error) {
+	parsed, err := url.Parse(location)
+	if err != nil {
+		return "", err
+	}
+	path := parsed.Path
+	if runtime.GOOS == "windows" {
+		path = strings.TrimPrefix(path, "/")
+	}
+	return filepath.FromSlash(path), nil
+}
+
+// probeLocalImage 本地目录中的镜像直接读取文件信息
+func probeLocalImage(location string) (*ImageProbe, error) {
+	path, err := fileURLPath(location)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return &ImageProbe{
+		Size:         info.Size(),
+		AcceptRanges: true,
+		LastModified: info.ModTime().UTC().Format(http.TimeFormat),
+		Method:       "FILE",
+	}, nil
+}
@@ -0,0 +1,172 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// probeTestImage 测试服务器提供的镜像内容
+var probeTestImage = strings.Repeat("x", 4096)
+
+// probeServerBehavior 测试服务器对HEAD和Range的处理方式
+type probeServerBehavior struct {
+	rejectHead   bool // HEAD返回405
+	headNoLength bool // HEAD成功但不返回长度
+	ignoreRange  bool // GET忽略Range，返回整个文件
+	etag         string
+	lastModified string
+}
+
+// newProbeServer 按 behavior 响应的镜像服务器，methods 记录收到的请求方法
+func newProbeServer(t *testing.T, behavior probeServerBehavior) (*httptest.Server, *[]string) {
+	t.Helper()
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		if behavior.etag != "" {
+			w.Header().Set("ETag", behavior.etag)
+		}
+		if behavior.lastModified != "" {
+			w.Header().Set("Last-Modified", behavior.lastModified)
+		}
+
+		switch {
+		case r.Method == http.MethodHead && behavior.rejectHead:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case r.Method == http.MethodHead && behavior.headNoLength:
+			// 分块传输时HEAD响应没有Content-Length
+			w.Header().Set("Transfer-Encoding", "chunked")
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodHead:
+			if !behavior.ignoreRange {
+				w.Header().Set("Accept-Ranges", "bytes")
+			}
+			w.Header().Set("Content-Length", strconv.Itoa(len(probeTestImage)))
+			w.WriteHeader(http.StatusOK)
+		case r.Header.Get("Range") == "bytes=0-0" && !behavior.ignoreRange:
+			w.Header().Set("Content-Range", "bytes 0-0/"+strconv.Itoa(len(probeTestImage)))
+			w.Header().Set("Content-Length", "1")
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write([]byte(probeTestImage[:1]))
+		default:
+			w.Header().Set("Content-Length", strconv.Itoa(len(probeTestImage)))
+			w.Write([]byte(probeTestImage))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &methods
+}
+
+func TestImageProberHTTP(t *testing.T) {
+	size := int64(len(probeTestImage))
+	tests := []struct {
+		name      string
+		behavior  probeServerBehavior
+		methods   string
+		want      ImageProbe
+		resumable bool
+		segmented bool
+	}{
+		{"HEAD", probeServerBehavior{etag: `"abc"`}, "HEAD",
+			ImageProbe{Size: size, AcceptRanges: true, ETag: `"abc"`, Method: "HEAD"}, true, true},
+		{"拒绝HEAD时使用Range GET", probeServerBehavior{rejectHead: true, lastModified: "Tue, 09 Jan 2024 00:00:00 GMT"}, "HEAD,GET",
+			ImageProbe{Size: size, AcceptRanges: true, LastModified: "Tue, 09 Jan 2024 00:00:00 GMT", Method: "GET"}, true, true},
+		{"HEAD没有长度时使用Range GET", probeServerBehavior{headNoLength: true, etag: `"abc"`}, "HEAD,GET",
+			ImageProbe{Size: size, AcceptRanges: true, ETag: `"abc"`, Method: "GET"}, true, true},
+		{"没有校验标识不能续传", probeServerBehavior{}, "HEAD",
+			ImageProbe{Size: size, AcceptRanges: true, Method: "HEAD"}, false, true},
+		{"忽略Range", probeServerBehavior{rejectHead: true, ignoreRange: true, etag: `"abc"`}, "HEAD,GET",
+			ImageProbe{Size: size, ETag: `"abc"`, Method: "GET"}, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, methods := newProbeServer(t, tt.behavior)
+			prober := NewImageProber(server.Client())
+
+			got, err := prober.Probe(context.Background(), server.URL+"/win11.vhd")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Size != tt.want.Size || got.AcceptRanges != tt.want.AcceptRanges || got.ETag != tt.want.ETag ||
+				got.LastModified != tt.want.LastModified || got.Method != tt.want.Method {
+				t.Errorf("probe = %+v, want %+v", got, tt.want)
+			}
+			if got.Resumable != tt.resumable || got.Segmented != tt.segmented {
+				t.Errorf("resumable = %v, segmented = %v; want %v, %v", got.Resumable, got.Segmented, tt.resumable, tt.segmented)
+			}
+			if strings.Join(*methods, ",") != tt.methods {
+				t.Errorf("requests = %v, want %s", *methods, tt.methods)
+			}
+		})
+	}
+}
+
+func TestImageProberErrorsAndCache(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path == "/missing.vhd" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Length", "10")
+		w.Header().Set("Accept-Ranges", "bytes")
+	}))
+	defer server.Close()
+	prober := NewImageProber(server.Client())
+
+	if _, err := prober.Probe(context.Background(), server.URL+"/missing.vhd"); err == nil {
+		t.Fatal("HEAD 和 GET 都失败时应返回错误")
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := prober.Probe(context.Background(), server.URL+"/win11.vhd"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// missing.vhd 的 HEAD 和 GET，加上 win11.vhd 的一次 HEAD
+	if got := requests.Load(); got != 3 {
+		t.Fatalf("requests = %d, want 3 (第二次探测应使用缓存)", got)
+	}
+}
+
+func TestImageProberLocalFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "disk.raw")
+	if err := os.WriteFile(path, []byte("disk"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := NewImageProber(nil).Probe(context.Background(), "file://"+filepath.ToSlash(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Size != 4 || got.Method != "FILE" || !got.Resumable || !got.Segmented {
+		t.Fatalf("probe = %+v", got)
+	}
+}
+
+func TestParseContentRangeTotal(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int64
+	}{
+		{"bytes 0-0/12345", 12345},
+		{"bytes 0-0/ 42", 42},
+		{"bytes 0-0/*", -1},
+		{"bytes */12345", 12345},
+		{"", -1},
+		{"bytes 0-0", -1},
+		{"bytes 0-0/abc", -1},
+	}
+	for _, tt := range tests {
+		if got := parseContentRangeTotal(tt.value); got != tt.want {
+			t.Errorf("parseContentRangeTotal(%q) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
@@ -1,29 +1,54 @@
 package core
 
 import (
-	"encoding/json"
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
+	"time"
+
+	"SystemReinstaller/utils"
 )
 
 // SystemInstaller 系统安装器
 type SystemInstaller struct {
-	config          map[string]interface{}
+	config          *ResolvedConfig
+	configFile      *configFile
+	configPath      string
+	configOverrides []ConfigOverride
+	overrideErrs    []error // 被忽略的无效配置覆盖
+	configMutex     sync.RWMutex
 	licenseVerified bool
 	userCredits     int
 	userType        string
 	progress        InstallProgress
 	progressMutex   sync.RWMutex
 	installRunning  bool
-	stopChannel     chan bool
+	installCtx      context.Context    // 本次安装的上下文，停止安装时取消
+	cancelInstall   context.CancelFunc // 取消本次安装的上下文
 	reinstallPath   string
 	workingDir      string
+	scriptInfo      *ReinstallScriptInfo
+	scriptMutex     sync.RWMutex
+	apiClient       *APIClient
+	lastVerify      *PostInstallResult
+	history         *HistoryStore
+	profiles        *ProfileStore
+	secrets         *FileSecretStore
+	drivers         *DriverManager
+	transcriptDir   string
+	transcript      io.Writer
+	logger          *utils.Logger
 }
 
 // InstallProgress 安装进度
@@ -35,23 +60,27 @@ type InstallProgress struct {
 
 // InstallOptions 安装选项
 type InstallOptions struct {
-	OSType       string            `json:"os_type"`       // linux, windows, dd
-	System       string            `json:"system"`        // 系统名称
-	Version      string            `json:"version"`       // 版本
-	Username     string            `json:"username"`      // 用户名
-	Password     string            `json:"password"`      // 密码
-	SSHKey       string            `json:"ssh_key"`       // SSH密钥
-	SSHPort      int               `json:"ssh_port"`      // SSH端口
-	WebPort      int               `json:"web_port"`      // Web端口
-	ImageURL     string            `json:"image_url"`     // 镜像URL (DD安装)
-	ISOURL       string            `json:"iso_url"`       // ISO URL (Windows)
-	ImageName    string            `json:"image_name"`    // Windows镜像名称
-	Language     string            `json:"language"`      // 语言
-	Minimal      bool              `json:"minimal"`       // 最小安装
-	AllowPing    bool              `json:"allow_ping"`    // 允许ping
-	RDPPort      int               `json:"rdp_port"`      // RDP端口
-	Drivers      []string          `json:"drivers"`       // 驱动列表
-	ExtraOptions map[string]string `json:"extra_options"` // 额外选项
+	OSType        string            `json:"os_type"`        // linux, windows, dd
+	System        string            `json:"system"`         // 系统名称
+	Version       string            `json:"version"`        // 版本
+	Username      string            `json:"username"`       // 用户名
+	Password      string            `json:"password"`       // 密码
+	SSHKey        string            `json:"ssh_key"`        // SSH密钥
+	SSHPort       int               `json:"ssh_port"`       // SSH端口
+	WebPort       int               `json:"web_port"`       // Web端口
+	ImageURL      string            `json:"image_url"`      // 镜像URL (DD安装)
+	ISOURL        string            `json:"iso_url"`        // ISO URL (Windows)
+	ImageName     string            `json:"image_name"`     // Windows镜像名称
+	Language      string            `json:"language"`       // 语言
+	Minimal       bool              `json:"minimal"`        // 最小安装
+	AllowPing     bool              `json:"allow_ping"`     // 允许ping
+	RDPPort       int               `json:"rdp_port"`       // RDP端口
+	Drivers       []string          `json:"drivers"`        // 驱动列表
+	DriverBackup  string            `json:"driver_backup"`  // 驱动备份归档，Windows安装时注入其中与本机硬件匹配的驱动
+	ImageSHA256   string            `json:"image_sha256"`   // 目录中登记的远程镜像SHA-256，记录到安装历史
+	ExtraOptions  map[string]string `json:"extra_options"`  // 额外选项
+	VerifyHost    string            `json:"verify_host"`    // 安装后验证SSH/RDP可达性的主机地址
+	VerifyTimeout int               `json:"verify_timeout"` // 安装后验证超时(秒)
 }
 
 // DDImageInfo DD镜像信息
@@ -84,9 +113,10 @@ func NewSystemInstaller() *SystemInstaller {
 		workingDir = "." // 如果获取失败，使用当前目录
 	}
 
-	return &SystemInstaller{
-		config:      make(map[string]interface{}),
-		stopChannel: make(chan bool, 1),
+	si := &SystemInstaller{
+		config:     defaultResolvedConfig(),
+		configFile: &configFile{config: DefaultConfig(), keys: map[string]bool{}},
+		configPath: DefaultConfigPath(),
 		progress: InstallProgress{
 			Percentage: 0,
 			Message:    "就绪",
@@ -94,7 +124,27 @@ func NewSystemInstaller() *SystemInstaller {
 		},
 		workingDir:    workingDir,
 		reinstallPath: filepath.Join(workingDir, "reinstall"),
+		apiClient:     NewAPIClient(),
+		history:       NewHistoryStore(filepath.Join(workingDir, "history")),
+		profiles:      NewProfileStore(DefaultProfilesPath()),
+		secrets:       NewFileSecretStore(DefaultSecretsPath()),
+		drivers:       NewDriverManager(),
+		transcriptDir: filepath.Join(workingDir, "logs", "transcripts"),
+		logger:        utils.NewNopLogger(),
 	}
+	si.profiles.SetSecretStore(si.secrets)
+	return si
+}
+
+// SetLogger 设置日志记录器
+func (si *SystemInstaller) SetLogger(logger *utils.Logger) {
+	si.logger = logger.With("component", "installer")
+	si.drivers.SetLogger(logger)
+}
+
+// SetAPIClient 设置用于检查脚本更新的API客户端
+func (si *SystemInstaller) SetAPIClient(client *APIClient) {
+	si.apiClient = client
 }
 
 // Initialize 初始化安装器
@@ -103,216 +153,387 @@ func (si *SystemInstaller) Initialize() error {
 	return si.setupReinstallScript()
 }
 
-// setupReinstallScript 设置reinstall脚本
+// setupReinstallScript 解压reinstall脚本，优先使用已启用的在线更新版本
 func (si *SystemInstaller) setupReinstallScript() error {
-	// 检查reinstall脚本是否存在
-	scriptPath := filepath.Join(si.reinstallPath, "reinstall.sh")
-	if runtime.GOOS == "windows" {
-		scriptPath = filepath.Join(si.reinstallPath, "reinstall.bat")
+	bundle, err := si.loadStagedScriptBundle()
+	if err != nil {
+		si.logger.Warning("已启用的脚本更新无法使用，回退到内置脚本", "error", err)
 	}
-
-	if _, err := os.Stat(scriptPath); os.IsNotExist(err) {
-		// 如果不存在，尝试从offline目录复制
-		offlineScript := filepath.Join(si.workingDir, "AutoInstaller", "reinstall-offline", "reinstall", "reinstall.sh")
-		if runtime.GOOS == "windows" {
-			offlineScript = filepath.Join(si.workingDir, "AutoInstaller", "reinstall-offline", "reinstall", "reinstall.bat")
-		}
-
-		if _, err := os.Stat(offlineScript); err == nil {
-			// 创建目录
-			os.MkdirAll(si.reinstallPath, 0755)
-			// 复制脚本
-			return si.copyFile(offlineScript, scriptPath)
+	if err != nil || bundle == nil {
+		// 没有启用更新或更新校验失败时回退到内置脚本
+		bundle, err = loadEmbeddedScriptBundle()
+		if err != nil {
+			return err
 		}
+	}
 
-		// 如果找不到offline脚本，创建一个基本的脚本文件以避免错误
-		os.MkdirAll(si.reinstallPath, 0755)
-		if runtime.GOOS == "windows" {
-			// 创建基本的Windows bat脚本
-			content := "@echo off\necho SystemReinstaller Script\necho Args: %*\npause\n"
-			return os.WriteFile(scriptPath, []byte(content), 0755)
-		} else {
-			// 创建基本的Linux shell脚本
-			content := "#!/bin/bash\necho \"SystemReinstaller Script\"\necho \"Args: $@\"\nread -p \"Press enter to continue...\"\n"
-			return os.WriteFile(scriptPath, []byte(content), 0755)
-		}
+	info, err := extractScriptBundle(bundle, si.reinstallPath)
+	if err != nil {
+		return err
 	}
 
+	si.scriptMutex.Lock()
+	si.scriptInfo = info
+	si.scriptMutex.Unlock()
+	si.logger.Info("reinstall脚本就绪", "version", info.Version, "source", info.Source)
 	return nil
 }
 
-// copyFile 复制文件
-func (si *SystemInstaller) copyFile(src, dst string) error {
-	sourceFile, err := os.Open(src)
+// ensureReinstallScript 执行前校验脚本，缺失或被修改时重新解压
+func (si *SystemInstaller) ensureReinstallScript() (*ReinstallScriptInfo, error) {
+	si.scriptMutex.RLock()
+	info := si.scriptInfo
+	si.scriptMutex.RUnlock()
+
+	if info != nil && verifyScriptFiles(si.reinstallPath, info.SHA256) == nil {
+		return info, nil
+	}
+
+	if err := si.setupReinstallScript(); err != nil {
+		return nil, err
+	}
+
+	si.scriptMutex.RLock()
+	defer si.scriptMutex.RUnlock()
+	return si.scriptInfo, nil
+}
+
+// GetReinstallScriptInfo 获取当前使用的reinstall脚本信息
+func (si *SystemInstaller) GetReinstallScriptInfo() *ReinstallScriptInfo {
+	si.scriptMutex.RLock()
+	defer si.scriptMutex.RUnlock()
+	return si.scriptInfo
+}
+
+// InstallSystem 安装系统主方法，每次调用都会追加一条审计记录
+func (si *SystemInstaller) InstallSystem(options InstallOptions) error {
+	// 先应用配置默认值，审计记录保存的是实际执行的选项
+	si.Config().ApplyInstallDefaults(&options)
+
+	startedAt := time.Now()
+	record := InstallRecord{
+		ID:              newRecordID(),
+		Timestamp:       startedAt,
+		OSUser:          currentOSUser(),
+		HostFingerprint: HostFingerprint(),
+		Options:         options.Redacted(),
+	}
+
+	logger := si.logger.With("install_id", record.ID)
+	logger.Info("开始安装", "os_type", options.OSType, "system", options.System, "version", options.Version)
+
+	// 记录脚本输出（已脱敏）
+	transcript, err := si.openTranscript(record.ID)
+	if err == nil {
+		defer transcript.Close()
+		record.TranscriptPath = transcript.Name()
+	} else {
+		logger.Warning("无法创建安装输出记录", "error", err)
+	}
+
+	ctx, endInstall := si.beginInstall(transcript)
+	defer endInstall()
+
+	// 本地镜像可能很大，在安装开始后计算哈希，停止安装时一并中断
+	hash, err := imageHash(ctx, options)
 	if err != nil {
-		return err
+		logger.Warning("无法计算镜像哈希", "error", err)
+	}
+	record.ImageHash = hash
+	if ctx.Err() != nil {
+		err = fmt.Errorf("安装已停止")
+	} else {
+		err = si.installSystem(options)
 	}
-	defer sourceFile.Close()
 
-	destFile, err := os.Create(dst)
+	if info := si.GetReinstallScriptInfo(); info != nil {
+		record.ScriptVersion = info.Version
+		record.ScriptSource = info.Source
+	}
+	record.DurationMs = time.Since(startedAt).Milliseconds()
+	record.Verification = si.GetPostInstallResult()
+	record.Outcome = "success"
 	if err != nil {
-		return err
+		record.Outcome = "error"
+		record.Error = NewOptionsRedactor(options).Redact(err.Error())
+	}
+	if ctx.Err() != nil || si.GetProgress().Status == "stopped" {
+		record.Outcome = "stopped"
+	}
+	logger.Info("安装结束", "outcome", record.Outcome, "duration_ms", record.DurationMs, "error", record.Error)
+	if historyErr := si.history.Append(record); historyErr != nil {
+		logger.Error("记录安装历史失败", "error", historyErr)
+		if err == nil {
+			return fmt.Errorf("安装完成但记录安装历史失败: %v", historyErr)
+		}
 	}
-	defer destFile.Close()
 
-	_, err = io.Copy(destFile, sourceFile)
 	return err
 }
 
-// InstallSystem 安装系统主方法
-func (si *SystemInstaller) InstallSystem(options InstallOptions) error {
+// beginInstall 标记安装开始并创建本次安装的上下文，返回的函数在安装结束时调用
+// 每次安装使用独立的上下文，之前的停止请求不会影响本次安装
+func (si *SystemInstaller) beginInstall(transcript *os.File) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
 	si.progressMutex.Lock()
 	si.installRunning = true
+	si.installCtx = ctx
+	si.cancelInstall = cancel
 	si.progress = InstallProgress{
 		Percentage: 0,
 		Message:    "开始安装...",
 		Status:     "running",
 	}
+	si.lastVerify = nil
+	if transcript != nil {
+		si.transcript = &syncWriter{w: transcript}
+	}
 	si.progressMutex.Unlock()
 
-	defer func() {
+	return ctx, func() {
+		cancel()
 		si.progressMutex.Lock()
 		si.installRunning = false
+		si.installCtx = nil
+		si.cancelInstall = nil
+		si.transcript = nil
 		si.progressMutex.Unlock()
-	}()
-
-	switch options.OSType {
-	case "linux":
-		return si.installLinuxSystem(options)
-	case "windows":
-		return si.installWindowsSystem(options)
-	case "dd":
-		return si.installDDImage(options)
-	default:
-		return fmt.Errorf("不支持的安装类型: %s", options.OSType)
 	}
 }
 
-// installLinuxSystem 安装Linux系统
-func (si *SystemInstaller) installLinuxSystem(options InstallOptions) error {
-	si.updateProgress(10, "准备Linux安装...")
+// Profiles 安装方案存储
+func (si *SystemInstaller) Profiles() *ProfileStore {
+	return si.profiles
+}
 
-	// 构建reinstall命令
-	args := []string{}
+// Secrets 保存密码、SSH密钥和API密钥的加密密钥库
+func (si *SystemInstaller) Secrets() *FileSecretStore {
+	return si.secrets
+}
 
-	// 添加系统和版本
-	if options.Version != "" {
-		args = append(args, options.System, options.Version)
-	} else {
-		args = append(args, options.System)
+// ProfileOptions 以安装方案为基础应用本次安装的覆盖项，返回的选项不含密码和SSH密钥
+func (si *SystemInstaller) ProfileOptions(name string, overrides map[string]interface{}) (InstallOptions, error) {
+	profile, err := si.profiles.Get(name)
+	if err != nil {
+		return InstallOptions{}, err
 	}
+	return profile.Apply(overrides)
+}
 
-	// 添加选项
-	if options.Password != "" {
-		args = append(args, "--password", options.Password)
+// InstallWithProfile 使用安装方案和本次的覆盖项安装系统
+// 覆盖项中没有密码或SSH密钥时使用方案保存在密钥库中的值
+func (si *SystemInstaller) InstallWithProfile(name string, overrides map[string]interface{}) error {
+	profile, err := si.profiles.Get(name)
+	if err != nil {
+		return err
 	}
-	if options.SSHKey != "" {
-		args = append(args, "--ssh-key", options.SSHKey)
+	options, err := profile.Apply(overrides)
+	if err != nil {
+		return err
 	}
-	if options.SSHPort > 0 {
-		args = append(args, "--ssh-port", fmt.Sprintf("%d", options.SSHPort))
+	if err := si.profiles.LoadSecrets(*profile, &options); err != nil {
+		return err
 	}
-	if options.WebPort > 0 {
-		args = append(args, "--web-port", fmt.Sprintf("%d", options.WebPort))
+	si.Config().ApplyInstallDefaults(&options)
+	if err := si.ValidateInstallOptions(options); err != nil {
+		return err
 	}
-	if options.Minimal {
-		args = append(args, "--minimal")
+	si.logger.Info("使用安装方案", "profile", name)
+	return si.InstallSystem(options)
+}
+
+// installSystem 按安装类型执行安装，需要时等待新系统上线
+func (si *SystemInstaller) installSystem(options InstallOptions) error {
+	var err error
+	switch options.OSType {
+	case "linux":
+		err = si.installLinuxSystem(options)
+	case "windows":
+		err = si.installWindowsSystem(options)
+	case "dd":
+		err = si.installDDImage(options)
+	default:
+		return fmt.Errorf("不支持的安装类型: %s", options.OSType)
 	}
 
-	return si.executeReinstallScript(args)
+	if err != nil || options.VerifyHost == "" {
+		return err
+	}
+	return si.waitForNewSystem(options)
 }
 
-// installWindowsSystem 安装Windows系统
-func (si *SystemInstaller) installWindowsSystem(options InstallOptions) error {
-	si.updateProgress(10, "准备Windows安装...")
+// openTranscript 创建本次安装的输出记录文件
+func (si *SystemInstaller) openTranscript(id string) (*os.File, error) {
+	if err := os.MkdirAll(si.transcriptDir, 0755); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(filepath.Join(si.transcriptDir, "install_"+id+".log"), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+}
+
+// GetInstallHistory 查询安装历史
+func (si *SystemInstaller) GetInstallHistory(query HistoryQuery) ([]InstallRecord, error) {
+	return si.history.List(query)
+}
 
-	// 构建reinstall命令
-	args := []string{"windows"}
+// ExportInstallHistory 将安装历史导出为JSON或CSV文件
+func (si *SystemInstaller) ExportInstallHistory(path, format string, query HistoryQuery) error {
+	return si.history.ExportFile(path, format, query)
+}
 
-	// 添加镜像名称
-	if options.ImageName != "" {
-		args = append(args, "--image-name", options.ImageName)
-	}
+// waitForNewSystem 脚本完成后等待新系统上线，只有SSH/RDP握手成功才算安装完成
+func (si *SystemInstaller) waitForNewSystem(options InstallOptions) error {
+	si.progressMutex.Lock()
+	si.progress.Status = "verifying"
+	si.progress.Message = "等待新系统上线..."
+	si.progressMutex.Unlock()
+
+	result := si.VerifyInstallation(options)
 
-	// 添加ISO URL
-	if options.ISOURL != "" {
-		args = append(args, "--iso", options.ISOURL)
+	si.progressMutex.Lock()
+	defer si.progressMutex.Unlock()
+	if !result.Success {
+		si.progress.Status = "error"
+		si.progress.Message = fmt.Sprintf("新系统未能上线: %s", result.Detail)
+		return fmt.Errorf("新系统未能上线: %s", result.Detail)
 	}
+	si.progress.Status = "success"
+	si.progress.Message = fmt.Sprintf("安装完成，新系统已上线 (耗时%ds)", result.ElapsedMs/1000)
+	return nil
+}
 
-	// 添加语言
-	if options.Language != "" {
-		args = append(args, "--lang", options.Language)
+// VerifyInstallation 轮询重装后系统的SSH/RDP端口，结果会被记录
+func (si *SystemInstaller) VerifyInstallation(options InstallOptions) *PostInstallResult {
+	watcher := NewReachabilityWatcher()
+	if options.VerifyTimeout > 0 {
+		watcher.Timeout = time.Duration(options.VerifyTimeout) * time.Second
 	}
 
-	// 添加通用选项
-	if options.Password != "" {
-		args = append(args, "--password", options.Password)
+	// 安装过程中的验证使用本次安装的上下文，停止安装时同时停止等待
+	result := watcher.Wait(si.runContext(), options.VerifyHost, options)
+
+	si.progressMutex.Lock()
+	si.lastVerify = result
+	si.progressMutex.Unlock()
+	return result
+}
+
+// runContext 正在运行的安装的上下文，没有安装运行时返回 context.Background()
+func (si *SystemInstaller) runContext() context.Context {
+	si.progressMutex.RLock()
+	defer si.progressMutex.RUnlock()
+	if si.installCtx == nil {
+		return context.Background()
 	}
-	if options.SSHPort > 0 {
-		args = append(args, "--ssh-port", fmt.Sprintf("%d", options.SSHPort))
+	return si.installCtx
+}
+
+// GetPostInstallResult 获取最近一次安装后验证结果
+func (si *SystemInstaller) GetPostInstallResult() *PostInstallResult {
+	si.progressMutex.RLock()
+	defer si.progressMutex.RUnlock()
+	return si.lastVerify
+}
+
+// installLinuxSystem 安装Linux系统
+func (si *SystemInstaller) installLinuxSystem(options InstallOptions) error {
+	si.updateProgress(10, "准备Linux安装...")
+	return si.runReinstall(options)
+}
+
+// installWindowsSystem 安装Windows系统
+func (si *SystemInstaller) installWindowsSystem(options InstallOptions) error {
+	si.updateProgress(10, "准备Windows安装...")
+	if options.DriverBackup != "" {
+		if err := si.injectBackupDrivers(&options); err != nil {
+			return err
+		}
 	}
-	if options.RDPPort > 0 {
-		args = append(args, "--rdp-port", fmt.Sprintf("%d", options.RDPPort))
+	return si.runReinstall(options)
+}
+
+// injectBackupDrivers 从驱动备份中取出与本机硬件匹配的驱动，追加到 --add-driver
+// 驱动解压在工作目录的 drivers/inject 下，每次安装前清空
+func (si *SystemInstaller) injectBackupDrivers(options *InstallOptions) error {
+	si.updateProgress(15, "准备注入驱动...")
+	dir := filepath.Join(si.workingDir, "drivers", "inject")
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("清理驱动目录失败: %v", err)
 	}
-	if options.AllowPing {
-		args = append(args, "--allow-ping")
+	ctx := si.runContext()
+	dirs, err := si.drivers.MatchedDriverDirs(ctx, options.DriverBackup, dir)
+	if ctx.Err() != nil {
+		return fmt.Errorf("安装已停止")
 	}
-
-	// 添加驱动
-	for _, driver := range options.Drivers {
-		args = append(args, "--add-driver", driver)
+	if err != nil {
+		return fmt.Errorf("准备注入的驱动失败: %v", err)
 	}
-
-	return si.executeReinstallScript(args)
+	si.logger.Info("注入备份中的驱动", "backup", options.DriverBackup, "drivers", len(dirs))
+	options.Drivers = append(append([]string(nil), options.Drivers...), dirs...)
+	return nil
 }
 
 // installDDImage 安装DD镜像
 func (si *SystemInstaller) installDDImage(options InstallOptions) error {
 	si.updateProgress(10, "准备DD安装...")
+	return si.runReinstall(options)
+}
 
-	// 构建reinstall命令
-	args := []string{"dd", "--img", options.ImageURL}
-
-	// 添加通用选项
-	if options.Password != "" {
-		args = append(args, "--password", options.Password)
-	}
-	if options.SSHKey != "" {
-		args = append(args, "--ssh-key", options.SSHKey)
-	}
-	if options.SSHPort > 0 {
-		args = append(args, "--ssh-port", fmt.Sprintf("%d", options.SSHPort))
+// runReinstall 构建reinstall命令并执行
+func (si *SystemInstaller) runReinstall(options InstallOptions) error {
+	args, err := buildReinstallArgs(options)
+	if err != nil {
+		return NewOptionsRedactor(options).RedactError(err)
 	}
-
-	return si.executeReinstallScript(args)
+	return si.executeReinstallScript(args, options)
 }
 
 // executeReinstallScript 执行reinstall脚本
-func (si *SystemInstaller) executeReinstallScript(args []string) error {
+func (si *SystemInstaller) executeReinstallScript(args []string, options InstallOptions) error {
 	si.updateProgress(20, "执行安装脚本...")
 
-	scriptPath := filepath.Join(si.reinstallPath, "reinstall.sh")
-	if runtime.GOOS == "windows" {
-		scriptPath = filepath.Join(si.reinstallPath, "reinstall.bat")
+	// 脚本中的 curl/wget 使用与程序相同的代理，代理密码同样需要脱敏
+	network := NetworkOptionsFromConfig(si.Config(), si.secrets)
+	redactor := NewRedactor(options.Password, options.SSHKey, network.proxyPassword())
+	env, err := ProxyEnvironment(os.Environ(), network)
+	if err != nil {
+		return err
 	}
 
-	// 检查脚本是否存在
-	if _, err := os.Stat(scriptPath); os.IsNotExist(err) {
-		return fmt.Errorf("reinstall脚本不存在: %s", scriptPath)
+	// 校验脚本，缺失或被修改时重新解压
+	scriptInfo, err := si.ensureReinstallScript()
+	if err != nil {
+		return err
 	}
+	scriptPath := scriptInfo.Path
 
-	// 创建命令
+	// 创建命令，停止安装时取消上下文并结束脚本
+	ctx := si.runContext()
 	var cmd *exec.Cmd
 	if runtime.GOOS == "windows" {
-		cmd = exec.Command(scriptPath)
+		cmd = exec.CommandContext(ctx, scriptPath)
 		cmd.Args = append(cmd.Args, args...)
 	} else {
-		cmd = exec.Command("bash", scriptPath)
+		cmd = exec.CommandContext(ctx, "bash", scriptPath)
 		cmd.Args = append(cmd.Args, args...)
 	}
 
 	// 设置工作目录
 	cmd.Dir = si.reinstallPath
+	cmd.Env = env
+
+	// 密码和SSH密钥写入0600临时文件，分别通过环境变量和 --ssh-key 传递路径，脚本结束后删除
+	secrets, err := newScriptSecrets(options)
+	if err != nil {
+		return redactor.RedactError(err)
+	}
+	secretArgs, secretEnv, cleanup, err := secrets.prepare("")
+	if err != nil {
+		return redactor.RedactError(err)
+	}
+	defer cleanup()
+	cmd.Args = append(cmd.Args, secretArgs...)
+	cmd.Env = append(cmd.Env, secretEnv...)
 
 	// 创建管道获取输出
 	stdout, err := cmd.StdoutPipe()
@@ -326,15 +547,24 @@ func (si *SystemInstaller) executeReinstallScript(args []string) error {
 
 	// 启动命令
 	if err := cmd.Start(); err != nil {
-		return err
+		return redactor.RedactError(err)
 	}
 
-	// 监控输出
-	go si.monitorOutput(stdout, stderr)
+	// 监控输出，读取完毕后才能调用Wait
+	si.progressMutex.RLock()
+	transcript := si.transcript
+	si.progressMutex.RUnlock()
+	outputDone := si.monitorOutput(stdout, stderr, transcript, redactor)
+	outputDone.Wait()
 
 	// 等待完成
 	err = cmd.Wait()
+	if ctx.Err() != nil {
+		// StopInstallation 已经设置了停止状态
+		return fmt.Errorf("安装已停止")
+	}
 	if err != nil {
+		err = redactor.RedactError(err)
 		si.updateProgress(0, fmt.Sprintf("安装失败: %v", err))
 		si.progressMutex.Lock()
 		si.progress.Status = "error"
@@ -350,31 +580,32 @@ func (si *SystemInstaller) executeReinstallScript(args []string) error {
 	return nil
 }
 
-// monitorOutput 监控命令输出
-func (si *SystemInstaller) monitorOutput(stdout, stderr io.ReadCloser) {
-	go func() {
-		buf := make([]byte, 1024)
-		for {
-			n, err := stdout.Read(buf)
-			if err != nil {
-				break
-			}
-			output := string(buf[:n])
-			si.parseProgress(output)
-		}
-	}()
-
-	go func() {
-		buf := make([]byte, 1024)
-		for {
-			n, err := stderr.Read(buf)
-			if err != nil {
-				break
+// monitorOutput 监控命令输出，脱敏后写入transcript
+func (si *SystemInstaller) monitorOutput(stdout, stderr io.ReadCloser, transcript io.Writer, redactor *Redactor) *sync.WaitGroup {
+	var wg sync.WaitGroup
+	for _, pipe := range []io.ReadCloser{stdout, stderr} {
+		wg.Add(1)
+		go func(pipe io.ReadCloser) {
+			defer wg.Done()
+			// 按行读取，避免敏感信息被拆分在两次读取之间而漏掉脱敏
+			reader := bufio.NewReader(pipe)
+			for {
+				output, err := reader.ReadString('\n')
+				if output != "" {
+					redacted := redactor.Redact(output)
+					if transcript != nil {
+						io.WriteString(transcript, redacted)
+					}
+					si.logger.Debug("脚本输出", "line", strings.TrimRight(redacted, "\r\n"))
+					si.parseProgress(output)
+				}
+				if err != nil {
+					break
+				}
 			}
-			output := string(buf[:n])
-			si.parseProgress(output)
-		}
-	}()
+		}(pipe)
+	}
+	return &wg
 }
 
 // parseProgress 解析进度信息
@@ -411,6 +642,13 @@ func (si *SystemInstaller) updateProgress(percentage int, message string) {
 	si.progress.Message = message
 }
 
+// isInstallRunning 是否有正在运行的安装任务
+func (si *SystemInstaller) isInstallRunning() bool {
+	si.progressMutex.RLock()
+	defer si.progressMutex.RUnlock()
+	return si.installRunning
+}
+
 // GetProgress 获取安装进度
 func (si *SystemInstaller) GetProgress() InstallProgress {
 	si.progressMutex.RLock()
@@ -419,22 +657,18 @@ func (si *SystemInstaller) GetProgress() InstallProgress {
 }
 
 // StopInstallation 停止安装
+// 只取消当前这次安装的上下文，安装结束后由 InstallSystem 清除运行状态
 func (si *SystemInstaller) StopInstallation() error {
-	if !si.installRunning {
-		return fmt.Errorf("没有正在运行的安装任务")
-	}
+	si.progressMutex.Lock()
+	defer si.progressMutex.Unlock()
 
-	select {
-	case si.stopChannel <- true:
-		si.progressMutex.Lock()
-		si.progress.Status = "stopped"
-		si.progress.Message = "安装已停止"
-		si.installRunning = false
-		si.progressMutex.Unlock()
-		return nil
-	default:
-		return fmt.Errorf("无法停止安装")
+	if !si.installRunning || si.cancelInstall == nil {
+		return fmt.Errorf("没有正在运行的安装任务")
 	}
+	si.cancelInstall()
+	si.progress.Status = "stopped"
+	si.progress.Message = "安装已停止"
+	return nil
 }
 
 // GetSupportedLinuxSystems 获取支持的Linux系统列表
@@ -607,23 +841,217 @@ func (si *SystemInstaller) ValidateInstallOptions(options InstallOptions) error
 		return fmt.Errorf("不支持的操作系统类型: %s", options.OSType)
 	}
 
+	if _, err := newScriptSecrets(options); err != nil {
+		return NewOptionsRedactor(options).RedactError(err)
+	}
+	if options.VerifyTimeout < 0 {
+		return fmt.Errorf("安装后验证超时无效: %d", options.VerifyTimeout)
+	}
+	if options.ImageSHA256 != "" {
+		if decoded, err := hex.DecodeString(options.ImageSHA256); err != nil || len(decoded) != sha256.Size {
+			return fmt.Errorf("镜像SHA-256格式错误: %s", options.ImageSHA256)
+		}
+	}
+	if options.DriverBackup != "" && !fileExists(options.DriverBackup) {
+		return fmt.Errorf("驱动备份不存在: %s", options.DriverBackup)
+	}
+
+	// 校验目标是否支持所有已设置的选项
+	if _, err := buildReinstallArgs(options); err != nil {
+		return NewOptionsRedactor(options).RedactError(err)
+	}
+
+	return nil
+}
+
+// SetConfigOptions 设置配置文件路径和命令行覆盖，在 Initialize 之前调用
+func (si *SystemInstaller) SetConfigOptions(options ConfigOptions) {
+	if options.Path != "" {
+		si.configPath = options.Path
+	}
+	si.configOverrides = options.Overrides
+}
+
+// ConfigPath 配置文件路径
+func (si *SystemInstaller) ConfigPath() string {
+	return si.configPath
+}
+
+// Config 当前生效的配置（已应用环境变量和命令行覆盖）的副本
+func (si *SystemInstaller) Config() *Config {
+	si.configMutex.RLock()
+	defer si.configMutex.RUnlock()
+	return si.config.Config.Clone()
+}
+
+// FileConfig 配置文件中的配置，修改配置时以此为基础，避免把临时的覆盖写入文件
+func (si *SystemInstaller) FileConfig() *Config {
+	si.configMutex.RLock()
+	defer si.configMutex.RUnlock()
+	return si.configFile.config.Clone()
+}
+
+// ConfigSources 每个配置项的来源
+func (si *SystemInstaller) ConfigSources() map[string]ConfigValueSource {
+	si.configMutex.RLock()
+	defer si.configMutex.RUnlock()
+	sources := make(map[string]ConfigValueSource, len(si.config.Sources))
+	for key, source := range si.config.Sources {
+		sources[key] = source
+	}
+	return sources
+}
+
+// UpdateConfig 校验并保存配置文件，环境变量和命令行的覆盖仍然生效
+func (si *SystemInstaller) UpdateConfig(config *Config) error {
+	config = config.Clone()
+	config.Version = ConfigVersion
+	if err := si.checkConfigWritable(); err != nil {
+		return err
+	}
+	if err := SaveConfig(si.configPath, config); err != nil {
+		return err
+	}
+	file, err := readConfigFile(si.configPath)
+	if err != nil {
+		return err
+	}
+	si.applyConfigFile(file)
+	si.logger.Info("配置已保存", "path", si.configPath)
 	return nil
 }
 
-// loadConfig 加载配置
+// PrintConfig 读取配置并输出每个配置项的实际值和来源
+// 只读取配置，不迁移或保存配置文件，也不修改当前生效的配置；配置文件无效时输出默认配置并返回错误
+func (si *SystemInstaller) PrintConfig(w io.Writer) error {
+	file, _ := si.readConfigFile()
+	resolved, overrideErrs := si.resolveConfigFile(file)
+	for _, err := range overrideErrs {
+		if _, err := fmt.Fprintf(w, "# 忽略无效的配置覆盖: %v\n", err); err != nil {
+			return err
+		}
+	}
+	if file.err != nil {
+		if _, err := fmt.Fprintf(w, "# %v，以下为默认配置\n", file.err); err != nil {
+			return err
+		}
+	}
+	if err := resolved.Print(w); err != nil {
+		return err
+	}
+	return file.err
+}
+
+// allConfigOverrides 环境变量和命令行的覆盖，命令行优先
+func (si *SystemInstaller) allConfigOverrides() []ConfigOverride {
+	return append(EnvConfigOverrides(os.Environ()), si.configOverrides...)
+}
+
+// loadConfig 加载配置文件并应用覆盖，无效的覆盖逐项忽略，其余覆盖照常生效
 func (si *SystemInstaller) loadConfig() {
-	configFile := filepath.Join(si.workingDir, "config.json")
-	if data, err := os.ReadFile(configFile); err == nil {
-		json.Unmarshal(data, &si.config)
+	si.applyConfigFile(si.loadConfigFile())
+}
+
+// resolveConfigFile 在配置文件上应用环境变量和命令行的覆盖
+// 按顺序逐项尝试，无效的覆盖（包括与之前的覆盖组合后校验失败的）被跳过并返回对应的错误
+func (si *SystemInstaller) resolveConfigFile(file *configFile) (*ResolvedConfig, []error) {
+	resolved, err := resolveConfig(file.config, file.keys, si.configPath, nil)
+	if err != nil {
+		// 配置文件本身已经校验过，这里只是防御
+		return defaultResolvedConfig(), []error{err}
 	}
+
+	var valid []ConfigOverride
+	var errs []error
+	for _, override := range si.allConfigOverrides() {
+		candidate := append(append([]ConfigOverride(nil), valid...), override)
+		next, err := resolveConfig(file.config, file.keys, si.configPath, candidate)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s=%s: %w", override.Key, override.Value, err))
+			continue
+		}
+		valid, resolved = candidate, next
+	}
+	return resolved, errs
 }
 
-// SaveConfig 保存配置
-func (si *SystemInstaller) SaveConfig() error {
-	configFile := filepath.Join(si.workingDir, "config.json")
-	data, err := json.MarshalIndent(si.config, "", "  ")
+// applyConfigFile 应用配置文件和覆盖，无效的覆盖记录错误日志后忽略
+func (si *SystemInstaller) applyConfigFile(file *configFile) {
+	resolved, overrideErrs := si.resolveConfigFile(file)
+	for _, err := range overrideErrs {
+		si.logger.Error("忽略无效的配置覆盖", "error", err)
+	}
+	si.configMutex.Lock()
+	si.configFile = file
+	si.config = resolved
+	si.overrideErrs = overrideErrs
+	si.configMutex.Unlock()
+}
+
+// readConfigFile 读取配置文件，不做任何写入
+// 用户目录中没有配置时读取工作目录中的旧配置文件，返回实际读取的路径；配置文件无效时使用默认配置并记录错误
+func (si *SystemInstaller) readConfigFile() (*configFile, string) {
+	path := si.configPath
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		legacy := filepath.Join(si.workingDir, configFileName)
+		if _, err := os.Stat(legacy); err == nil {
+			path = legacy
+		}
+	}
+
+	file, err := readConfigFile(path)
 	if err != nil {
+		return &configFile{config: DefaultConfig(), keys: map[string]bool{}, err: fmt.Errorf("配置文件%s无效: %w", path, err)}, path
+	}
+	return file, path
+}
+
+// loadConfigFile 读取配置文件，旧版本或旧位置的配置迁移后保存到用户目录
+// 配置文件无效时使用默认配置，默认配置只在内存中使用，文件修复前拒绝保存，避免覆盖用户的配置
+func (si *SystemInstaller) loadConfigFile() *configFile {
+	file, path := si.readConfigFile()
+	if file.err != nil {
+		si.logger.Error("配置文件无效，暂时使用默认配置", "path", path, "error", file.err)
+		return file
+	}
+
+	if file.migrated || path != si.configPath {
+		if err := SaveConfig(si.configPath, file.config); err != nil {
+			si.logger.Warning("保存迁移后的配置失败", "path", si.configPath, "error", err)
+			return file
+		}
+		si.logger.Info("配置已迁移", "from", path, "to", si.configPath, "version", ConfigVersion)
+	}
+	return file
+}
+
+// SaveConfig 保存配置文件中的配置
+func (si *SystemInstaller) SaveConfig() error {
+	if err := si.checkConfigWritable(); err != nil {
 		return err
 	}
-	return os.WriteFile(configFile, data, 0644)
+	return SaveConfig(si.configPath, si.FileConfig())
+}
+
+// ConfigOverrideErrors 被忽略的环境变量和命令行配置覆盖
+func (si *SystemInstaller) ConfigOverrideErrors() []error {
+	si.configMutex.RLock()
+	defer si.configMutex.RUnlock()
+	return append([]error(nil), si.overrideErrs...)
+}
+
+// ConfigLoadError 启动时配置文件无法解析的错误，为nil表示配置文件正常
+// 错误可能包含 ConfigErrors，列出每个无效的配置项
+func (si *SystemInstaller) ConfigLoadError() error {
+	si.configMutex.RLock()
+	defer si.configMutex.RUnlock()
+	return si.configFile.err
+}
+
+// checkConfigWritable 磁盘上的配置文件无法解析时拒绝保存，需要用户先修复或删除该文件
+func (si *SystemInstaller) checkConfigWritable() error {
+	if _, err := readConfigFile(si.configPath); err != nil {
+		return fmt.Errorf("配置文件%s无法解析，为避免覆盖拒绝保存，请先修复或删除该文件: %w", si.configPath, err)
+	}
+	return nil
 }
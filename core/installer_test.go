@@ -0,0 +1,236 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStopInstallationOnlyCancelsCurrentRun(t *testing.T) {
+	si := NewSystemInstaller()
+	if err := si.StopInstallation(); err == nil {
+		t.Fatal("没有安装运行时停止应返回错误")
+	}
+
+	first, endFirst := si.beginInstall(nil)
+	if err := si.StopInstallation(); err != nil {
+		t.Fatal(err)
+	}
+	if first.Err() == nil {
+		t.Fatal("停止后本次安装的上下文应被取消")
+	}
+	if !si.isInstallRunning() {
+		t.Fatal("安装结束前应保持运行状态")
+	}
+	endFirst()
+	if si.isInstallRunning() {
+		t.Fatal("安装结束后应清除运行状态")
+	}
+	if err := si.StopInstallation(); err == nil {
+		t.Fatal("安装结束后停止应返回错误")
+	}
+
+	second, endSecond := si.beginInstall(nil)
+	defer endSecond()
+	if second.Err() != nil {
+		t.Fatal("之前的停止请求不应影响下一次安装")
+	}
+	if si.runContext() != second {
+		t.Fatal("验证应使用当前安装的上下文")
+	}
+}
+
+func TestInstallRecordUsesAppliedDefaults(t *testing.T) {
+	dir := t.TempDir()
+	si := NewSystemInstaller()
+	si.history = NewHistoryStore(dir)
+	si.transcriptDir = dir
+	si.config.Config.Install.SSHPort = 2222
+	si.config.Config.Install.Language = "en-us"
+
+	// 不支持的安装类型在执行前失败，但仍然留下审计记录
+	if err := si.InstallSystem(InstallOptions{OSType: "macos", Password: "secret"}); err == nil {
+		t.Fatal("InstallSystem 应失败")
+	}
+	records, err := si.GetInstallHistory(HistoryQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("记录数 = %d, want 1", len(records))
+	}
+	options := records[0].Options
+	if options.SSHPort != 2222 || options.Language != "en-us" {
+		t.Fatalf("记录的选项没有应用默认值: ssh_port=%d language=%q", options.SSHPort, options.Language)
+	}
+	if options.Password != redactedMask {
+		t.Fatalf("记录中的密码未脱敏: %q", options.Password)
+	}
+}
+
+func TestInvalidConfigFileIsNotOverwritten(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	broken := []byte(`{"version": 1, "log_level": "loud"`)
+	if err := os.WriteFile(path, broken, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	si := NewSystemInstaller()
+	si.workingDir = dir
+	si.SetConfigOptions(ConfigOptions{Path: path})
+	si.loadConfig()
+
+	if si.ConfigLoadError() == nil {
+		t.Fatal("无法解析的配置文件应报告错误")
+	}
+	if err := si.SaveConfig(); err == nil {
+		t.Fatal("SaveConfig 不应覆盖无法解析的配置文件")
+	}
+	if err := si.UpdateConfig(DefaultConfig()); err == nil {
+		t.Fatal("UpdateConfig 不应覆盖无法解析的配置文件")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(broken) {
+		t.Fatalf("配置文件被修改: %q", data)
+	}
+
+	// 校验失败的配置项通过 ConfigErrors 报告
+	if err := os.WriteFile(path, []byte(`{"version": 1, "log_level": "loud"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	si.loadConfig()
+	var configErrs ConfigErrors
+	if !errors.As(si.ConfigLoadError(), &configErrs) || len(configErrs) == 0 {
+		t.Fatalf("ConfigLoadError = %v, want ConfigErrors", si.ConfigLoadError())
+	}
+}
+
+func TestInvalidConfigOverrideIsDroppedAlone(t *testing.T) {
+	dir := t.TempDir()
+	si := NewSystemInstaller()
+	si.workingDir = dir
+	si.SetConfigOptions(ConfigOptions{
+		Path: filepath.Join(dir, "config.json"),
+		Overrides: []ConfigOverride{
+			{Key: "log_level", Value: "debug", Source: ConfigSourceCLI, Origin: "-set"},
+			{Key: "download.concurrency", Value: "many", Source: ConfigSourceCLI, Origin: "-set"},
+			{Key: "theme", Value: "dark", Source: ConfigSourceCLI, Origin: "-set"},
+		},
+	})
+	si.loadConfig()
+
+	config := si.Config()
+	if config.LogLevel != "debug" || config.Theme != "dark" {
+		t.Fatalf("有效的覆盖应生效: log_level=%q theme=%q", config.LogLevel, config.Theme)
+	}
+	if config.Download.Concurrency != DefaultConfig().Download.Concurrency {
+		t.Fatalf("无效的覆盖不应生效: concurrency=%d", config.Download.Concurrency)
+	}
+	if errs := si.ConfigOverrideErrors(); len(errs) != 1 {
+		t.Fatalf("ConfigOverrideErrors = %v, want 1 error", errs)
+	}
+}
+
+func TestPrintConfigHasNoSideEffects(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "user", "config.json")
+	// 工作目录中的旧版本配置，正常加载时会迁移到用户目录
+	legacy := filepath.Join(dir, configFileName)
+	legacyData := []byte(`{"api_base_url": "https://legacy.example.com", "log_level": "warning"}`)
+	if err := os.WriteFile(legacy, legacyData, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	si := NewSystemInstaller()
+	si.workingDir = dir
+	si.SetConfigOptions(ConfigOptions{Path: path})
+	before := si.Config()
+
+	var out strings.Builder
+	if err := si.PrintConfig(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "https://legacy.example.com") {
+		t.Fatalf("输出应包含旧配置中的值:\n%s", out.String())
+	}
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatal("PrintConfig 不应写入配置文件")
+	}
+	if data, _ := os.ReadFile(legacy); string(data) != string(legacyData) {
+		t.Fatal("PrintConfig 不应修改旧配置文件")
+	}
+	if si.Config().LogLevel != before.LogLevel || si.Config().API.BaseURL != before.API.BaseURL {
+		t.Fatal("PrintConfig 不应修改当前生效的配置")
+	}
+}
+
+func TestImageHash(t *testing.T) {
+	image := filepath.Join(t.TempDir(), "disk.raw")
+	if err := os.WriteFile(image, []byte("disk image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	catalogHash := strings.Repeat("AB", 32)
+
+	tests := []struct {
+		name    string
+		options InstallOptions
+		want    string
+	}{
+		{"远程镜像使用目录中的哈希", InstallOptions{ImageURL: "https://example.com/disk.raw.gz", ImageSHA256: catalogHash}, strings.ToLower(catalogHash)},
+		{"目录没有登记哈希", InstallOptions{ISOURL: "https://example.com/win.iso"}, ""},
+		{"本地镜像", InstallOptions{ImageURL: "file://" + image, ImageSHA256: catalogHash}, sha256Hex([]byte("disk image"))},
+		{"没有镜像", InstallOptions{OSType: "linux"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := imageHash(context.Background(), tt.options)
+			if err != nil || got != tt.want {
+				t.Fatalf("imageHash = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := imageHash(ctx, InstallOptions{ImageURL: "file://" + image}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("停止安装后计算哈希应中断: %v", err)
+	}
+}
+
+func TestInjectBackupDriversUsesInstallContext(t *testing.T) {
+	si := NewSystemInstaller()
+	si.workingDir = t.TempDir()
+	si.drivers = newHardwareDriverManager(t, true)
+	options := InstallOptions{OSType: "windows", DriverBackup: newWindowsBackupFixture(t, DriverBackupZip)}
+
+	_, end := si.beginInstall(nil)
+	if err := si.injectBackupDrivers(&options); err != nil {
+		t.Fatal(err)
+	}
+	if len(options.Drivers) != 1 || options.Drivers[0] != filepath.Join(si.workingDir, "drivers", "inject", "drivers", "oem3") {
+		t.Fatalf("Drivers = %v", options.Drivers)
+	}
+	end()
+
+	// 停止安装后不再解压驱动
+	options.Drivers = nil
+	_, end = si.beginInstall(nil)
+	defer end()
+	if err := si.StopInstallation(); err != nil {
+		t.Fatal(err)
+	}
+	if err := si.injectBackupDrivers(&options); err == nil || err.Error() != "安装已停止" {
+		t.Fatalf("err = %v, want 安装已停止", err)
+	}
+	if len(options.Drivers) != 0 {
+		t.Fatalf("Drivers = %v", options.Drivers)
+	}
+}
@@ -0,0 +1,258 @@
+package core
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"SystemReinstaller/utils"
+)
+
+// secretConfigKeys 配置中名称包含这些词的键在导出时脱敏
+var secretConfigKeys = []string{"password", "secret", "token", "key", "credential"}
+
+// LogExportResult 日志导出结果
+type LogExportResult struct {
+	Path   string   `json:"path"`
+	Files  int      `json:"files"`
+	Size   int64    `json:"size"`
+	Errors []string `json:"errors,omitempty"` // 未能收集的内容，不影响导出
+}
+
+// logBundleManifest 导出包中的说明文件
+type logBundleManifest struct {
+	CreatedAt       time.Time `json:"created_at"`
+	AppVersion      string    `json:"app_version"`
+	OS              string    `json:"os"`
+	Arch            string    `json:"arch"`
+	HostFingerprint string    `json:"host_fingerprint"`
+	Files           []string  `json:"files"`
+	Errors          []string  `json:"errors,omitempty"`
+}
+
+// logBundle 正在写入的zip导出包
+type logBundle struct {
+	writer   *zip.Writer
+	manifest logBundleManifest
+	skip     string // 导出文件本身，放在日志目录中时跳过
+}
+
+// addFile 将磁盘文件加入导出包
+func (b *logBundle) addFile(name, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+	if strings.HasSuffix(name, ".gz") {
+		header.Method = zip.Store
+	}
+
+	w, err := b.writer.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, file); err != nil {
+		return err
+	}
+	b.manifest.Files = append(b.manifest.Files, name)
+	return nil
+}
+
+// addJSON 将数据编码为JSON加入导出包
+func (b *logBundle) addJSON(name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	w, err := b.writer.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	b.manifest.Files = append(b.manifest.Files, name)
+	return nil
+}
+
+// addDir 将目录下的所有文件加入导出包，prefix为包内目录名
+func (b *logBundle) addDir(prefix, dir string) {
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		if absPath, _ := filepath.Abs(path); absPath == b.skip {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if err := b.addFile(prefix+"/"+filepath.ToSlash(rel), path); err != nil {
+			b.fail("%s: %v", path, err)
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		b.fail("%s: %v", dir, err)
+	}
+}
+
+// fail 记录未能收集的内容
+func (b *logBundle) fail(format string, args ...interface{}) {
+	b.manifest.Errors = append(b.manifest.Errors, fmt.Sprintf(format, args...))
+}
+
+// ExportLogs 将日志、安装输出记录、安装历史、系统检测结果和脱敏后的配置打包为zip，用于提交支持工单
+func (si *SystemInstaller) ExportLogs(savePath string) (*LogExportResult, error) {
+	absPath, err := filepath.Abs(savePath)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(absPath), 0755); err != nil {
+		return nil, fmt.Errorf("创建导出目录失败: %v", err)
+	}
+	file, err := os.Create(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("创建导出文件失败: %v", err)
+	}
+
+	bundle := &logBundle{
+		writer: zip.NewWriter(file),
+		skip:   absPath,
+		manifest: logBundleManifest{
+			CreatedAt:       time.Now(),
+			AppVersion:      AppVersion,
+			OS:              runtime.GOOS,
+			Arch:            runtime.GOARCH,
+			HostFingerprint: HostFingerprint(),
+		},
+	}
+
+	// 日志目录包含轮转的旧日志和安装输出记录
+	bundle.addDir("logs", filepath.Join(si.workingDir, utils.DefaultLogDir))
+	if !strings.HasPrefix(si.transcriptDir, filepath.Join(si.workingDir, utils.DefaultLogDir)) {
+		bundle.addDir("transcripts", si.transcriptDir)
+	}
+	if _, err := os.Stat(si.history.Path()); err == nil {
+		if err := bundle.addFile("history/"+historyFileName, si.history.Path()); err != nil {
+			bundle.fail("%s: %v", si.history.Path(), err)
+		}
+	}
+
+	detector := NewSystemDetector()
+	if detection, err := detector.GetCompleteSystemInfo(); err != nil {
+		bundle.fail("系统检测失败: %v", err)
+	} else if err := bundle.addJSON("detection.json", detection); err != nil {
+		bundle.fail("detection.json: %v", err)
+	}
+
+	config := si.Config()
+	if proxy, err := url.Parse(config.Proxy.URL); err == nil && proxy.User != nil {
+		config.Proxy.URL = proxy.Redacted()
+	}
+	if err := bundle.addJSON("config.json", redactConfig(configMap(config))); err != nil {
+		bundle.fail("config.json: %v", err)
+	}
+	if info := si.GetReinstallScriptInfo(); info != nil {
+		if err := bundle.addJSON("reinstall_script.json", info); err != nil {
+			bundle.fail("reinstall_script.json: %v", err)
+		}
+	}
+
+	manifestErr := bundle.addJSON("manifest.json", bundle.manifest)
+	zipErr := bundle.writer.Close()
+	fileErr := file.Close()
+	if err := firstError(manifestErr, zipErr, fileErr); err != nil {
+		os.Remove(absPath)
+		return nil, fmt.Errorf("写入导出文件失败: %v", err)
+	}
+
+	result := &LogExportResult{
+		Path:   absPath,
+		Files:  len(bundle.manifest.Files),
+		Errors: bundle.manifest.Errors,
+	}
+	if info, err := os.Stat(absPath); err == nil {
+		result.Size = info.Size()
+	}
+	si.logger.Info("已导出日志", "path", absPath, "files", result.Files, "bytes", result.Size)
+	return result, nil
+}
+
+// configMap 将配置转换为JSON对象，便于按键名脱敏
+func configMap(config *Config) map[string]interface{} {
+	result := map[string]interface{}{}
+	if data, err := json.Marshal(config); err == nil {
+		json.Unmarshal(data, &result)
+	}
+	return result
+}
+
+// redactConfig 复制配置并隐藏敏感的值
+func redactConfig(config map[string]interface{}) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(config))
+	for key, value := range config {
+		redacted[key] = redactConfigValue(key, value)
+	}
+	return redacted
+}
+
+// redactConfigValue 递归处理嵌套的对象和数组
+func redactConfigValue(key string, value interface{}) interface{} {
+	lower := strings.ToLower(key)
+	for _, word := range secretConfigKeys {
+		if strings.Contains(lower, word) {
+			if value == nil || value == "" {
+				return value
+			}
+			return redactedMask
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return redactConfig(v)
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = redactConfigValue("", item)
+		}
+		return items
+	default:
+		return value
+	}
+}
+
+// firstError 返回第一个非nil的错误
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
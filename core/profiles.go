@@ -0,0 +1,555 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// profilesFileName 安装配置方案文件名
+const profilesFileName = "profiles.json"
+
+// profilesVersion 方案文件和导出文件的格式版本
+const profilesVersion = 1
+
+// maxProfileNameLength 方案名称的最大长度（字符数）
+const maxProfileNameLength = 64
+
+// ErrProfileNotFound 方案不存在
+var ErrProfileNotFound = errors.New("安装方案不存在")
+
+// InstallProfile 保存的安装方案：一组可重复使用的安装选项
+//
+// 密码和SSH密钥不会写入方案文件。设置了密钥库时保存在密钥库中，Secrets 记录保存了哪些字段；
+// 否则每次安装时通过覆盖项提供。
+type InstallProfile struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Options     InstallOptions `json:"options"`
+	Secrets     []string       `json:"secrets,omitempty"` // 保存在密钥库中的字段：password、ssh_key
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// profileDocument 方案文件和导出文件的内容
+type profileDocument struct {
+	Version  int              `json:"version"`
+	Profiles []InstallProfile `json:"profiles"`
+}
+
+// ProfileImportResult 导入结果
+type ProfileImportResult struct {
+	Imported []string `json:"imported"`
+	Skipped  []string `json:"skipped,omitempty"` // 已存在且未选择覆盖的方案
+}
+
+// WithoutSecrets 去掉密码和SSH密钥后的安装选项副本
+func (o InstallOptions) WithoutSecrets() InstallOptions {
+	cleaned := o.Redacted()
+	cleaned.Password = ""
+	cleaned.SSHKey = ""
+	return cleaned
+}
+
+// Apply 以方案为基础应用本次安装的覆盖项，overrides 的键与 InstallOptions 的JSON字段相同
+// extra_options 按键合并，其余字段整体替换
+func (p InstallProfile) Apply(overrides map[string]interface{}) (InstallOptions, error) {
+	options := p.Options.WithoutSecrets()
+	if len(overrides) == 0 {
+		return options, nil
+	}
+
+	data, err := json.Marshal(overrides)
+	if err != nil {
+		return options, err
+	}
+	if err := json.Unmarshal(data, &options); err != nil {
+		return options, fmt.Errorf("覆盖项格式错误: %v", err)
+	}
+	return options, nil
+}
+
+// validateProfileName 校验方案名称
+func validateProfileName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("方案名称不能为空")
+	}
+	if len([]rune(name)) > maxProfileNameLength {
+		return fmt.Errorf("方案名称不能超过%d个字符", maxProfileNameLength)
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("方案名称包含控制字符")
+		}
+	}
+	return nil
+}
+
+// profileSecretPrefix 方案的敏感字段在密钥库中的键前缀，方案名不区分大小写
+func profileSecretPrefix(name string) string {
+	return "profile/" + strings.ToLower(name)
+}
+
+// ProfileStore 安装方案存储，所有方案保存在一个JSON文件中
+type ProfileStore struct {
+	path    string
+	secrets SecretStore // 为nil时不保存密码和SSH密钥
+	mutex   sync.Mutex
+}
+
+// NewProfileStore 创建安装方案存储
+func NewProfileStore(path string) *ProfileStore {
+	return &ProfileStore{path: path}
+}
+
+// SetSecretStore 设置保存方案密码和SSH密钥的密钥库
+func (ps *ProfileStore) SetSecretStore(store SecretStore) {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+	ps.secrets = store
+}
+
+// DefaultProfilesPath 默认的方案文件路径，位于当前用户的配置目录
+func DefaultProfilesPath() string {
+	return filepath.Join(UserConfigDir(), profilesFileName)
+}
+
+// load 读取所有方案，文件不存在时返回空列表
+func (ps *ProfileStore) load() ([]InstallProfile, error) {
+	data, err := os.ReadFile(ps.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取安装方案失败: %v", err)
+	}
+	var document profileDocument
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("安装方案文件格式错误: %v", err)
+	}
+	return document.Profiles, nil
+}
+
+// save 按名称排序后原子地写入所有方案
+func (ps *ProfileStore) save(profiles []InstallProfile) error {
+	sort.Slice(profiles, func(i, j int) bool {
+		return strings.ToLower(profiles[i].Name) < strings.ToLower(profiles[j].Name)
+	})
+	data, err := json.MarshalIndent(profileDocument{Version: profilesVersion, Profiles: profiles}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(ps.path), 0700); err != nil {
+		return fmt.Errorf("创建配置目录失败: %v", err)
+	}
+	return writeFileAtomic(ps.path, data, 0600)
+}
+
+// findProfile 按名称查找方案，不区分大小写
+func findProfile(profiles []InstallProfile, name string) int {
+	for i, profile := range profiles {
+		if strings.EqualFold(profile.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// List 列出所有方案
+func (ps *ProfileStore) List() ([]InstallProfile, error) {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+	profiles, err := ps.load()
+	if err != nil {
+		return nil, err
+	}
+	if profiles == nil {
+		profiles = []InstallProfile{}
+	}
+	return profiles, nil
+}
+
+// Get 按名称获取方案
+func (ps *ProfileStore) Get(name string) (*InstallProfile, error) {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+	profiles, err := ps.load()
+	if err != nil {
+		return nil, err
+	}
+	index := findProfile(profiles, name)
+	if index < 0 {
+		return nil, fmt.Errorf("%w: %s", ErrProfileNotFound, name)
+	}
+	return &profiles[index], nil
+}
+
+// Create 新建方案，同名方案已存在时报错
+func (ps *ProfileStore) Create(profile InstallProfile) (*InstallProfile, error) {
+	if err := validateProfileName(profile.Name); err != nil {
+		return nil, err
+	}
+
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+	profiles, err := ps.load()
+	if err != nil {
+		return nil, err
+	}
+	if findProfile(profiles, profile.Name) >= 0 {
+		return nil, fmt.Errorf("安装方案已存在: %s", profile.Name)
+	}
+
+	secrets, err := ps.saveSecrets(profile.Name, profile.Options, nil)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	profile.Options = profile.Options.WithoutSecrets()
+	profile.Secrets = secrets
+	profile.CreatedAt = now
+	profile.UpdatedAt = now
+	if err := ps.save(append(profiles, profile)); err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// Update 修改方案，profile.Name 与 name 不同时为重命名
+// 密码或SSH密钥为空时保留密钥库中已保存的值，从 profile.Secrets 中去掉的字段会被删除
+func (ps *ProfileStore) Update(name string, profile InstallProfile) (*InstallProfile, error) {
+	if err := validateProfileName(profile.Name); err != nil {
+		return nil, err
+	}
+
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+	profiles, err := ps.load()
+	if err != nil {
+		return nil, err
+	}
+	index := findProfile(profiles, name)
+	if index < 0 {
+		return nil, fmt.Errorf("%w: %s", ErrProfileNotFound, name)
+	}
+	if other := findProfile(profiles, profile.Name); other >= 0 && other != index {
+		return nil, fmt.Errorf("安装方案已存在: %s", profile.Name)
+	}
+
+	secrets, err := ps.updateSecrets(profiles[index], profile)
+	if err != nil {
+		return nil, err
+	}
+	profile.Options = profile.Options.WithoutSecrets()
+	profile.Secrets = secrets
+	profile.CreatedAt = profiles[index].CreatedAt
+	profile.UpdatedAt = time.Now()
+	profiles[index] = profile
+	if err := ps.save(profiles); err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// Delete 删除方案
+func (ps *ProfileStore) Delete(name string) error {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+	profiles, err := ps.load()
+	if err != nil {
+		return err
+	}
+	index := findProfile(profiles, name)
+	if index < 0 {
+		return fmt.Errorf("%w: %s", ErrProfileNotFound, name)
+	}
+	if err := ps.deleteSecrets(profiles[index]); err != nil {
+		return err
+	}
+	return ps.save(append(profiles[:index], profiles[index+1:]...))
+}
+
+// LoadSecrets 从密钥库读取方案保存的密码和SSH密钥，options 中已有的值不会被覆盖
+func (ps *ProfileStore) LoadSecrets(profile InstallProfile, options *InstallOptions) error {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+	if len(profile.Secrets) == 0 {
+		return nil
+	}
+	if ps.secrets == nil {
+		return fmt.Errorf("安装方案 %s 的密码保存在密钥库中，但未配置密钥库", profile.Name)
+	}
+	return options.LoadSecrets(ps.secrets, profileSecretPrefix(profile.Name), profile.Secrets)
+}
+
+// saveSecrets 将方案的密码和SSH密钥保存到密钥库，keep 中的字段为空时保留原值
+// 返回密钥库中保存了的字段
+func (ps *ProfileStore) saveSecrets(name string, options InstallOptions, keep []string) ([]string, error) {
+	if ps.secrets == nil {
+		return nil, nil
+	}
+	prefix := profileSecretPrefix(name)
+	if err := options.LoadSecrets(ps.secrets, prefix, keep); err != nil {
+		return nil, fmt.Errorf("修改方案密码需要先解锁密钥库: %w", err)
+	}
+	if options.Password == "" && options.SSHKey == "" {
+		// 不要求为没有敏感字段的方案解锁密钥库
+		if _, err := options.SaveSecrets(ps.secrets, prefix); err != nil && !errors.Is(err, ErrSecretStoreLocked) {
+			return nil, err
+		}
+		return nil, nil
+	}
+	secrets, err := options.SaveSecrets(ps.secrets, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("保存方案密码需要先解锁密钥库: %w", err)
+	}
+	return secrets, nil
+}
+
+// updateSecrets 修改方案时更新密钥库，重命名时移动到新名称下
+func (ps *ProfileStore) updateSecrets(current, updated InstallProfile) ([]string, error) {
+	keep := []string{}
+	for _, field := range updated.Secrets {
+		if containsString(current.Secrets, field) {
+			keep = append(keep, field)
+		}
+	}
+	if ps.secrets == nil {
+		return nil, nil
+	}
+	if strings.EqualFold(current.Name, updated.Name) {
+		unchanged := len(keep) == len(current.Secrets) && updated.Options.Password == "" && updated.Options.SSHKey == ""
+		if unchanged {
+			// 只修改了其他选项，不需要解锁密钥库
+			return current.Secrets, nil
+		}
+		return ps.saveSecrets(updated.Name, updated.Options, keep)
+	}
+
+	// 重命名：先读出要保留的旧值，再保存到新名称下并删除旧的键
+	options := updated.Options
+	if err := options.LoadSecrets(ps.secrets, profileSecretPrefix(current.Name), keep); err != nil {
+		return nil, err
+	}
+	secrets, err := ps.saveSecrets(updated.Name, options, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := ps.deleteSecrets(current); err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+// deleteSecrets 删除方案在密钥库中的密码和SSH密钥
+func (ps *ProfileStore) deleteSecrets(profile InstallProfile) error {
+	if ps.secrets == nil || len(profile.Secrets) == 0 {
+		return nil
+	}
+	if _, err := (InstallOptions{}).SaveSecrets(ps.secrets, profileSecretPrefix(profile.Name)); err != nil {
+		return fmt.Errorf("删除方案密码需要先解锁密钥库: %w", err)
+	}
+	return nil
+}
+
+// profileFormat 按指定格式或文件扩展名确定导入导出格式
+func profileFormat(path, format string) (string, error) {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml":
+			format = "yaml"
+		default:
+			format = "json"
+		}
+	}
+	switch strings.ToLower(format) {
+	case "json":
+		return "json", nil
+	case "yaml", "yml":
+		return "yaml", nil
+	default:
+		return "", fmt.Errorf("不支持的格式: %s", format)
+	}
+}
+
+// Export 导出方案，names 为空时导出全部，format 为 json 或 yaml
+func (ps *ProfileStore) Export(w io.Writer, names []string, format string) error {
+	profiles, err := ps.List()
+	if err != nil {
+		return err
+	}
+	if len(names) > 0 {
+		selected := make([]InstallProfile, 0, len(names))
+		for _, name := range names {
+			index := findProfile(profiles, name)
+			if index < 0 {
+				return fmt.Errorf("%w: %s", ErrProfileNotFound, name)
+			}
+			selected = append(selected, profiles[index])
+		}
+		profiles = selected
+	}
+	document := profileDocument{Version: profilesVersion, Profiles: profiles}
+
+	format, err = profileFormat("", format)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(document)
+	case "yaml":
+		// 经JSON转换，YAML中的字段名与JSON一致
+		data, err := json.Marshal(document)
+		if err != nil {
+			return err
+		}
+		var tree interface{}
+		if err := json.Unmarshal(data, &tree); err != nil {
+			return err
+		}
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(tree); err != nil {
+			return err
+		}
+		return encoder.Close()
+	default:
+		return fmt.Errorf("不支持的格式: %s", format)
+	}
+}
+
+// ExportFile 导出方案到文件，format 为空时按扩展名判断
+func (ps *ProfileStore) ExportFile(path string, names []string, format string) error {
+	format, err := profileFormat(path, format)
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("创建导出文件失败: %v", err)
+	}
+	if err := ps.Export(file, names, format); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// parseProfiles 解析导入的内容：完整的导出文件、方案数组或单个方案
+func parseProfiles(data []byte, format string) ([]InstallProfile, error) {
+	if format == "yaml" {
+		var tree interface{}
+		if err := yaml.Unmarshal(data, &tree); err != nil {
+			return nil, fmt.Errorf("YAML格式错误: %v", err)
+		}
+		converted, err := json.Marshal(tree)
+		if err != nil {
+			return nil, fmt.Errorf("YAML格式错误: %v", err)
+		}
+		data = converted
+	}
+
+	trimmed := strings.TrimSpace(string(data))
+	if strings.HasPrefix(trimmed, "[") {
+		var profiles []InstallProfile
+		if err := json.Unmarshal(data, &profiles); err != nil {
+			return nil, fmt.Errorf("安装方案格式错误: %v", err)
+		}
+		return profiles, nil
+	}
+
+	var document struct {
+		profileDocument
+		InstallProfile
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("安装方案格式错误: %v", err)
+	}
+	if document.Version > profilesVersion {
+		return nil, fmt.Errorf("安装方案文件版本(%d)高于程序支持的版本(%d)", document.Version, profilesVersion)
+	}
+	if document.profileDocument.Profiles != nil {
+		return document.profileDocument.Profiles, nil
+	}
+	if document.InstallProfile.Name != "" {
+		return []InstallProfile{document.InstallProfile}, nil
+	}
+	return nil, fmt.Errorf("文件中没有安装方案")
+}
+
+// ImportFile 从JSON或YAML文件导入方案，overwrite 为 false 时跳过已存在的方案
+// 导入的方案中的密码和SSH密钥会被丢弃
+func (ps *ProfileStore) ImportFile(path string, format string, overwrite bool) (*ProfileImportResult, error) {
+	format, err := profileFormat(path, format)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取导入文件失败: %v", err)
+	}
+	imported, err := parseProfiles(data, format)
+	if err != nil {
+		return nil, err
+	}
+	for _, profile := range imported {
+		if err := validateProfileName(profile.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+	profiles, err := ps.load()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ProfileImportResult{Imported: []string{}}
+	now := time.Now()
+	for _, profile := range imported {
+		profile.Options = profile.Options.WithoutSecrets()
+		profile.Secrets = nil
+		profile.UpdatedAt = now
+		if profile.CreatedAt.IsZero() {
+			profile.CreatedAt = now
+		}
+
+		index := findProfile(profiles, profile.Name)
+		switch {
+		case index < 0:
+			profiles = append(profiles, profile)
+		case overwrite:
+			// 导入的方案不带密码，被覆盖方案保存在密钥库中的密码一并删除，避免留下无主的密钥
+			if err := ps.deleteSecrets(profiles[index]); err != nil {
+				return nil, err
+			}
+			profiles[index] = profile
+		default:
+			result.Skipped = append(result.Skipped, profile.Name)
+			continue
+		}
+		result.Imported = append(result.Imported, profile.Name)
+	}
+
+	if len(result.Imported) > 0 {
+		if err := ps.save(profiles); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
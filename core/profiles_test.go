@@ -0,0 +1,288 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// memorySecretStore 内存中的密钥库
+type memorySecretStore map[string]string
+
+func (m memorySecretStore) Get(key string) (string, error) {
+	value, ok := m[key]
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	return value, nil
+}
+
+func (m memorySecretStore) Set(key, value string) error {
+	if value == "" {
+		delete(m, key)
+		return nil
+	}
+	m[key] = value
+	return nil
+}
+
+func (m memorySecretStore) Delete(key string) error {
+	delete(m, key)
+	return nil
+}
+
+func (m memorySecretStore) List(prefix string) ([]string, error) {
+	var keys []string
+	for key := range m {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func TestImportOverwriteDeletesSecrets(t *testing.T) {
+	dir := t.TempDir()
+	secrets := memorySecretStore{}
+	store := NewProfileStore(filepath.Join(dir, "profiles.json"))
+	store.SetSecretStore(secrets)
+
+	if _, err := store.Create(InstallProfile{Name: "web", Options: InstallOptions{OSType: "linux", System: "debian", Password: "secret", SSHKey: "ssh-ed25519 AAAA"}}); err != nil {
+		t.Fatal(err)
+	}
+	if keys, _ := secrets.List(profileSecretPrefix("web")); len(keys) != 2 {
+		t.Fatalf("创建方案后密钥 = %v, want 2", keys)
+	}
+
+	importPath := filepath.Join(dir, "import.json")
+	if err := os.WriteFile(importPath, []byte(`[{"name": "web", "options": {"os_type": "linux", "system": "ubuntu"}}]`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// 不覆盖时保留原方案和密钥
+	if _, err := store.ImportFile(importPath, "", false); err != nil {
+		t.Fatal(err)
+	}
+	if keys, _ := secrets.List(profileSecretPrefix("web")); len(keys) != 2 {
+		t.Fatalf("跳过导入后密钥 = %v, want 2", keys)
+	}
+
+	result, err := store.ImportFile(importPath, "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Imported) != 1 {
+		t.Fatalf("Imported = %v", result.Imported)
+	}
+	if keys, _ := secrets.List(profileSecretPrefix("web")); len(keys) != 0 {
+		t.Fatalf("覆盖导入后仍有旧方案的密钥: %v", keys)
+	}
+	profile, err := store.Get("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Options.System != "ubuntu" || len(profile.Secrets) != 0 {
+		t.Fatalf("导入的方案 = %+v", profile)
+	}
+}
+
+func TestProfileApply(t *testing.T) {
+	profile := InstallProfile{Name: "web", Options: InstallOptions{
+		OSType:       "linux",
+		System:       "debian",
+		Password:     "secret",
+		SSHPort:      22,
+		Drivers:      []string{"virtio"},
+		ExtraOptions: map[string]string{"hold": "1", "ci": ""},
+	}}
+
+	options, err := profile.Apply(map[string]interface{}{
+		"ssh_port":      2222,
+		"drivers":       []string{"e1000"},
+		"extra_options": map[string]string{"hold": "2", "frpc-toml": "/tmp/frpc.toml"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if options.SSHPort != 2222 || options.System != "debian" || options.Password != "" {
+		t.Errorf("options = %+v", options)
+	}
+	if !reflect.DeepEqual(options.Drivers, []string{"e1000"}) {
+		t.Errorf("Drivers = %v, 其余字段应整体替换", options.Drivers)
+	}
+	wantExtra := map[string]string{"hold": "2", "ci": "", "frpc-toml": "/tmp/frpc.toml"}
+	if !reflect.DeepEqual(options.ExtraOptions, wantExtra) {
+		t.Errorf("ExtraOptions = %v, want %v", options.ExtraOptions, wantExtra)
+	}
+	// 方案本身不受覆盖项影响
+	if profile.Options.ExtraOptions["hold"] != "1" || len(profile.Options.ExtraOptions) != 2 || profile.Options.Drivers[0] != "virtio" {
+		t.Errorf("Apply 修改了方案: %+v", profile.Options)
+	}
+
+	// 本次安装提供的密码可以覆盖
+	options, err = profile.Apply(map[string]interface{}{"password": "override"})
+	if err != nil || options.Password != "override" {
+		t.Errorf("Apply(password) = %+v, %v", options, err)
+	}
+
+	if options, err := profile.Apply(nil); err != nil || options.Password != "" || options.SSHPort != 22 {
+		t.Errorf("Apply(nil) = %+v, %v", options, err)
+	}
+	if _, err := profile.Apply(map[string]interface{}{"ssh_port": "abc"}); err == nil {
+		t.Error("类型错误的覆盖项应返回错误")
+	}
+}
+
+func TestProfileExportImportRoundTrip(t *testing.T) {
+	for _, ext := range []string{".json", ".yaml"} {
+		t.Run(ext, func(t *testing.T) {
+			dir := t.TempDir()
+			source := NewProfileStore(filepath.Join(dir, "source.json"))
+			source.SetSecretStore(memorySecretStore{})
+			created := []InstallProfile{
+				{Name: "Web 服务器", Description: "香港节点", Options: InstallOptions{
+					OSType: "linux", System: "debian", Version: "12", Password: "p@ssw0rd-42", SSHPort: 2222,
+					Minimal: true, ExtraOptions: map[string]string{"hold": "2"},
+				}},
+				{Name: "win", Options: InstallOptions{OSType: "windows", ImageName: "Windows 11 Pro", RDPPort: 3389, Drivers: []string{"virtio"}}},
+			}
+			for _, profile := range created {
+				if _, err := source.Create(profile); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			exportPath := filepath.Join(dir, "export"+ext)
+			if err := source.ExportFile(exportPath, nil, ""); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(exportPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(data), "p@ssw0rd-42") {
+				t.Fatalf("导出文件中包含密码:\n%s", data)
+			}
+
+			target := NewProfileStore(filepath.Join(dir, "target.json"))
+			result, err := target.ImportFile(exportPath, "", false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Imported) != 2 || len(result.Skipped) != 0 {
+				t.Fatalf("result = %+v", result)
+			}
+			for _, want := range created {
+				got, err := target.Get(want.Name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.Description != want.Description || len(got.Secrets) != 0 {
+					t.Errorf("%s: profile = %+v", want.Name, got)
+				}
+				if !reflect.DeepEqual(got.Options, want.Options.WithoutSecrets()) {
+					t.Errorf("%s: options = %+v\nwant %+v", want.Name, got.Options, want.Options.WithoutSecrets())
+				}
+			}
+
+			// 再次导入时已存在的方案被跳过
+			result, err = target.ImportFile(exportPath, "", false)
+			if err != nil || len(result.Imported) != 0 || len(result.Skipped) != 2 {
+				t.Fatalf("重复导入 result = %+v, err = %v", result, err)
+			}
+		})
+	}
+}
+
+func TestProfileRenameMovesSecrets(t *testing.T) {
+	secrets := memorySecretStore{}
+	store := NewProfileStore(filepath.Join(t.TempDir(), "profiles.json"))
+	store.SetSecretStore(secrets)
+	if _, err := store.Create(InstallProfile{Name: "web", Options: InstallOptions{OSType: "linux", Password: "secret", SSHKey: "ssh-ed25519 AAAA"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重命名时保留密码，去掉SSH密钥
+	updated, err := store.Update("web", InstallProfile{Name: "Web-Prod", Options: InstallOptions{OSType: "linux"}, Secrets: []string{SecretFieldPassword}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(updated.Secrets, []string{SecretFieldPassword}) {
+		t.Errorf("Secrets = %v", updated.Secrets)
+	}
+	if keys, _ := secrets.List(profileSecretPrefix("web") + "/"); len(keys) != 0 {
+		t.Errorf("旧名称下仍有密钥: %v", keys)
+	}
+	want := memorySecretStore{profileSecretPrefix("Web-Prod") + "/" + SecretFieldPassword: "secret"}
+	if !reflect.DeepEqual(secrets, want) {
+		t.Errorf("secrets = %v, want %v", secrets, want)
+	}
+
+	var options InstallOptions
+	if err := store.LoadSecrets(*updated, &options); err != nil || options.Password != "secret" || options.SSHKey != "" {
+		t.Errorf("LoadSecrets = %+v, %v", options, err)
+	}
+	if _, err := store.Get("web"); !errors.Is(err, ErrProfileNotFound) {
+		t.Errorf("Get(旧名称) err = %v", err)
+	}
+
+	// 只改变大小写不移动密钥
+	if _, err := store.Update("web-prod", InstallProfile{Name: "WEB-PROD", Options: InstallOptions{OSType: "linux"}, Secrets: []string{SecretFieldPassword}}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(secrets, want) {
+		t.Errorf("secrets = %v, want %v", secrets, want)
+	}
+}
+
+func TestProfileNameValidation(t *testing.T) {
+	store := NewProfileStore(filepath.Join(t.TempDir(), "profiles.json"))
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"web", true},
+		{"香港 Debian 12", true},
+		{strings.Repeat("方", maxProfileNameLength), true},
+		{"", false},
+		{"   ", false},
+		{strings.Repeat("a", maxProfileNameLength+1), false},
+		{"web\nprod", false},
+		{"web\x00", false},
+	}
+	for _, tt := range tests {
+		err := validateProfileName(tt.name)
+		if (err == nil) != tt.valid {
+			t.Errorf("validateProfileName(%q) = %v, want valid %v", tt.name, err, tt.valid)
+		}
+		if !tt.valid {
+			if _, err := store.Create(InstallProfile{Name: tt.name}); err == nil {
+				t.Errorf("Create(%q) 应失败", tt.name)
+			}
+		}
+	}
+
+	if _, err := store.Create(InstallProfile{Name: "web"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Create(InstallProfile{Name: "WEB"}); err == nil {
+		t.Error("名称不区分大小写，重复创建应失败")
+	}
+	if _, err := store.Update("web", InstallProfile{Name: "web\t"}); err == nil {
+		t.Error("Update 应校验新名称")
+	}
+
+	importPath := filepath.Join(t.TempDir(), "import.json")
+	if err := os.WriteFile(importPath, []byte(`[{"name": "ok"}, {"name": ""}]`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.ImportFile(importPath, "", false); err == nil {
+		t.Error("导入文件中有无效名称时应失败")
+	}
+	if _, err := store.Get("ok"); !errors.Is(err, ErrProfileNotFound) {
+		t.Errorf("导入失败时不应写入任何方案，err = %v", err)
+	}
+}
@@ -0,0 +1,229 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// 默认的安装后验证参数
+const (
+	defaultVerifyTimeout  = 15 * time.Minute
+	defaultVerifyInterval = 10 * time.Second
+	defaultVerifyDial     = 5 * time.Second
+)
+
+// rdpConnectionRequest X.224连接请求，附带请求TLS/CredSSP的RDP协商数据
+var rdpConnectionRequest = []byte{
+	0x03, 0x00, 0x00, 0x13, // TPKT
+	0x0e, 0xe0, 0x00, 0x00, 0x00, 0x00, 0x00, // X.224 CR
+	0x01, 0x00, 0x08, 0x00, 0x03, 0x00, 0x00, 0x00, // RDP_NEG_REQ
+}
+
+// PostInstallResult 安装后可达性验证结果
+type PostInstallResult struct {
+	Host      string    `json:"host"`
+	Port      int       `json:"port"`
+	Protocol  string    `json:"protocol"` // ssh, rdp
+	Success   bool      `json:"success"`
+	Detail    string    `json:"detail"` // SSH banner 或失败原因
+	Attempts  int       `json:"attempts"`
+	StartedAt time.Time `json:"started_at"`
+	ElapsedMs int64     `json:"elapsed_ms"`
+}
+
+// ReachabilityWatcher 轮询新系统的SSH/RDP端口，直到新系统握手成功或超时
+type ReachabilityWatcher struct {
+	Timeout     time.Duration
+	Interval    time.Duration
+	DialTimeout time.Duration
+}
+
+// NewReachabilityWatcher 创建可达性验证器
+func NewReachabilityWatcher() *ReachabilityWatcher {
+	return &ReachabilityWatcher{
+		Timeout:     defaultVerifyTimeout,
+		Interval:    defaultVerifyInterval,
+		DialTimeout: defaultVerifyDial,
+	}
+}
+
+// verifyTarget 根据安装选项决定验证的协议和端口
+func verifyTarget(options InstallOptions) (string, int) {
+	useRDP := options.OSType == "windows" || (options.OSType == "dd" && options.RDPPort > 0)
+	if useRDP {
+		if options.RDPPort > 0 {
+			return "rdp", options.RDPPort
+		}
+		return "rdp", 3389
+	}
+	if options.SSHPort > 0 {
+		return "ssh", options.SSHPort
+	}
+	return "ssh", 22
+}
+
+// Wait 等待重装后的主机上线，ctx取消或超时后返回失败结果
+//
+// 脚本结束时原系统可能还在运行，它的SSH/RDP端口同样能握手成功。因此先记录原系统的标识，
+// 等到端口不可达（原系统已重启）或SSH标识、主机密钥发生变化后，才把握手成功视为新系统上线。
+func (w *ReachabilityWatcher) Wait(ctx context.Context, host string, options InstallOptions) *PostInstallResult {
+	protocol, port := verifyTarget(options)
+	result := &PostInstallResult{
+		Host:      host,
+		Port:      port,
+		Protocol:  protocol,
+		StartedAt: time.Now(),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, w.Timeout)
+	defer cancel()
+
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+	// baseline 为空表示原系统已经下线，之后任何一次握手成功都来自新系统
+	baseline := ""
+	for {
+		result.Attempts++
+		detail, identity, err := w.probe(ctx, address, protocol)
+		switch {
+		case err != nil && probeInterrupted(ctx):
+			// 超时或取消打断的探测不代表原系统已下线
+		case err != nil:
+			baseline = ""
+			result.Detail = err.Error()
+		case result.Attempts == 1:
+			baseline = identity
+			result.Detail = fmt.Sprintf("原系统仍在运行: %s", detail)
+		case baseline == "" || identity != baseline:
+			result.Success = true
+			result.Detail = detail
+			result.ElapsedMs = time.Since(result.StartedAt).Milliseconds()
+			return result
+		}
+
+		timer := time.NewTimer(w.Interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			result.Detail = fmt.Sprintf("等待%s超时: %s", address, result.Detail)
+			result.ElapsedMs = time.Since(result.StartedAt).Milliseconds()
+			return result
+		case <-timer.C:
+		}
+	}
+}
+
+// probeInterrupted 探测是否被ctx的取消或截止时间打断
+// 连接的截止时间与ctx的计时器各自触发，探测返回时ctx可能还没有标记为结束
+func probeInterrupted(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return true
+	}
+	deadline, ok := ctx.Deadline()
+	return ok && !time.Now().Before(deadline)
+}
+
+// probe 连接一次并完成协议握手，返回展示用的详情和用于区分新旧系统的标识
+func (w *ReachabilityWatcher) probe(ctx context.Context, address, protocol string) (string, string, error) {
+	dialer := net.Dialer{Timeout: w.DialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return "", "", err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(w.DialTimeout))
+
+	switch protocol {
+	case "ssh":
+		return probeSSHIdentity(conn, address)
+	case "rdp":
+		detail, err := probeRDPHandshake(conn)
+		// RDP握手不带可比较的标识，只能通过端口下线判断原系统已重启
+		return detail, detail, err
+	default:
+		return "", "", fmt.Errorf("不支持的验证协议: %s", protocol)
+	}
+}
+
+// errHostKeyCaptured 取得主机密钥后中止SSH握手
+var errHostKeyCaptured = errors.New("已取得主机密钥")
+
+// recordingConn 记录从连接读取的数据，用于取出SSH握手中的服务端标识行
+type recordingConn struct {
+	net.Conn
+	read bytes.Buffer
+}
+
+func (c *recordingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if c.read.Len() < 4096 {
+		c.read.Write(p[:n])
+	}
+	return n, err
+}
+
+// probeSSHIdentity 读取SSH服务端标识和主机密钥，在认证前中止握手
+// 标识为 "<标识行> <主机密钥SHA256指纹>"，重装后两者至少有一个会变化
+func probeSSHIdentity(conn net.Conn, address string) (string, string, error) {
+	recorder := &recordingConn{Conn: conn}
+	var hostKey ssh.PublicKey
+	config := &ssh.ClientConfig{
+		User: "reachability-probe",
+		HostKeyCallback: func(_ string, _ net.Addr, key ssh.PublicKey) error {
+			hostKey = key
+			return errHostKeyCaptured
+		},
+	}
+	client, _, _, err := ssh.NewClientConn(recorder, address, config)
+	if client != nil {
+		client.Close()
+	}
+
+	banner := sshBannerLine(recorder.read.Bytes())
+	if banner == "" {
+		return "", "", fmt.Errorf("未收到SSH标识: %v", err)
+	}
+	if hostKey == nil {
+		return "", "", fmt.Errorf("SSH握手失败: %v", err)
+	}
+	return banner, banner + " " + ssh.FingerprintSHA256(hostKey), nil
+}
+
+// sshBannerLine 从服务端输出中取出SSH标识行
+// RFC 4253 允许在标识行之前输出其他文本行
+func sshBannerLine(data []byte) string {
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "SSH-") {
+			return line
+		}
+	}
+	return ""
+}
+
+// probeRDPHandshake 发送X.224连接请求并校验连接确认
+func probeRDPHandshake(conn net.Conn) (string, error) {
+	if _, err := conn.Write(rdpConnectionRequest); err != nil {
+		return "", err
+	}
+
+	header := make([]byte, 6)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return "", fmt.Errorf("未收到RDP响应: %v", err)
+	}
+	if header[0] != 0x03 || header[1] != 0x00 {
+		return "", fmt.Errorf("RDP响应格式错误")
+	}
+	if header[5]&0xf0 != 0xd0 {
+		return "", fmt.Errorf("RDP连接未被确认")
+	}
+	return "RDP X.224 connection confirm", nil
+}
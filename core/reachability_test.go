@@ -0,0 +1,203 @@
+package core
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// fakeSSHHost 模拟重装前后的SSH服务，可以切换版本标识、主机密钥或模拟下线
+type fakeSSHHost struct {
+	listener net.Listener
+
+	mu      sync.Mutex
+	version string
+	signer  ssh.Signer
+	down    bool
+	probes  int
+}
+
+func newFakeSSHHost(t *testing.T, version string) *fakeSSHHost {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := &fakeSSHHost{listener: listener, version: version, signer: newTestHostKey(t)}
+	t.Cleanup(func() { listener.Close() })
+	go host.serve()
+	return host
+}
+
+func newTestHostKey(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func (h *fakeSSHHost) serve() {
+	for {
+		conn, err := h.listener.Accept()
+		if err != nil {
+			return
+		}
+		h.mu.Lock()
+		h.probes++
+		down, version, signer := h.down, h.version, h.signer
+		h.mu.Unlock()
+		if down {
+			// 重启中：端口接受连接但不返回SSH标识
+			conn.Close()
+			continue
+		}
+		go func() {
+			defer conn.Close()
+			config := &ssh.ServerConfig{NoClientAuth: true, ServerVersion: version}
+			config.AddHostKey(signer)
+			ssh.NewServerConn(conn, config)
+		}()
+	}
+}
+
+// update 修改服务状态
+func (h *fakeSSHHost) update(change func(h *fakeSSHHost)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	change(h)
+}
+
+// waitProbes 等待至少 n 次连接
+func (h *fakeSSHHost) waitProbes(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		h.mu.Lock()
+		probes := h.probes
+		h.mu.Unlock()
+		if probes >= n {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("等待 %d 次探测超时", n)
+}
+
+func (h *fakeSSHHost) options(t *testing.T) (string, InstallOptions) {
+	t.Helper()
+	host, port, err := net.SplitHostPort(h.listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	portNumber, _ := strconv.Atoi(port)
+	return host, InstallOptions{OSType: "linux", SSHPort: portNumber}
+}
+
+func testWatcher(timeout time.Duration) *ReachabilityWatcher {
+	return &ReachabilityWatcher{Timeout: timeout, Interval: 10 * time.Millisecond, DialTimeout: time.Second}
+}
+
+func TestReachabilityIgnoresOldSystem(t *testing.T) {
+	server := newFakeSSHHost(t, "SSH-2.0-OpenSSH_8.9p1")
+	host, options := server.options(t)
+
+	result := testWatcher(300*time.Millisecond).Wait(context.Background(), host, options)
+	if result.Success {
+		t.Fatalf("原系统一直在运行时不应判定为上线: %+v", result)
+	}
+	if result.Attempts < 2 || !strings.Contains(result.Detail, "OpenSSH_8.9p1") {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestReachabilityWaitsForNewSystem(t *testing.T) {
+	tests := []struct {
+		name   string
+		reboot func(t *testing.T, server *fakeSSHHost)
+		banner string
+	}{
+		{"下线后以相同标识上线", func(t *testing.T, server *fakeSSHHost) {
+			server.update(func(h *fakeSSHHost) { h.down = true })
+			server.waitProbes(t, 4)
+			server.update(func(h *fakeSSHHost) { h.down = false })
+		}, "SSH-2.0-OpenSSH_8.9p1"},
+		{"主机密钥变化", func(t *testing.T, server *fakeSSHHost) {
+			signer := newTestHostKey(t)
+			server.update(func(h *fakeSSHHost) { h.signer = signer })
+		}, "SSH-2.0-OpenSSH_8.9p1"},
+		{"标识变化", func(t *testing.T, server *fakeSSHHost) {
+			server.update(func(h *fakeSSHHost) { h.version = "SSH-2.0-OpenSSH_9.6p1" })
+		}, "SSH-2.0-OpenSSH_9.6p1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeSSHHost(t, "SSH-2.0-OpenSSH_8.9p1")
+			host, options := server.options(t)
+
+			done := make(chan *PostInstallResult, 1)
+			go func() {
+				done <- testWatcher(10*time.Second).Wait(context.Background(), host, options)
+			}()
+			server.waitProbes(t, 2)
+			tt.reboot(t, server)
+
+			result := <-done
+			if !result.Success || result.Detail != tt.banner {
+				t.Fatalf("result = %+v, want success with %s", result, tt.banner)
+			}
+		})
+	}
+}
+
+func TestReachabilityAcceptsHostThatWasAlreadyDown(t *testing.T) {
+	server := newFakeSSHHost(t, "SSH-2.0-OpenSSH_9.6p1")
+	server.update(func(h *fakeSSHHost) { h.down = true })
+	host, options := server.options(t)
+
+	done := make(chan *PostInstallResult, 1)
+	go func() {
+		done <- testWatcher(10*time.Second).Wait(context.Background(), host, options)
+	}()
+	server.waitProbes(t, 2)
+	server.update(func(h *fakeSSHHost) { h.down = false })
+
+	if result := <-done; !result.Success || result.Detail != "SSH-2.0-OpenSSH_9.6p1" {
+		t.Fatalf("result = %+v", result)
+	}
+}
+
+func TestReachabilityStopsOnCancel(t *testing.T) {
+	server := newFakeSSHHost(t, "SSH-2.0-OpenSSH_8.9p1")
+	host, options := server.options(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan *PostInstallResult, 1)
+	go func() {
+		done <- testWatcher(time.Minute).Wait(ctx, host, options)
+	}()
+	server.waitProbes(t, 2)
+	cancel()
+
+	select {
+	case result := <-done:
+		if result.Success {
+			t.Fatalf("result = %+v", result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("取消后 Wait 没有返回")
+	}
+}
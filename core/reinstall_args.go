@@ -0,0 +1,154 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// optionFlag InstallOptions字段到reinstall脚本参数的映射
+//
+// 参数形式由字段类型决定：
+//   - string/int: --flag value
+//   - bool:       --flag
+//   - []string:   每个元素重复一次 --flag value
+//
+// Flag为空表示该字段不由这里生成参数（如由 scriptSecrets 单独传递的密码和SSH密钥），
+// 但仍然按Targets校验安装类型是否支持。
+type optionFlag struct {
+	Field   string   // InstallOptions字段名
+	Flag    string   // 脚本参数
+	Targets []string // 支持该字段的安装类型
+	Port    bool     // 是否为端口号
+}
+
+// reinstallFlags 每个安装类型支持的选项
+var reinstallFlags = []optionFlag{
+	{Field: "ImageName", Flag: "--image-name", Targets: []string{"windows"}},
+	{Field: "ISOURL", Flag: "--iso", Targets: []string{"windows"}},
+	{Field: "ImageURL", Flag: "--img", Targets: []string{"dd"}},
+	{Field: "Language", Flag: "--lang", Targets: []string{"windows"}},
+	{Field: "Username", Flag: "--username", Targets: []string{"linux", "windows"}},
+	{Field: "SSHPort", Flag: "--ssh-port", Targets: []string{"linux", "windows", "dd"}, Port: true},
+	{Field: "WebPort", Flag: "--web-port", Targets: []string{"linux", "windows", "dd"}, Port: true},
+	{Field: "RDPPort", Flag: "--rdp-port", Targets: []string{"windows", "dd"}, Port: true},
+	{Field: "Minimal", Flag: "--minimal", Targets: []string{"linux"}},
+	{Field: "AllowPing", Flag: "--allow-ping", Targets: []string{"windows", "dd"}},
+	{Field: "Drivers", Flag: "--add-driver", Targets: []string{"windows"}},
+	{Field: "DriverBackup", Targets: []string{"windows"}},
+	{Field: "ImageSHA256", Targets: []string{"windows", "dd"}},
+	{Field: "Password", Targets: []string{"linux", "windows", "dd"}},
+	{Field: "SSHKey", Targets: []string{"linux", "dd"}},
+}
+
+// allowedExtraOptions 每个安装类型允许透传的额外选项
+var allowedExtraOptions = map[string][]string{
+	"linux":   {"ci", "hold", "frpc-toml"},
+	"windows": {"hold", "frpc-toml"},
+	"dd":      {"hold", "frpc-toml"},
+}
+
+// buildReinstallArgs 根据安装选项生成reinstall脚本参数，目标不支持的选项会返回错误
+func buildReinstallArgs(options InstallOptions) ([]string, error) {
+	var args []string
+
+	// 位置参数
+	switch options.OSType {
+	case "linux":
+		if options.System == "" {
+			return nil, fmt.Errorf("必须指定Linux系统名称")
+		}
+		args = append(args, options.System)
+		if options.Version != "" {
+			args = append(args, options.Version)
+		}
+	case "windows", "dd":
+		if options.System != "" || options.Version != "" {
+			return nil, fmt.Errorf("%s安装不支持System/Version选项", options.OSType)
+		}
+		args = append(args, options.OSType)
+	default:
+		return nil, fmt.Errorf("不支持的安装类型: %s", options.OSType)
+	}
+
+	value := reflect.ValueOf(options)
+	for _, spec := range reinstallFlags {
+		field := value.FieldByName(spec.Field)
+		if !field.IsValid() {
+			return nil, fmt.Errorf("未知的安装选项字段: %s", spec.Field)
+		}
+		if field.IsZero() {
+			continue
+		}
+		if !containsString(spec.Targets, options.OSType) {
+			return nil, fmt.Errorf("%s安装不支持%s选项", options.OSType, spec.Field)
+		}
+		if spec.Flag == "" {
+			continue
+		}
+
+		switch field.Kind() {
+		case reflect.String:
+			args = append(args, spec.Flag, field.String())
+		case reflect.Int:
+			n := int(field.Int())
+			if spec.Port && (n < 1 || n > 65535) {
+				return nil, fmt.Errorf("%s端口无效: %d", spec.Field, n)
+			}
+			args = append(args, spec.Flag, strconv.Itoa(n))
+		case reflect.Bool:
+			args = append(args, spec.Flag)
+		case reflect.Slice:
+			for i := 0; i < field.Len(); i++ {
+				item := field.Index(i).String()
+				if item == "" {
+					continue
+				}
+				args = append(args, spec.Flag, item)
+			}
+		default:
+			return nil, fmt.Errorf("不支持的安装选项类型: %s", spec.Field)
+		}
+	}
+
+	extra, err := buildExtraArgs(options)
+	if err != nil {
+		return nil, err
+	}
+	return append(args, extra...), nil
+}
+
+// buildExtraArgs 按允许列表透传ExtraOptions，值为空时只传参数名
+func buildExtraArgs(options InstallOptions) ([]string, error) {
+	keys := make([]string, 0, len(options.ExtraOptions))
+	for key := range options.ExtraOptions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var args []string
+	allowed := allowedExtraOptions[options.OSType]
+	for _, key := range keys {
+		name := strings.TrimPrefix(key, "--")
+		if !containsString(allowed, name) {
+			return nil, fmt.Errorf("%s安装不允许额外选项: %s", options.OSType, key)
+		}
+		args = append(args, "--"+name)
+		if value := options.ExtraOptions[key]; value != "" {
+			args = append(args, value)
+		}
+	}
+	return args, nil
+}
+
+// containsString 判断切片中是否包含指定字符串
+func containsString(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
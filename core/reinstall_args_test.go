@@ -0,0 +1,146 @@
+package core
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// baseInstallOptions 每个安装类型的最小选项和对应的位置参数
+func baseInstallOptions(osType string) (InstallOptions, []string) {
+	if osType == "linux" {
+		return InstallOptions{OSType: "linux", System: "debian", Version: "12"}, []string{"debian", "12"}
+	}
+	return InstallOptions{OSType: osType}, []string{osType}
+}
+
+func TestBuildReinstallArgsFlags(t *testing.T) {
+	// 每个字段的示例值、期望参数和支持的安装类型，与 reinstallFlags 独立维护
+	fields := []struct {
+		field   string
+		set     func(*InstallOptions)
+		args    []string
+		targets []string
+	}{
+		{"ImageName", func(o *InstallOptions) { o.ImageName = "Windows 11 Pro" }, []string{"--image-name", "Windows 11 Pro"}, []string{"windows"}},
+		{"ISOURL", func(o *InstallOptions) { o.ISOURL = "https://example.com/win.iso" }, []string{"--iso", "https://example.com/win.iso"}, []string{"windows"}},
+		{"ImageURL", func(o *InstallOptions) { o.ImageURL = "https://example.com/disk.raw.gz" }, []string{"--img", "https://example.com/disk.raw.gz"}, []string{"dd"}},
+		{"Language", func(o *InstallOptions) { o.Language = "zh-cn" }, []string{"--lang", "zh-cn"}, []string{"windows"}},
+		{"Username", func(o *InstallOptions) { o.Username = "admin" }, []string{"--username", "admin"}, []string{"linux", "windows"}},
+		{"SSHPort", func(o *InstallOptions) { o.SSHPort = 2222 }, []string{"--ssh-port", "2222"}, []string{"linux", "windows", "dd"}},
+		{"WebPort", func(o *InstallOptions) { o.WebPort = 8080 }, []string{"--web-port", "8080"}, []string{"linux", "windows", "dd"}},
+		{"RDPPort", func(o *InstallOptions) { o.RDPPort = 3390 }, []string{"--rdp-port", "3390"}, []string{"windows", "dd"}},
+		{"Minimal", func(o *InstallOptions) { o.Minimal = true }, []string{"--minimal"}, []string{"linux"}},
+		{"AllowPing", func(o *InstallOptions) { o.AllowPing = true }, []string{"--allow-ping"}, []string{"windows", "dd"}},
+		{"Drivers", func(o *InstallOptions) { o.Drivers = []string{"C:/drivers/a", "", "C:/drivers/b"} }, []string{"--add-driver", "C:/drivers/a", "--add-driver", "C:/drivers/b"}, []string{"windows"}},
+		{"DriverBackup", func(o *InstallOptions) { o.DriverBackup = "drivers.zip" }, nil, []string{"windows"}},
+		{"ImageSHA256", func(o *InstallOptions) { o.ImageSHA256 = strings.Repeat("ab", 32) }, nil, []string{"windows", "dd"}},
+		{"Password", func(o *InstallOptions) { o.Password = "secret" }, nil, []string{"linux", "windows", "dd"}},
+		{"SSHKey", func(o *InstallOptions) { o.SSHKey = "ssh-ed25519 AAAA" }, nil, []string{"linux", "dd"}},
+	}
+
+	covered := map[string]bool{}
+	for _, f := range fields {
+		covered[f.field] = true
+	}
+	for _, spec := range reinstallFlags {
+		if !covered[spec.Field] {
+			t.Errorf("reinstallFlags 中的 %s 没有测试用例", spec.Field)
+		}
+	}
+
+	for _, osType := range []string{"linux", "windows", "dd"} {
+		for _, f := range fields {
+			t.Run(osType+"/"+f.field, func(t *testing.T) {
+				options, want := baseInstallOptions(osType)
+				f.set(&options)
+				got, err := buildReinstallArgs(options)
+				if !containsString(f.targets, osType) {
+					if err == nil {
+						t.Fatalf("buildReinstallArgs = %q, want error", got)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("buildReinstallArgs: %v", err)
+				}
+				want = append(want, f.args...)
+				if !reflect.DeepEqual(got, want) {
+					t.Fatalf("buildReinstallArgs = %q, want %q", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestBuildReinstallArgsExtraOptions(t *testing.T) {
+	keys := []struct {
+		key     string
+		value   string
+		args    []string
+		targets []string
+	}{
+		{"ci", "", []string{"--ci"}, []string{"linux"}},
+		{"hold", "2", []string{"--hold", "2"}, []string{"linux", "windows", "dd"}},
+		{"--hold", "1", []string{"--hold", "1"}, []string{"linux", "windows", "dd"}},
+		{"frpc-toml", "/etc/frpc.toml", []string{"--frpc-toml", "/etc/frpc.toml"}, []string{"linux", "windows", "dd"}},
+		{"password", "x", nil, nil},
+		{"debug", "", nil, nil},
+	}
+
+	for _, osType := range []string{"linux", "windows", "dd"} {
+		for _, k := range keys {
+			t.Run(osType+"/"+k.key, func(t *testing.T) {
+				options, want := baseInstallOptions(osType)
+				options.ExtraOptions = map[string]string{k.key: k.value}
+				got, err := buildReinstallArgs(options)
+				if !containsString(k.targets, osType) {
+					if err == nil {
+						t.Fatalf("buildReinstallArgs = %q, want error", got)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("buildReinstallArgs: %v", err)
+				}
+				want = append(want, k.args...)
+				if !reflect.DeepEqual(got, want) {
+					t.Fatalf("buildReinstallArgs = %q, want %q", got, want)
+				}
+			})
+		}
+	}
+
+	// 多个额外选项按键名排序输出
+	options, want := baseInstallOptions("linux")
+	options.ExtraOptions = map[string]string{"hold": "2", "ci": ""}
+	got, err := buildReinstallArgs(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = append(want, "--ci", "--hold", "2")
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("buildReinstallArgs = %q, want %q", got, want)
+	}
+}
+
+func TestBuildReinstallArgsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		options InstallOptions
+	}{
+		{"未知类型", InstallOptions{OSType: "macos"}},
+		{"Linux缺少系统", InstallOptions{OSType: "linux"}},
+		{"Windows带System", InstallOptions{OSType: "windows", System: "debian"}},
+		{"dd带Version", InstallOptions{OSType: "dd", Version: "12"}},
+		{"端口过大", InstallOptions{OSType: "dd", SSHPort: 70000}},
+		{"端口为负", InstallOptions{OSType: "windows", RDPPort: -1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := buildReinstallArgs(tt.options); err == nil {
+				t.Fatalf("buildReinstallArgs = %q, want error", got)
+			}
+		})
+	}
+}
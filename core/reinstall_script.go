@@ -0,0 +1,279 @@
+package core
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// embeddedReinstall 编译时打包的reinstall脚本
+//
+//go:embed reinstall
+var embeddedReinstall embed.FS
+
+// embeddedReinstallRoot 打包脚本在embed.FS中的根目录
+const embeddedReinstallRoot = "reinstall"
+
+// scriptStateFile 解压目录中记录版本和哈希的文件
+const scriptStateFile = ".version.json"
+
+// scriptChecksumFile 内置脚本包中固定上游脚本哈希的文件，格式与 sha256sum 输出相同
+const scriptChecksumFile = "SHA256SUMS"
+
+// scriptSourceEmbedded 脚本来源：程序内置
+const scriptSourceEmbedded = "embedded"
+
+// ErrReinstallScriptUnavailable 没有可用的reinstall脚本
+var ErrReinstallScriptUnavailable = errors.New("没有可用的reinstall脚本")
+
+// ReinstallScriptInfo reinstall脚本信息
+type ReinstallScriptInfo struct {
+	Version string            `json:"version"`
+	Source  string            `json:"source"` // embedded
+	Path    string            `json:"path"`   // 当前平台使用的脚本路径
+	SHA256  map[string]string `json:"sha256"` // 文件名 -> SHA-256
+}
+
+// scriptBundle 一组reinstall脚本文件
+type scriptBundle struct {
+	Version string
+	Source  string
+	Files   map[string][]byte
+}
+
+// reinstallScriptName 当前平台使用的脚本文件名
+func reinstallScriptName() string {
+	if runtime.GOOS == "windows" {
+		return "reinstall.bat"
+	}
+	return "reinstall.sh"
+}
+
+// loadEmbeddedScriptBundle 读取内置脚本包
+func loadEmbeddedScriptBundle() (*scriptBundle, error) {
+	version, err := embeddedReinstall.ReadFile(path.Join(embeddedReinstallRoot, "VERSION"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: 内置脚本缺少版本信息", ErrReinstallScriptUnavailable)
+	}
+
+	bundle := &scriptBundle{
+		Version: strings.TrimSpace(string(version)),
+		Source:  scriptSourceEmbedded,
+		Files:   make(map[string][]byte),
+	}
+	if _, err := parseScriptVersion(bundle.Version); err != nil {
+		return nil, fmt.Errorf("%w: 内置%v", ErrReinstallScriptUnavailable, err)
+	}
+
+	entries, err := embeddedReinstall.ReadDir(embeddedReinstallRoot)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrReinstallScriptUnavailable, err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || name == "VERSION" || name == "README.md" || name == scriptChecksumFile {
+			continue
+		}
+		data, err := embeddedReinstall.ReadFile(path.Join(embeddedReinstallRoot, name))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrReinstallScriptUnavailable, err)
+		}
+		bundle.Files[name] = data
+	}
+
+	sums, err := embeddedReinstall.ReadFile(path.Join(embeddedReinstallRoot, scriptChecksumFile))
+	if err != nil {
+		return nil, fmt.Errorf("%w: 内置脚本缺少%s", ErrReinstallScriptUnavailable, scriptChecksumFile)
+	}
+	if err := bundle.verifyChecksums(sums); err != nil {
+		return nil, err
+	}
+	if err := bundle.validate(); err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+// parseChecksums 解析 sha256sum 格式的校验文件，忽略空行和#注释
+func parseChecksums(data []byte) (map[string]string, error) {
+	sums := make(map[string]string)
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 || len(fields[0]) != sha256.Size*2 {
+			return nil, fmt.Errorf("第%d行格式无效", i+1)
+		}
+		if _, err := hex.DecodeString(fields[0]); err != nil {
+			return nil, fmt.Errorf("第%d行哈希无效", i+1)
+		}
+		sums[strings.TrimPrefix(fields[1], "*")] = strings.ToLower(fields[0])
+	}
+	return sums, nil
+}
+
+// verifyChecksums 校验脚本包与固定的哈希完全一致：每个文件都必须登记且哈希匹配，登记的文件不能缺失
+func (b *scriptBundle) verifyChecksums(data []byte) error {
+	sums, err := parseChecksums(data)
+	if err != nil {
+		return fmt.Errorf("%w: %s %v", ErrReinstallScriptUnavailable, scriptChecksumFile, err)
+	}
+	for name, actual := range b.hashes() {
+		expected, ok := sums[name]
+		if !ok {
+			return fmt.Errorf("%w: %s未登记在%s中", ErrReinstallScriptUnavailable, name, scriptChecksumFile)
+		}
+		if actual != expected {
+			return fmt.Errorf("%w: %s的SHA-256与%s不一致", ErrReinstallScriptUnavailable, name, scriptChecksumFile)
+		}
+	}
+	for name := range sums {
+		if _, ok := b.Files[name]; !ok {
+			return fmt.Errorf("%w: %s中登记的%s缺失", ErrReinstallScriptUnavailable, scriptChecksumFile, name)
+		}
+	}
+	return nil
+}
+
+// validate 检查脚本包是否包含当前平台所需的脚本
+func (b *scriptBundle) validate() error {
+	name := reinstallScriptName()
+	if len(b.Files[name]) == 0 {
+		return fmt.Errorf("%w: %s脚本包(%s)中缺少%s", ErrReinstallScriptUnavailable, b.Source, b.Version, name)
+	}
+	return nil
+}
+
+// hashes 计算脚本包中每个文件的SHA-256
+func (b *scriptBundle) hashes() map[string]string {
+	hashes := make(map[string]string, len(b.Files))
+	for name, data := range b.Files {
+		hashes[name] = sha256Hex(data)
+	}
+	return hashes
+}
+
+// sha256Hex 计算数据的SHA-256十六进制字符串
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// fileSHA256 计算文件的SHA-256
+func fileSHA256(filePath string) (string, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", err
+	}
+	return sha256Hex(data), nil
+}
+
+// extractScriptBundle 将脚本包解压到目录，仅重写缺失或内容不一致的文件
+// 目录中不属于脚本包的文件（例如回滚前更新版本独有的文件）会被删除，使目录与脚本包完全一致
+func extractScriptBundle(bundle *scriptBundle, dir string) (*ReinstallScriptInfo, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建脚本目录失败: %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取脚本目录失败: %v", err)
+	}
+	for _, entry := range entries {
+		if _, ok := bundle.Files[entry.Name()]; ok && entry.Type().IsRegular() {
+			continue
+		}
+		if entry.Name() == scriptStateFile {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+			return nil, fmt.Errorf("清理脚本目录失败: %v", err)
+		}
+	}
+
+	hashes := bundle.hashes()
+	names := make([]string, 0, len(bundle.Files))
+	for name := range bundle.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		target := filepath.Join(dir, name)
+		if current, err := fileSHA256(target); err == nil && current == hashes[name] {
+			continue
+		}
+		if err := writeFileAtomic(target, bundle.Files[name], 0755); err != nil {
+			return nil, fmt.Errorf("解压脚本%s失败: %v", name, err)
+		}
+	}
+
+	info := &ReinstallScriptInfo{
+		Version: bundle.Version,
+		Source:  bundle.Source,
+		Path:    filepath.Join(dir, reinstallScriptName()),
+		SHA256:  hashes,
+	}
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(filepath.Join(dir, scriptStateFile), data, 0644); err != nil {
+		return nil, fmt.Errorf("写入脚本版本信息失败: %v", err)
+	}
+	return info, nil
+}
+
+// verifyScriptFiles 校验磁盘上的脚本与记录的哈希是否一致
+func verifyScriptFiles(dir string, hashes map[string]string) error {
+	for name, expected := range hashes {
+		actual, err := fileSHA256(filepath.Join(dir, name))
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("脚本文件缺失: %s", name)
+		}
+		if err != nil {
+			return err
+		}
+		if actual != expected {
+			return fmt.Errorf("脚本文件已被修改: %s", name)
+		}
+	}
+	return nil
+}
+
+// writeFileAtomic 先写临时文件再重命名，避免留下写了一半的文件
+func writeFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpName, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmpName, filename)
+}
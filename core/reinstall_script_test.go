@@ -0,0 +1,55 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestScriptBundleVerifyChecksums(t *testing.T) {
+	script := []byte("#!/bin/sh\necho reinstall\n")
+	sum := sha256Hex(script)
+
+	tests := []struct {
+		name  string
+		files map[string][]byte
+		sums  string
+		ok    bool
+	}{
+		{"匹配", map[string][]byte{"reinstall.sh": script}, fmt.Sprintf("# comment\n%s  reinstall.sh\n", sum), true},
+		{"二进制模式", map[string][]byte{"reinstall.sh": script}, fmt.Sprintf("%s *reinstall.sh\n", sum), true},
+		{"哈希不一致", map[string][]byte{"reinstall.sh": []byte("changed")}, fmt.Sprintf("%s  reinstall.sh\n", sum), false},
+		{"未登记", map[string][]byte{"reinstall.sh": script, "extra.sh": script}, fmt.Sprintf("%s  reinstall.sh\n", sum), false},
+		{"登记的文件缺失", map[string][]byte{"reinstall.sh": script}, fmt.Sprintf("%s  reinstall.sh\n%s  reinstall.bat\n", sum, sum), false},
+		{"格式无效", map[string][]byte{"reinstall.sh": script}, "abc reinstall.sh\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bundle := &scriptBundle{Version: "test", Source: scriptSourceEmbedded, Files: tt.files}
+			err := bundle.verifyChecksums([]byte(tt.sums))
+			if tt.ok && err != nil {
+				t.Fatalf("verifyChecksums: %v", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrReinstallScriptUnavailable) {
+				t.Fatalf("verifyChecksums = %v, want ErrReinstallScriptUnavailable", err)
+			}
+		})
+	}
+}
+
+// TestEmbeddedScriptBundle 内置脚本包必须能通过启动时的校验，空包或未更新的包在CI中即失败
+func TestEmbeddedScriptBundle(t *testing.T) {
+	bundle, err := loadEmbeddedScriptBundle()
+	if err != nil {
+		t.Fatalf("内置脚本包不可用（运行 go run ./tools/vendorscripts 打包上游脚本）: %v", err)
+	}
+	for _, name := range []string{"reinstall.sh", "reinstall.bat"} {
+		if len(bundle.Files[name]) == 0 {
+			t.Errorf("内置脚本包缺少%s", name)
+		}
+	}
+	if !strings.Contains(string(bundle.Files["reinstall.sh"]), scriptPasswordFileEnv) {
+		t.Errorf("reinstall.sh 没有插入读取 %s 的补丁", scriptPasswordFileEnv)
+	}
+}
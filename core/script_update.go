@@ -0,0 +1,421 @@
+package core
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// scriptUpdatePublicKeys 信任的脚本发布Ed25519公钥(base64)
+//
+// 密钥由发布维护者使用 `go run ./tools/scriptsign keygen` 生成，私钥离线保存，只把公钥登记在这里。
+// 轮换密钥时先把新公钥追加到列表并发布一个程序版本，之后用新私钥签名，
+// 等旧版本程序不再使用后再删除旧公钥。列表为空时在线更新不可用。
+// testdata/script_release 中的发布信息由当前发布私钥签名，用于测试登记的公钥。
+var scriptUpdatePublicKeys = []string{
+	"Nyxq5hTIIx8YaV2DLVJ5v4hFIHlTTjioB5Vf0IOuvOc=", // 2025 发布密钥
+}
+
+// scriptSourceUpdate 脚本来源：在线更新
+const scriptSourceUpdate = "update"
+
+// scriptUpdateDirName 在线更新脚本的暂存目录名（与reinstall目录同级）
+const scriptUpdateDirName = "reinstall-updates"
+
+// scriptActiveFile 记录当前启用的更新版本
+const scriptActiveFile = "active.json"
+
+// scriptHighestFile 记录启用过的最高版本，回滚后也不能再启用不高于它的版本，防止重放旧的签名清单
+const scriptHighestFile = "highest.json"
+
+// maxScriptFileSize 单个脚本文件大小上限
+const maxScriptFileSize = 16 << 20
+
+// scriptNamePattern 版本号和文件名只允许的字符，防止路径穿越
+var scriptNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// scriptManifest 脚本发布清单
+type scriptManifest struct {
+	Version string            `json:"version"`
+	Files   map[string]string `json:"files"` // 文件名 -> SHA-256
+}
+
+// ScriptUpdateInfo 脚本更新检查结果
+type ScriptUpdateInfo struct {
+	CurrentVersion string `json:"current_version"`
+	CurrentSource  string `json:"current_source"`
+	LatestVersion  string `json:"latest_version"`
+	Available      bool   `json:"available"`
+}
+
+// scriptActiveState 当前启用的更新版本
+type scriptActiveState struct {
+	Version string `json:"version"`
+}
+
+// verifyScriptRelease 校验发布信息的签名并解析清单
+func verifyScriptRelease(release *ScriptRelease) (*scriptManifest, []byte, []byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(release.Manifest)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("脚本清单格式错误: %v", err)
+	}
+	signature, err := base64.StdEncoding.DecodeString(release.Signature)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("脚本签名格式错误: %v", err)
+	}
+
+	manifest, err := parseSignedScriptManifest(raw, signature)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return manifest, raw, signature, nil
+}
+
+// parseSignedScriptManifest 使用信任的公钥校验清单签名后解析
+func parseSignedScriptManifest(raw, signature []byte) (*scriptManifest, error) {
+	if len(scriptUpdatePublicKeys) == 0 {
+		return nil, fmt.Errorf("未配置脚本更新公钥，无法校验脚本更新")
+	}
+	verified := false
+	for _, encoded := range scriptUpdatePublicKeys {
+		publicKey, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(publicKey) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("脚本更新公钥无效")
+		}
+		if ed25519.Verify(ed25519.PublicKey(publicKey), raw, signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, fmt.Errorf("脚本清单签名校验失败")
+	}
+
+	var manifest scriptManifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return nil, fmt.Errorf("脚本清单解析失败: %v", err)
+	}
+	if !scriptNamePattern.MatchString(manifest.Version) {
+		return nil, fmt.Errorf("脚本版本号无效: %q", manifest.Version)
+	}
+	if _, err := parseScriptVersion(manifest.Version); err != nil {
+		return nil, err
+	}
+	if len(manifest.Files) == 0 {
+		return nil, fmt.Errorf("脚本清单为空")
+	}
+	for name := range manifest.Files {
+		if !scriptNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("脚本文件名无效: %q", name)
+		}
+	}
+	return &manifest, nil
+}
+
+// SignScriptRelease 用发布私钥为一组脚本生成签名的发布信息，供发布工具使用
+func SignScriptRelease(privateKey ed25519.PrivateKey, version, baseURL string, files map[string][]byte) (*ScriptRelease, error) {
+	if len(privateKey) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("发布私钥无效")
+	}
+	if !scriptNamePattern.MatchString(version) {
+		return nil, fmt.Errorf("脚本版本号无效: %q", version)
+	}
+	if _, err := parseScriptVersion(version); err != nil {
+		return nil, err
+	}
+	manifest := scriptManifest{Version: version, Files: make(map[string]string, len(files))}
+	for name, data := range files {
+		if !scriptNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("脚本文件名无效: %q", name)
+		}
+		manifest.Files[name] = sha256Hex(data)
+	}
+	raw, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	return &ScriptRelease{
+		Manifest:  base64.StdEncoding.EncodeToString(raw),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, raw)),
+		BaseURL:   baseURL,
+	}, nil
+}
+
+// parseScriptVersion 解析点分数字版本号，如 2025.06.01
+func parseScriptVersion(version string) ([]int, error) {
+	parts := strings.Split(version, ".")
+	numbers := make([]int, len(parts))
+	for i, part := range parts {
+		if part == "" || len(part) > 9 || strings.Trim(part, "0123456789") != "" {
+			return nil, fmt.Errorf("脚本版本号不是点分数字格式: %q", version)
+		}
+		numbers[i], _ = strconv.Atoi(part)
+	}
+	return numbers, nil
+}
+
+// compareScriptVersions 比较两个版本号，a<b 返回-1，相等返回0，a>b 返回1
+func compareScriptVersions(a, b string) (int, error) {
+	va, err := parseScriptVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := parseScriptVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < len(va) || i < len(vb); i++ {
+		var x, y int
+		if i < len(va) {
+			x = va[i]
+		}
+		if i < len(vb) {
+			y = vb[i]
+		}
+		if x != y {
+			if x < y {
+				return -1, nil
+			}
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// minimumScriptVersion 更新必须超过的版本：当前脚本版本和启用过的最高版本中较大的一个
+func (si *SystemInstaller) minimumScriptVersion() (string, error) {
+	current := si.GetReinstallScriptInfo()
+	if current == nil {
+		return "", ErrReinstallScriptUnavailable
+	}
+	minimum := current.Version
+	if _, err := parseScriptVersion(minimum); err != nil {
+		return "", fmt.Errorf("当前脚本版本无法比较: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(si.scriptUpdateDir(), scriptHighestFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return minimum, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	var highest scriptActiveState
+	if err := json.Unmarshal(data, &highest); err != nil {
+		return "", fmt.Errorf("脚本最高版本记录解析失败: %v", err)
+	}
+	cmp, err := compareScriptVersions(highest.Version, minimum)
+	if err != nil {
+		return "", err
+	}
+	if cmp > 0 {
+		minimum = highest.Version
+	}
+	return minimum, nil
+}
+
+// checkScriptVersion 拒绝不高于当前版本或已启用过版本的更新
+func (si *SystemInstaller) checkScriptVersion(version string) (bool, error) {
+	minimum, err := si.minimumScriptVersion()
+	if err != nil {
+		return false, err
+	}
+	cmp, err := compareScriptVersions(version, minimum)
+	if err != nil {
+		return false, err
+	}
+	return cmp > 0, nil
+}
+
+// scriptUpdateDir 在线更新脚本的暂存目录
+func (si *SystemInstaller) scriptUpdateDir() string {
+	return filepath.Join(si.workingDir, scriptUpdateDirName)
+}
+
+// scriptUpdateEndpoint 配置的脚本更新地址，为空时使用API默认地址
+func (si *SystemInstaller) scriptUpdateEndpoint() string {
+	return si.Config().API.ScriptUpdateURL
+}
+
+// CheckScriptUpdate 检查是否有新的reinstall脚本
+func (si *SystemInstaller) CheckScriptUpdate(ctx context.Context) (*ScriptUpdateInfo, error) {
+	release, err := si.apiClient.GetScriptRelease(ctx, si.scriptUpdateEndpoint())
+	if err != nil {
+		return nil, fmt.Errorf("检查脚本更新失败: %v", err)
+	}
+	manifest, _, _, err := verifyScriptRelease(release)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &ScriptUpdateInfo{LatestVersion: manifest.Version}
+	if current := si.GetReinstallScriptInfo(); current != nil {
+		info.CurrentVersion = current.Version
+		info.CurrentSource = current.Source
+	}
+	// 只有高于当前版本和已启用过版本的发布才算更新，避免重放旧清单造成降级
+	info.Available, err = si.checkScriptVersion(manifest.Version)
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// ApplyScriptUpdate 下载并校验最新脚本，暂存后启用
+func (si *SystemInstaller) ApplyScriptUpdate(ctx context.Context) (*ReinstallScriptInfo, error) {
+	if si.isInstallRunning() {
+		return nil, fmt.Errorf("安装进行中，无法更新脚本")
+	}
+
+	release, err := si.apiClient.GetScriptRelease(ctx, si.scriptUpdateEndpoint())
+	if err != nil {
+		return nil, fmt.Errorf("获取脚本更新失败: %v", err)
+	}
+	manifest, raw, signature, err := verifyScriptRelease(release)
+	if err != nil {
+		return nil, err
+	}
+	newer, err := si.checkScriptVersion(manifest.Version)
+	if err != nil {
+		return nil, err
+	}
+	if !newer {
+		return nil, fmt.Errorf("脚本版本%s不高于当前或已启用过的版本，拒绝降级", manifest.Version)
+	}
+
+	bundle := &scriptBundle{
+		Version: manifest.Version,
+		Source:  scriptSourceUpdate,
+		Files:   make(map[string][]byte, len(manifest.Files)),
+	}
+	baseURL := strings.TrimSuffix(release.BaseURL, "/")
+	for name, expected := range manifest.Files {
+		data, err := si.apiClient.DownloadScriptFile(ctx, baseURL+"/"+name, maxScriptFileSize)
+		if err != nil {
+			return nil, fmt.Errorf("下载脚本%s失败: %v", name, err)
+		}
+		if actual := sha256Hex(data); !strings.EqualFold(actual, expected) {
+			return nil, fmt.Errorf("脚本%s的SHA-256不匹配", name)
+		}
+		bundle.Files[name] = data
+	}
+	if err := bundle.validate(); err != nil {
+		return nil, err
+	}
+
+	// 暂存到 reinstall-updates/<version>/
+	stageDir := filepath.Join(si.scriptUpdateDir(), manifest.Version)
+	if err := os.MkdirAll(stageDir, 0755); err != nil {
+		return nil, fmt.Errorf("创建脚本暂存目录失败: %v", err)
+	}
+	for name, data := range bundle.Files {
+		if err := writeFileAtomic(filepath.Join(stageDir, name), data, 0644); err != nil {
+			return nil, fmt.Errorf("暂存脚本%s失败: %v", name, err)
+		}
+	}
+	if err := writeFileAtomic(filepath.Join(stageDir, "manifest.json"), raw, 0644); err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(filepath.Join(stageDir, "manifest.sig"), signature, 0644); err != nil {
+		return nil, err
+	}
+
+	state, err := json.Marshal(scriptActiveState{Version: manifest.Version})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(filepath.Join(si.scriptUpdateDir(), scriptHighestFile), state, 0644); err != nil {
+		return nil, fmt.Errorf("记录脚本版本失败: %v", err)
+	}
+	if err := writeFileAtomic(filepath.Join(si.scriptUpdateDir(), scriptActiveFile), state, 0644); err != nil {
+		return nil, fmt.Errorf("启用脚本更新失败: %v", err)
+	}
+
+	if err := si.setupReinstallScript(); err != nil {
+		return nil, err
+	}
+	return si.GetReinstallScriptInfo(), nil
+}
+
+// RollbackReinstallScript 回滚到程序内置的脚本
+func (si *SystemInstaller) RollbackReinstallScript() (*ReinstallScriptInfo, error) {
+	if si.isInstallRunning() {
+		return nil, fmt.Errorf("安装进行中，无法回滚脚本")
+	}
+
+	activePath := filepath.Join(si.scriptUpdateDir(), scriptActiveFile)
+	if err := os.Remove(activePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("回滚脚本失败: %v", err)
+	}
+
+	if err := si.setupReinstallScript(); err != nil {
+		return nil, err
+	}
+	return si.GetReinstallScriptInfo(), nil
+}
+
+// loadStagedScriptBundle 读取已启用的更新脚本，没有启用更新时返回nil
+func (si *SystemInstaller) loadStagedScriptBundle() (*scriptBundle, error) {
+	data, err := os.ReadFile(filepath.Join(si.scriptUpdateDir(), scriptActiveFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state scriptActiveState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("脚本启用状态解析失败: %v", err)
+	}
+	if !scriptNamePattern.MatchString(state.Version) {
+		return nil, fmt.Errorf("脚本版本号无效: %q", state.Version)
+	}
+
+	// 每次加载都重新校验签名和哈希，防止暂存文件被篡改
+	stageDir := filepath.Join(si.scriptUpdateDir(), state.Version)
+	raw, err := os.ReadFile(filepath.Join(stageDir, "manifest.json"))
+	if err != nil {
+		return nil, err
+	}
+	signature, err := os.ReadFile(filepath.Join(stageDir, "manifest.sig"))
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := parseSignedScriptManifest(raw, signature)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Version != state.Version {
+		return nil, fmt.Errorf("脚本版本不一致: %s", state.Version)
+	}
+
+	bundle := &scriptBundle{
+		Version: manifest.Version,
+		Source:  scriptSourceUpdate,
+		Files:   make(map[string][]byte, len(manifest.Files)),
+	}
+	for name, expected := range manifest.Files {
+		fileData, err := os.ReadFile(filepath.Join(stageDir, name))
+		if err != nil {
+			return nil, err
+		}
+		if !strings.EqualFold(sha256Hex(fileData), expected) {
+			return nil, fmt.Errorf("暂存脚本已被修改: %s", name)
+		}
+		bundle.Files[name] = fileData
+	}
+	if err := bundle.validate(); err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
@@ -0,0 +1,189 @@
+package core
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompareScriptVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"2025.06.01", "2025.06.01", 0},
+		{"2025.06.02", "2025.06.01", 1},
+		{"2025.6.1", "2025.06.01", 0},
+		{"2025.10.01", "2025.9.30", 1},
+		{"2025.06", "2025.06.01", -1},
+		{"1.10", "1.9", 1},
+	}
+	for _, tt := range tests {
+		got, err := compareScriptVersions(tt.a, tt.b)
+		if err != nil {
+			t.Fatalf("compareScriptVersions(%q, %q): %v", tt.a, tt.b, err)
+		}
+		if got != tt.want {
+			t.Errorf("compareScriptVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+	for _, invalid := range []string{"", "v1.0", "1..2", "abc1234", "unbundled"} {
+		if _, err := parseScriptVersion(invalid); err == nil {
+			t.Errorf("parseScriptVersion(%q) 应失败", invalid)
+		}
+	}
+}
+
+// withScriptKeys 临时替换信任的公钥列表
+func withScriptKeys(t *testing.T, keys ...ed25519.PublicKey) {
+	t.Helper()
+	saved := scriptUpdatePublicKeys
+	scriptUpdatePublicKeys = nil
+	for _, key := range keys {
+		scriptUpdatePublicKeys = append(scriptUpdatePublicKeys, base64.StdEncoding.EncodeToString(key))
+	}
+	t.Cleanup(func() { scriptUpdatePublicKeys = saved })
+}
+
+func TestScriptReleaseSignatureRotation(t *testing.T) {
+	oldPublic, oldPrivate, _ := ed25519.GenerateKey(rand.Reader)
+	newPublic, newPrivate, _ := ed25519.GenerateKey(rand.Reader)
+	_, otherPrivate, _ := ed25519.GenerateKey(rand.Reader)
+	files := map[string][]byte{"reinstall.sh": []byte("#!/bin/sh\n")}
+
+	withScriptKeys(t, oldPublic, newPublic)
+	for _, key := range []ed25519.PrivateKey{oldPrivate, newPrivate} {
+		release, err := SignScriptRelease(key, "2025.07.01", "https://example.com", files)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, _, err := verifyScriptRelease(release); err != nil {
+			t.Fatalf("轮换期间两个密钥都应被接受: %v", err)
+		}
+	}
+
+	release, _ := SignScriptRelease(otherPrivate, "2025.07.01", "https://example.com", files)
+	if _, _, _, err := verifyScriptRelease(release); err == nil {
+		t.Fatal("未登记的密钥签名应被拒绝")
+	}
+
+	withScriptKeys(t)
+	release, _ = SignScriptRelease(newPrivate, "2025.07.01", "https://example.com", files)
+	if _, _, _, err := verifyScriptRelease(release); err == nil {
+		t.Fatal("没有登记公钥时应拒绝更新")
+	}
+}
+
+// TestPinnedScriptKeyAcceptsReleaseFixture testdata 中的发布信息由登记的发布私钥签名，必须能通过默认公钥列表的校验
+func TestPinnedScriptKeyAcceptsReleaseFixture(t *testing.T) {
+	dir := filepath.Join("testdata", "script_release")
+	data, err := os.ReadFile(filepath.Join(dir, "release.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var release ScriptRelease
+	if err := json.Unmarshal(data, &release); err != nil {
+		t.Fatal(err)
+	}
+	manifest, _, _, err := verifyScriptRelease(&release)
+	if err != nil {
+		t.Fatalf("登记的公钥应接受发布私钥签名的清单: %v", err)
+	}
+	if manifest.Version != "2025.07.01" || len(manifest.Files) != 2 {
+		t.Fatalf("manifest = %+v", manifest)
+	}
+	for name, sum := range manifest.Files {
+		script, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sha256Hex(script) != sum {
+			t.Errorf("%s 的哈希与清单不一致", name)
+		}
+	}
+
+	tampered := release
+	tampered.Manifest = base64.StdEncoding.EncodeToString([]byte(`{"version":"2099.01.01","files":{}}`))
+	if _, _, _, err := verifyScriptRelease(&tampered); err == nil {
+		t.Fatal("篡改后的清单应被拒绝")
+	}
+}
+
+func TestCheckScriptVersionRejectsDowngrade(t *testing.T) {
+	si := NewSystemInstaller()
+	si.workingDir = t.TempDir()
+	si.scriptInfo = &ReinstallScriptInfo{Version: "2025.06.01", Source: scriptSourceEmbedded}
+
+	for version, want := range map[string]bool{"2025.05.30": false, "2025.06.01": false, "2025.06.02": true} {
+		got, err := si.checkScriptVersion(version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("checkScriptVersion(%q) = %v, want %v", version, got, want)
+		}
+	}
+
+	// 启用过 2025.08.01 后回滚到内置脚本，旧的 2025.07.01 清单不能重放
+	if err := os.MkdirAll(si.scriptUpdateDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(si.scriptUpdateDir(), scriptHighestFile), []byte(`{"version":"2025.08.01"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := si.checkScriptVersion("2025.07.01"); err != nil || ok {
+		t.Fatalf("checkScriptVersion(2025.07.01) = %v, %v; want false", ok, err)
+	}
+	if ok, err := si.checkScriptVersion("2025.08.02"); err != nil || !ok {
+		t.Fatalf("checkScriptVersion(2025.08.02) = %v, %v; want true", ok, err)
+	}
+}
+
+func TestExtractScriptBundleRestoresDirectoryExactly(t *testing.T) {
+	dir := t.TempDir()
+	update := &scriptBundle{Version: "2025.07.01", Source: scriptSourceUpdate, Files: map[string][]byte{
+		"reinstall.sh":  []byte("update"),
+		"trans.sh":      []byte("only in update"),
+		"reinstall.bat": []byte("update bat"),
+	}}
+	if _, err := extractScriptBundle(update, dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "cache"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	embedded := &scriptBundle{Version: "2025.06.01", Source: scriptSourceEmbedded, Files: map[string][]byte{
+		"reinstall.sh":  []byte("embedded"),
+		"reinstall.bat": []byte("embedded bat"),
+	}}
+	info, err := extractScriptBundle(embedded, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	want := []string{scriptStateFile, "reinstall.bat", "reinstall.sh"}
+	if len(names) != len(want) {
+		t.Fatalf("回滚后目录内容 = %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("回滚后目录内容 = %v, want %v", names, want)
+		}
+	}
+	if err := verifyScriptFiles(dir, info.SHA256); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,434 @@
+package core
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// secretsFileName 加密密钥库文件名
+const secretsFileName = "secrets.enc"
+
+// secretsVersion 密钥库文件格式版本
+const secretsVersion = 1
+
+// minSecretPassphraseLength 新建密钥库或修改口令时口令的最小长度
+const minSecretPassphraseLength = 8
+
+// Argon2id 参数，按 RFC 9106 的第二推荐配置
+const (
+	secretKDFAlgorithm = "argon2id"
+	secretKDFTime      = 3
+	secretKDFMemory    = 64 * 1024 // KiB
+	secretKDFThreads   = 4
+	secretKeyLength    = 32 // AES-256
+	secretSaltLength   = 16
+)
+
+// 从文件读取的密钥派生参数上限，防止被篡改的文件让解锁耗尽内存或CPU
+const (
+	maxSecretKDFTime    = 16
+	maxSecretKDFMemory  = 1024 * 1024 // KiB，即1GiB
+	maxSecretKDFThreads = 16
+	maxSecretSaltLength = 64
+)
+
+var (
+	// ErrSecretNotFound 密钥库中没有该项
+	ErrSecretNotFound = errors.New("密钥不存在")
+	// ErrSecretStoreLocked 密钥库未解锁
+	ErrSecretStoreLocked = errors.New("密钥库未解锁")
+	// ErrSecretPassphrase 口令错误或密钥库文件被篡改
+	ErrSecretPassphrase = errors.New("口令错误或密钥库已损坏")
+)
+
+// SecretStore 密码、SSH密钥、API密钥等敏感信息的存储
+//
+// 键使用 / 分隔的路径，如 api/key、profile/<方案名>/password。
+// 目前只有加密文件实现，系统钥匙串（Windows凭据管理器、macOS钥匙串、Secret Service）可以实现同一接口。
+type SecretStore interface {
+	// Get 读取密钥，不存在时返回 ErrSecretNotFound
+	Get(key string) (string, error)
+	// Set 保存密钥，value 为空时删除
+	Set(key, value string) error
+	// Delete 删除密钥，不存在时不报错
+	Delete(key string) error
+	// List 列出以 prefix 开头的键
+	List(prefix string) ([]string, error)
+}
+
+// secretKDF 口令派生密钥的参数，随文件保存
+type secretKDF struct {
+	Algorithm string `json:"algorithm"`
+	Salt      []byte `json:"salt"`
+	Time      uint32 `json:"time"`
+	Memory    uint32 `json:"memory"` // KiB
+	Threads   uint8  `json:"threads"`
+}
+
+// secretFileHeader 密钥库文件的明文头，作为AES-GCM的附加数据防止被替换
+type secretFileHeader struct {
+	Version int       `json:"version"`
+	KDF     secretKDF `json:"kdf"`
+}
+
+// secretFile 密钥库文件的内容
+type secretFile struct {
+	secretFileHeader
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// deriveKey 按参数从口令派生密钥
+func (k secretKDF) deriveKey(passphrase string) ([]byte, error) {
+	if k.Algorithm != secretKDFAlgorithm {
+		return nil, fmt.Errorf("不支持的密钥派生算法: %s", k.Algorithm)
+	}
+	if len(k.Salt) == 0 || k.Time == 0 || k.Memory == 0 || k.Threads == 0 {
+		return nil, fmt.Errorf("密钥派生参数无效")
+	}
+	// 超出上限的参数不能截断后继续使用（派生出的密钥会不同），直接拒绝
+	if k.Time > maxSecretKDFTime || k.Memory > maxSecretKDFMemory || k.Threads > maxSecretKDFThreads || len(k.Salt) > maxSecretSaltLength {
+		return nil, fmt.Errorf("密钥派生参数超出上限: time=%d memory=%dKiB threads=%d", k.Time, k.Memory, k.Threads)
+	}
+	return argon2.IDKey([]byte(passphrase), k.Salt, k.Time, k.Memory, k.Threads, secretKeyLength), nil
+}
+
+// newSecretKDF 生成使用新盐值的默认参数
+func newSecretKDF() (secretKDF, error) {
+	salt := make([]byte, secretSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return secretKDF{}, fmt.Errorf("生成盐值失败: %v", err)
+	}
+	return secretKDF{
+		Algorithm: secretKDFAlgorithm,
+		Salt:      salt,
+		Time:      secretKDFTime,
+		Memory:    secretKDFMemory,
+		Threads:   secretKDFThreads,
+	}, nil
+}
+
+// validateSecretPassphrase 校验新口令
+func validateSecretPassphrase(passphrase string) error {
+	if len([]rune(passphrase)) < minSecretPassphraseLength {
+		return fmt.Errorf("口令至少需要%d个字符", minSecretPassphraseLength)
+	}
+	return nil
+}
+
+// FileSecretStore 用口令加密的文件密钥库
+//
+// 密钥由口令经 Argon2id 派生，所有密钥序列化后整体用 AES-256-GCM 加密，每次保存使用新的随机数。
+// 解锁前所有读写操作都返回 ErrSecretStoreLocked。
+type FileSecretStore struct {
+	path    string
+	mutex   sync.Mutex
+	header  secretFileHeader
+	key     []byte // 为nil表示未解锁
+	secrets map[string]string
+	newKDF  func() (secretKDF, error) // 新建和修改口令时生成派生参数，测试中替换为低开销的参数
+}
+
+// NewFileSecretStore 创建文件密钥库，文件在第一次解锁时创建
+func NewFileSecretStore(path string) *FileSecretStore {
+	return &FileSecretStore{path: path, newKDF: newSecretKDF}
+}
+
+// DefaultSecretsPath 默认的密钥库路径，位于当前用户的配置目录
+func DefaultSecretsPath() string {
+	return filepath.Join(UserConfigDir(), secretsFileName)
+}
+
+// Path 密钥库文件路径
+func (s *FileSecretStore) Path() string {
+	return s.path
+}
+
+// Exists 密钥库文件是否已创建
+func (s *FileSecretStore) Exists() bool {
+	_, err := os.Stat(s.path)
+	return err == nil
+}
+
+// Locked 密钥库是否未解锁
+func (s *FileSecretStore) Locked() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.key == nil
+}
+
+// Unlock 用口令解锁密钥库，文件不存在时用该口令新建
+func (s *FileSecretStore) Unlock(passphrase string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s.create(passphrase)
+	}
+	if err != nil {
+		return fmt.Errorf("读取密钥库失败: %v", err)
+	}
+
+	var file secretFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("密钥库文件格式错误: %v", err)
+	}
+	if file.Version > secretsVersion {
+		return fmt.Errorf("密钥库版本 %d 高于当前支持的版本 %d", file.Version, secretsVersion)
+	}
+	key, err := file.KDF.deriveKey(passphrase)
+	if err != nil {
+		return err
+	}
+	secrets, err := openSecrets(key, file)
+	if err != nil {
+		return err
+	}
+
+	s.header = file.secretFileHeader
+	s.key = key
+	s.secrets = secrets
+	return nil
+}
+
+// create 用口令新建空的密钥库并立即写入文件
+func (s *FileSecretStore) create(passphrase string) error {
+	if err := validateSecretPassphrase(passphrase); err != nil {
+		return err
+	}
+	kdf, err := s.newKDF()
+	if err != nil {
+		return err
+	}
+	key, err := kdf.deriveKey(passphrase)
+	if err != nil {
+		return err
+	}
+
+	header := secretFileHeader{Version: secretsVersion, KDF: kdf}
+	secrets := make(map[string]string)
+	if err := s.write(header, key, secrets); err != nil {
+		return err
+	}
+	s.header = header
+	s.key = key
+	s.secrets = secrets
+	return nil
+}
+
+// Lock 锁定密钥库，清除内存中的密钥
+func (s *FileSecretStore) Lock() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.key {
+		s.key[i] = 0
+	}
+	s.key = nil
+	s.secrets = nil
+}
+
+// ChangePassphrase 修改口令，使用新的盐值重新加密
+func (s *FileSecretStore) ChangePassphrase(oldPassphrase, newPassphrase string) error {
+	if err := validateSecretPassphrase(newPassphrase); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.key == nil {
+		return ErrSecretStoreLocked
+	}
+	oldKey, err := s.header.KDF.deriveKey(oldPassphrase)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(oldKey, s.key) != 1 {
+		return ErrSecretPassphrase
+	}
+
+	kdf, err := s.newKDF()
+	if err != nil {
+		return err
+	}
+	key, err := kdf.deriveKey(newPassphrase)
+	if err != nil {
+		return err
+	}
+	header := secretFileHeader{Version: secretsVersion, KDF: kdf}
+	if err := s.write(header, key, s.secrets); err != nil {
+		return err
+	}
+	s.header = header
+	s.key = key
+	return nil
+}
+
+// Get 实现 SecretStore
+func (s *FileSecretStore) Get(key string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.key == nil {
+		return "", ErrSecretStoreLocked
+	}
+	value, ok := s.secrets[key]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrSecretNotFound, key)
+	}
+	return value, nil
+}
+
+// Set 实现 SecretStore
+func (s *FileSecretStore) Set(key, value string) error {
+	if value == "" {
+		return s.Delete(key)
+	}
+	if strings.TrimSpace(key) == "" {
+		return fmt.Errorf("密钥名称不能为空")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.key == nil {
+		return ErrSecretStoreLocked
+	}
+	if current, ok := s.secrets[key]; ok && current == value {
+		return nil
+	}
+	secrets := s.copySecrets()
+	secrets[key] = value
+	return s.commit(secrets)
+}
+
+// Delete 实现 SecretStore
+func (s *FileSecretStore) Delete(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.key == nil {
+		return ErrSecretStoreLocked
+	}
+	if _, ok := s.secrets[key]; !ok {
+		return nil
+	}
+	secrets := s.copySecrets()
+	delete(secrets, key)
+	return s.commit(secrets)
+}
+
+// List 实现 SecretStore
+func (s *FileSecretStore) List(prefix string) ([]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.key == nil {
+		return nil, ErrSecretStoreLocked
+	}
+	keys := []string{}
+	for key := range s.secrets {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// copySecrets 复制当前的密钥，写入成功后才替换
+func (s *FileSecretStore) copySecrets() map[string]string {
+	secrets := make(map[string]string, len(s.secrets)+1)
+	for key, value := range s.secrets {
+		secrets[key] = value
+	}
+	return secrets
+}
+
+// commit 写入文件并更新内存中的密钥
+func (s *FileSecretStore) commit(secrets map[string]string) error {
+	if err := s.write(s.header, s.key, secrets); err != nil {
+		return err
+	}
+	s.secrets = secrets
+	return nil
+}
+
+// write 加密并原子地写入密钥库文件
+func (s *FileSecretStore) write(header secretFileHeader, key []byte, secrets map[string]string) error {
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	additional, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	aead, err := newSecretAEAD(key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("生成随机数失败: %v", err)
+	}
+
+	data, err := json.MarshalIndent(secretFile{
+		secretFileHeader: header,
+		Nonce:            nonce,
+		Ciphertext:       aead.Seal(nil, nonce, plaintext, additional),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("创建配置目录失败: %v", err)
+	}
+	if err := writeFileAtomic(s.path, data, 0600); err != nil {
+		return fmt.Errorf("保存密钥库失败: %v", err)
+	}
+	return nil
+}
+
+// openSecrets 解密密钥库文件中的密钥
+func openSecrets(key []byte, file secretFile) (map[string]string, error) {
+	additional, err := json.Marshal(file.secretFileHeader)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newSecretAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(file.Nonce) != aead.NonceSize() {
+		return nil, ErrSecretPassphrase
+	}
+	plaintext, err := aead.Open(nil, file.Nonce, file.Ciphertext, additional)
+	if err != nil {
+		return nil, ErrSecretPassphrase
+	}
+
+	secrets := make(map[string]string)
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, fmt.Errorf("密钥库内容格式错误: %v", err)
+	}
+	return secrets, nil
+}
+
+// newSecretAEAD 创建 AES-256-GCM 加密器
+func newSecretAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,242 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTestSecretStore 使用低开销派生参数的密钥库
+func newTestSecretStore(path string) *FileSecretStore {
+	store := NewFileSecretStore(path)
+	store.newKDF = func() (secretKDF, error) {
+		kdf, err := newSecretKDF()
+		kdf.Time, kdf.Memory, kdf.Threads = 1, 64, 1
+		return kdf, err
+	}
+	return store
+}
+
+// readSecretFile 读取密钥库文件
+func readSecretFile(t *testing.T, path string) secretFile {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file secretFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// writeSecretFileJSON 写入密钥库文件
+func writeSecretFileJSON(t *testing.T, path string, file secretFile) {
+	t.Helper()
+	data, err := json.Marshal(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileSecretStoreLifecycle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.enc")
+	store := newTestSecretStore(path)
+	if !store.Locked() || store.Exists() {
+		t.Fatal("新的密钥库应未解锁且没有文件")
+	}
+	if _, err := store.Get("api/key"); !errors.Is(err, ErrSecretStoreLocked) {
+		t.Fatalf("解锁前 Get err = %v", err)
+	}
+	if err := store.Set("api/key", "x"); !errors.Is(err, ErrSecretStoreLocked) {
+		t.Fatalf("解锁前 Set err = %v", err)
+	}
+	if err := store.Unlock("short"); err == nil || store.Exists() {
+		t.Fatal("口令过短时不应创建密钥库")
+	}
+
+	if err := store.Unlock("correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	if !store.Exists() || store.Locked() {
+		t.Fatal("第一次解锁应创建密钥库")
+	}
+	if err := store.Set("api/key", "sk-123"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("profile/web/password", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(path); err != nil || strings.Contains(string(data), "sk-123") {
+		t.Fatalf("密钥库文件中有明文: %s, %v", data, err)
+	}
+
+	store.Lock()
+	if !store.Locked() {
+		t.Fatal("Lock 后应处于锁定状态")
+	}
+	if _, err := store.List(""); !errors.Is(err, ErrSecretStoreLocked) {
+		t.Fatalf("锁定后 List err = %v", err)
+	}
+
+	// 重新打开文件解锁
+	reopened := newTestSecretStore(path)
+	if err := reopened.Unlock("correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := reopened.Get("api/key"); err != nil || value != "sk-123" {
+		t.Fatalf("Get = %q, %v", value, err)
+	}
+	if keys, err := reopened.List("profile/"); err != nil || !reflect.DeepEqual(keys, []string{"profile/web/password"}) {
+		t.Fatalf("List = %v, %v", keys, err)
+	}
+	if err := reopened.Set("api/key", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reopened.Get("api/key"); !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("值为空的 Set 应删除密钥，err = %v", err)
+	}
+}
+
+func TestFileSecretStoreWrongPassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.enc")
+	store := newTestSecretStore(path)
+	if err := store.Unlock("correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("api/key", "sk-123"); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := newTestSecretStore(path)
+	if err := reopened.Unlock("wrong passphrase"); !errors.Is(err, ErrSecretPassphrase) {
+		t.Fatalf("Unlock err = %v, want ErrSecretPassphrase", err)
+	}
+	if !reopened.Locked() {
+		t.Fatal("口令错误时不应解锁")
+	}
+}
+
+func TestFileSecretStoreRejectsTamperedFile(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(file *secretFile)
+	}{
+		{"密文", func(file *secretFile) { file.Ciphertext[0] ^= 1 }},
+		{"随机数", func(file *secretFile) { file.Nonce[0] ^= 1 }},
+		{"截断随机数", func(file *secretFile) { file.Nonce = file.Nonce[:4] }},
+		// 头部作为附加数据参与认证，即使派生出相同的密钥也不能通过
+		{"头部版本", func(file *secretFile) { file.Version = 0 }},
+		{"头部派生参数", func(file *secretFile) { file.KDF.Time++ }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "secrets.enc")
+			store := newTestSecretStore(path)
+			if err := store.Unlock("correct horse"); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Set("api/key", "sk-123"); err != nil {
+				t.Fatal(err)
+			}
+
+			file := readSecretFile(t, path)
+			tt.tamper(&file)
+			writeSecretFileJSON(t, path, file)
+
+			if err := newTestSecretStore(path).Unlock("correct horse"); !errors.Is(err, ErrSecretPassphrase) {
+				t.Fatalf("Unlock err = %v, want ErrSecretPassphrase", err)
+			}
+		})
+	}
+}
+
+func TestFileSecretStoreChangePassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.enc")
+	store := newTestSecretStore(path)
+	if err := store.ChangePassphrase("correct horse", "battery staple"); !errors.Is(err, ErrSecretStoreLocked) {
+		t.Fatalf("未解锁时 ChangePassphrase err = %v", err)
+	}
+	if err := store.Unlock("correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("api/key", "sk-123"); err != nil {
+		t.Fatal(err)
+	}
+	before := readSecretFile(t, path)
+
+	if err := store.ChangePassphrase("wrong passphrase", "battery staple"); !errors.Is(err, ErrSecretPassphrase) {
+		t.Fatalf("旧口令错误时 err = %v", err)
+	}
+	if err := store.ChangePassphrase("correct horse", "short"); err == nil {
+		t.Fatal("新口令过短时应失败")
+	}
+	if err := store.ChangePassphrase("correct horse", "battery staple"); err != nil {
+		t.Fatal(err)
+	}
+
+	after := readSecretFile(t, path)
+	if reflect.DeepEqual(before.KDF.Salt, after.KDF.Salt) {
+		t.Error("修改口令后应使用新的盐值")
+	}
+	// 修改口令后仍可继续写入
+	if err := store.Set("api/secret", "s3"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := newTestSecretStore(path).Unlock("correct horse"); !errors.Is(err, ErrSecretPassphrase) {
+		t.Fatalf("旧口令 Unlock err = %v", err)
+	}
+	reopened := newTestSecretStore(path)
+	if err := reopened.Unlock("battery staple"); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{"api/key": "sk-123", "api/secret": "s3"} {
+		if value, err := reopened.Get(key); err != nil || value != want {
+			t.Errorf("Get(%s) = %q, %v", key, value, err)
+		}
+	}
+}
+
+func TestUnlockRejectsOversizedKDFParams(t *testing.T) {
+	tests := []struct {
+		name string
+		kdf  secretKDF
+	}{
+		{"内存", secretKDF{Memory: 1 << 31, Time: 1, Threads: 1}},
+		{"迭代次数", secretKDF{Memory: 8, Time: 1 << 30, Threads: 1}},
+		{"线程数", secretKDF{Memory: 8, Time: 1, Threads: 255}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kdf := tt.kdf
+			kdf.Algorithm = secretKDFAlgorithm
+			kdf.Salt = make([]byte, secretSaltLength)
+			data, err := json.Marshal(secretFile{
+				secretFileHeader: secretFileHeader{Version: secretsVersion, KDF: kdf},
+				Nonce:            make([]byte, 12),
+				Ciphertext:       make([]byte, 32),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(t.TempDir(), "secrets.json")
+			if err := os.WriteFile(path, data, 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			// 参数未被限制时这里会分配大量内存或长时间运行
+			if err := NewFileSecretStore(path).Unlock("passphrase"); err == nil {
+				t.Fatal("Unlock 应拒绝超出上限的派生参数")
+			}
+		})
+	}
+}
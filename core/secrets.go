@@ -0,0 +1,231 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sshKeyReferencePrefixes reinstall 脚本自行获取公钥的 --ssh-key 写法，不是密钥内容本身
+var sshKeyReferencePrefixes = []string{"github:", "gitlab:", "http://", "https://"}
+
+// redactedMask 脱敏后的占位符
+const redactedMask = "******"
+
+// Redacted 返回屏蔽了密码和SSH密钥的安装选项副本，用于日志、计划和错误信息
+func (o InstallOptions) Redacted() InstallOptions {
+	redacted := o
+	if redacted.Password != "" {
+		redacted.Password = redactedMask
+	}
+	if redacted.SSHKey != "" {
+		redacted.SSHKey = redactedMask
+	}
+	if o.ExtraOptions != nil {
+		redacted.ExtraOptions = make(map[string]string, len(o.ExtraOptions))
+		for key, value := range o.ExtraOptions {
+			redacted.ExtraOptions[key] = value
+		}
+	}
+	if o.Drivers != nil {
+		redacted.Drivers = append([]string(nil), o.Drivers...)
+	}
+	return redacted
+}
+
+// String 实现fmt.Stringer，避免通过%v打印出敏感信息
+func (o InstallOptions) String() string {
+	type plain InstallOptions
+	return fmt.Sprintf("%+v", plain(o.Redacted()))
+}
+
+// 安装选项中可以保存到密钥库的字段
+const (
+	SecretFieldPassword = "password"
+	SecretFieldSSHKey   = "ssh_key"
+)
+
+// optionSecret 安装选项中的一个敏感字段
+type optionSecret struct {
+	name  string
+	value *string
+}
+
+// secretFields 按固定顺序返回安装选项中的敏感字段
+func (o *InstallOptions) secretFields() []optionSecret {
+	return []optionSecret{
+		{SecretFieldPassword, &o.Password},
+		{SecretFieldSSHKey, &o.SSHKey},
+	}
+}
+
+// SaveSecrets 将密码和SSH密钥保存到密钥库的 prefix/<字段> 下，空字段会删除对应的键
+// 返回保存了的字段
+func (o InstallOptions) SaveSecrets(store SecretStore, prefix string) ([]string, error) {
+	saved := []string{}
+	for _, field := range o.secretFields() {
+		if err := store.Set(prefix+"/"+field.name, *field.value); err != nil {
+			return nil, fmt.Errorf("保存%s失败: %w", field.name, err)
+		}
+		if *field.value != "" {
+			saved = append(saved, field.name)
+		}
+	}
+	return saved, nil
+}
+
+// LoadSecrets 从密钥库读取 fields 列出的字段，已经有值的字段不会被覆盖
+func (o *InstallOptions) LoadSecrets(store SecretStore, prefix string, fields []string) error {
+	for _, field := range o.secretFields() {
+		if *field.value != "" || !containsString(fields, field.name) {
+			continue
+		}
+		value, err := store.Get(prefix + "/" + field.name)
+		if err != nil {
+			return fmt.Errorf("读取%s失败: %w", field.name, err)
+		}
+		*field.value = value
+	}
+	return nil
+}
+
+// Redactor 从任意文本中屏蔽已知的敏感信息
+type Redactor struct {
+	secrets []string
+}
+
+// NewRedactor 创建脱敏器，空字符串会被忽略
+func NewRedactor(secrets ...string) *Redactor {
+	r := &Redactor{}
+	for _, secret := range secrets {
+		if secret != "" {
+			r.secrets = append(r.secrets, secret)
+		}
+	}
+	return r
+}
+
+// NewOptionsRedactor 根据安装选项中的敏感字段创建脱敏器
+func NewOptionsRedactor(options InstallOptions) *Redactor {
+	return NewRedactor(options.Password, options.SSHKey)
+}
+
+// Redact 屏蔽文本中的敏感信息
+func (r *Redactor) Redact(text string) string {
+	if r == nil {
+		return text
+	}
+	for _, secret := range r.secrets {
+		text = strings.ReplaceAll(text, secret, redactedMask)
+	}
+	return text
+}
+
+// RedactError 屏蔽错误信息中的敏感信息
+func (r *Redactor) RedactError(err error) error {
+	if err == nil || r == nil {
+		return err
+	}
+	message := err.Error()
+	if redacted := r.Redact(message); redacted != message {
+		return errors.New(redacted)
+	}
+	return err
+}
+
+// scriptPasswordFileEnv 指向密码文件的环境变量，由打包脚本开头的补丁读取（见 tools/vendorscripts）
+const scriptPasswordFileEnv = "REINSTALL_PASSWORD_FILE"
+
+// scriptSecrets 传给reinstall脚本的敏感参数
+//
+// 敏感内容都不出现在命令行上：密码写入0600临时文件，通过继承的环境变量 REINSTALL_PASSWORD_FILE 传递路径；
+// --ssh-key 可以是公钥文件路径，因此SSH密钥同样写入0600临时文件后只传路径。临时文件在脚本结束后删除。
+type scriptSecrets struct {
+	Password string
+	SSHKey   string
+}
+
+// newScriptSecrets 从安装选项中提取敏感参数
+// 脚本不接受密码哈希，已哈希的密码会被当作明文设置，因此直接报错
+func newScriptSecrets(options InstallOptions) (scriptSecrets, error) {
+	if isCryptHash(options.Password) {
+		return scriptSecrets{}, fmt.Errorf("密码是crypt哈希值，reinstall脚本会把它当作明文密码设置")
+	}
+	return scriptSecrets{Password: options.Password, SSHKey: options.SSHKey}, nil
+}
+
+// isCryptHash 判断字符串是否已经是crypt格式的哈希
+func isCryptHash(value string) bool {
+	return strings.HasPrefix(value, "$6$") || strings.HasPrefix(value, "$5$") ||
+		strings.HasPrefix(value, "$y$") || strings.HasPrefix(value, "$2b$")
+}
+
+// prepare 将密码和SSH密钥写入 dir 下的临时文件，返回 --ssh-key 参数和需要追加的环境变量
+// 返回的清理函数删除临时文件，始终不为nil
+func (s scriptSecrets) prepare(dir string) ([]string, []string, func(), error) {
+	var args, env []string
+	var files []func()
+	cleanup := func() {
+		for _, remove := range files {
+			remove()
+		}
+	}
+
+	// Windows 上脚本在 Cygwin 中运行，使用正斜杠路径
+	if s.Password != "" {
+		path, remove, err := writeSecretFile(dir, "reinstall-password-*", s.Password)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		files = append(files, remove)
+		env = append(env, scriptPasswordFileEnv+"="+filepath.ToSlash(path))
+	}
+	if s.SSHKey == "" {
+		return args, env, cleanup, nil
+	}
+	for _, prefix := range sshKeyReferencePrefixes {
+		if strings.HasPrefix(s.SSHKey, prefix) {
+			return append(args, "--ssh-key", s.SSHKey), env, cleanup, nil
+		}
+	}
+
+	path, remove, err := writeSecretFile(dir, "reinstall-ssh-key-*", strings.TrimRight(s.SSHKey, "\r\n")+"\n")
+	if err != nil {
+		cleanup()
+		return nil, nil, nil, err
+	}
+	files = append(files, remove)
+	return append(args, "--ssh-key", filepath.ToSlash(path)), env, cleanup, nil
+}
+
+// writeSecretFile 将内容写入仅当前用户可读的临时文件，返回路径和清理函数
+func writeSecretFile(dir, pattern, content string) (string, func(), error) {
+	// CreateTemp 以0600权限创建文件
+	file, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return "", nil, fmt.Errorf("创建敏感参数文件失败: %v", err)
+	}
+	path := file.Name()
+	cleanup := func() {
+		os.Remove(path)
+	}
+
+	if err := file.Chmod(0600); err != nil && !errors.Is(err, errors.ErrUnsupported) {
+		file.Close()
+		cleanup()
+		return "", nil, fmt.Errorf("设置敏感参数文件权限失败: %v", err)
+	}
+	if _, err := file.WriteString(content); err != nil {
+		file.Close()
+		cleanup()
+		return "", nil, fmt.Errorf("写入敏感参数文件失败: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("写入敏感参数文件失败: %v", err)
+	}
+
+	return path, cleanup, nil
+}
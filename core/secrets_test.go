@@ -0,0 +1,66 @@
+package core
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScriptSecretsPrepare(t *testing.T) {
+	if _, err := newScriptSecrets(InstallOptions{Password: "$6$salt$abcdefghijklmnopqrstuv"}); err == nil {
+		t.Fatal("crypt 哈希密码应被拒绝")
+	}
+
+	secrets, err := newScriptSecrets(InstallOptions{Password: "p@ss word", SSHKey: "github:octocat"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	args, env, cleanup, err := secrets.prepare(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"--ssh-key", "github:octocat"}
+	if !reflect.DeepEqual(args, want) {
+		t.Fatalf("args = %q, want %q", args, want)
+	}
+	if len(env) != 1 || !strings.HasPrefix(env[0], scriptPasswordFileEnv+"=") {
+		t.Fatalf("env = %q", env)
+	}
+	passwordFile := strings.TrimPrefix(env[0], scriptPasswordFileEnv+"=")
+	data, err := os.ReadFile(passwordFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "p@ss word" {
+		t.Fatalf("密码文件内容 = %q", data)
+	}
+	if info, err := os.Stat(passwordFile); err == nil && os.PathSeparator == '/' && info.Mode().Perm() != 0600 {
+		t.Fatalf("密码文件权限 = %v", info.Mode().Perm())
+	}
+	cleanup()
+	if _, err := os.Stat(passwordFile); !os.IsNotExist(err) {
+		t.Fatal("清理后密码文件应被删除")
+	}
+
+	key := "ssh-ed25519 AAAA one\nssh-rsa BBBB two\n"
+	secrets, _ = newScriptSecrets(InstallOptions{SSHKey: key})
+	args, env, cleanup, err = secrets.prepare(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(args) != 2 || args[0] != "--ssh-key" || len(env) != 0 {
+		t.Fatalf("args = %q, env = %q", args, env)
+	}
+	data, err = os.ReadFile(args[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != key {
+		t.Fatalf("密钥文件内容 = %q", data)
+	}
+	cleanup()
+	if _, err := os.Stat(args[1]); !os.IsNotExist(err) {
+		t.Fatal("清理后密钥文件应被删除")
+	}
+}
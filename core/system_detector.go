@@ -3,6 +3,7 @@ package core
 import (
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
@@ -156,6 +157,93 @@ func (sd *SystemDetector) detectBootMode() string {
 	return "Unknown"
 }
 
+// HostTarget 安装目标的启动模式和系统盘大小，用于检查镜像兼容性
+// 无法确定时 BootMode 为空、DiskBytes 为0，对应的检查会被跳过
+type HostTarget struct {
+	BootMode  string `json:"boot_mode"`
+	DiskBytes int64  `json:"disk_bytes"`
+}
+
+// DetectHostTarget 检测本机的启动模式和系统盘大小
+func (sd *SystemDetector) DetectHostTarget() HostTarget {
+	target := HostTarget{DiskBytes: systemDiskSize()}
+	switch mode := sd.detectBootMode(); mode {
+	case BootModeUEFI, BootModeLegacy:
+		target.BootMode = mode
+	}
+	return target
+}
+
+// systemDiskSize 系统所在磁盘的大小（字节），无法确定时返回0
+func systemDiskSize() int64 {
+	if runtime.GOOS == "windows" {
+		return windowsSystemDriveSize()
+	}
+	return linuxRootDiskSize("/proc/self/mountinfo", "/sys/class/block")
+}
+
+// windowsSystemDriveSize 系统分区所在逻辑磁盘的大小
+func windowsSystemDriveSize() int64 {
+	drive := os.Getenv("SystemDrive")
+	if drive == "" {
+		drive = "C:"
+	}
+	output, err := exec.Command("wmic", "logicaldisk", "where", "DeviceID='"+drive+"'", "get", "Size", "/value").Output()
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(line), "Size="); ok {
+			size, _ := strconv.ParseInt(value, 10, 64)
+			return size
+		}
+	}
+	return 0
+}
+
+// linuxRootDiskSize 根文件系统所在整块磁盘的大小
+// 从 mountinfo 找到根分区设备，在 /sys/class/block 中取其所属磁盘的 size（512字节扇区数）
+func linuxRootDiskSize(mountinfo, sysBlock string) int64 {
+	data, err := os.ReadFile(mountinfo)
+	if err != nil {
+		return 0
+	}
+	var device string
+	for _, line := range strings.Split(string(data), "\n") {
+		// 格式: id parent major:minor root mountpoint options ... - fstype source superoptions
+		fields := strings.Fields(line)
+		if len(fields) < 5 || fields[4] != "/" {
+			continue
+		}
+		for i, field := range fields {
+			if field == "-" && i+2 < len(fields) {
+				device = fields[i+2]
+			}
+		}
+	}
+	if !strings.HasPrefix(device, "/dev/") {
+		return 0
+	}
+
+	// /sys/class/block/<分区> 指向 .../block/<磁盘>/<分区>，整块磁盘本身没有 partition 文件
+	dir, err := filepath.EvalSymlinks(filepath.Join(sysBlock, filepath.Base(device)))
+	if err != nil {
+		return 0
+	}
+	if _, err := os.Stat(filepath.Join(dir, "partition")); err == nil {
+		dir = filepath.Dir(dir)
+	}
+	sectors, err := os.ReadFile(filepath.Join(dir, "size"))
+	if err != nil {
+		return 0
+	}
+	count, err := strconv.ParseInt(strings.TrimSpace(string(sectors)), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return count * 512
+}
+
 // detectPartitionTable 检测分区表类型
 func (sd *SystemDetector) detectPartitionTable() map[string]interface{} {
 	if !sd.isAdmin {
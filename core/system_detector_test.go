@@ -0,0 +1,55 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLinuxRootDiskSize(t *testing.T) {
+	root := t.TempDir()
+	devices := filepath.Join(root, "devices", "pci0000:00", "block")
+	sysBlock := filepath.Join(root, "class", "block")
+	for _, dir := range []string{filepath.Join(devices, "sda", "sda2"), filepath.Join(devices, "vdb"), sysBlock} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		filepath.Join(devices, "sda", "size"):              "41943040\n",
+		filepath.Join(devices, "sda", "sda2", "size"):      "40000000\n",
+		filepath.Join(devices, "sda", "sda2", "partition"): "2\n",
+		filepath.Join(devices, "vdb", "size"):              "2097152\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, target := range map[string]string{"sda2": filepath.Join(devices, "sda", "sda2"), "vdb": filepath.Join(devices, "vdb")} {
+		if err := os.Symlink(target, filepath.Join(sysBlock, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		mountinfo string
+		want      int64
+	}{
+		{"分区", "22 1 8:2 / / rw,relatime shared:1 - ext4 /dev/sda2 rw\n23 22 0:5 / /dev rw - devtmpfs udev rw\n", 41943040 * 512},
+		{"整块磁盘", "22 1 252:16 / / rw - xfs /dev/vdb rw\n", 2097152 * 512},
+		{"非块设备", "22 1 0:30 / / rw - overlay overlay rw\n", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mountinfo := filepath.Join(t.TempDir(), "mountinfo")
+			if err := os.WriteFile(mountinfo, []byte(tt.mountinfo), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if got := linuxRootDiskSize(mountinfo, sysBlock); got != tt.want {
+				t.Fatalf("linuxRootDiskSize = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
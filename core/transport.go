@@ -0,0 +1,302 @@
+package core
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// secretKeyProxyPassword 代理密码在密钥库中的键
+const secretKeyProxyPassword = "proxy/password"
+
+// pinnedKeyPrefix 公钥固定值的可选前缀，与 curl --pinnedpubkey 的写法一致
+const pinnedKeyPrefix = "sha256//"
+
+// NetworkOptions 创建HTTP传输层的选项
+type NetworkOptions struct {
+	ProxyURL      string   // http://、https:// 或 socks5:// 代理，为空时使用环境变量
+	ProxyPassword string   // 代理地址中只有用户名时使用的密码
+	NoProxy       string   // 不走代理的主机，逗号分隔，为空时使用 NO_PROXY 环境变量
+	CAFile        string   // 额外信任的CA证书(PEM)
+	PinnedHost    string   // 需要校验公钥固定的主机
+	PinnedKeys    []string // 证书链中公钥的SHA-256(base64)，匹配任意一个即可
+}
+
+// NetworkOptionsFromConfig 按配置生成传输层选项，公钥固定作用于API主机
+// store 已解锁时从中读取代理密码
+func NetworkOptionsFromConfig(config *Config, store SecretStore) NetworkOptions {
+	options := NetworkOptions{
+		ProxyURL:   config.Proxy.URL,
+		NoProxy:    config.Proxy.NoProxy,
+		CAFile:     config.TLS.CAFile,
+		PinnedKeys: append([]string(nil), config.TLS.PinnedKeys...),
+	}
+	if len(options.PinnedKeys) > 0 {
+		baseURL := config.API.BaseURL
+		if baseURL == "" {
+			baseURL = DefaultAPIBaseURL
+		}
+		if parsed, err := url.Parse(baseURL); err == nil {
+			options.PinnedHost = parsed.Hostname()
+		}
+	}
+	if store != nil && options.ProxyURL != "" {
+		if password, err := store.Get(secretKeyProxyPassword); err == nil {
+			options.ProxyPassword = password
+		}
+	}
+	return options
+}
+
+// SaveProxyPassword 将代理密码保存到密钥库，为空时删除
+func SaveProxyPassword(store SecretStore, password string) error {
+	if err := store.Set(secretKeyProxyPassword, password); err != nil {
+		return fmt.Errorf("保存代理密码失败: %w", err)
+	}
+	return nil
+}
+
+// HTTPTransport 所有网络组件共享的HTTP传输层
+//
+// 配置变化时替换内部的 http.Transport，已经创建的 http.Client 不需要重建。
+type HTTPTransport struct {
+	mutex     sync.RWMutex
+	transport *http.Transport
+}
+
+// defaultHTTPTransport 程序内默认使用的传输层
+var defaultHTTPTransport = NewHTTPTransport()
+
+// DefaultHTTPTransport 程序内共享的传输层，API客户端、镜像探测和下载都使用它
+func DefaultHTTPTransport() *HTTPTransport {
+	return defaultHTTPTransport
+}
+
+// NewHTTPTransport 创建使用环境变量代理和系统证书的传输层
+func NewHTTPTransport() *HTTPTransport {
+	transport, err := newTransport(NetworkOptions{})
+	if err != nil {
+		panic(err) // 空选项不会出错
+	}
+	return &HTTPTransport{transport: transport}
+}
+
+// Configure 按选项重建传输层，出错时保留原来的设置
+func (t *HTTPTransport) Configure(options NetworkOptions) error {
+	transport, err := newTransport(options)
+	if err != nil {
+		return err
+	}
+
+	t.mutex.Lock()
+	previous := t.transport
+	t.transport = transport
+	t.mutex.Unlock()
+
+	previous.CloseIdleConnections()
+	return nil
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (t *HTTPTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mutex.RLock()
+	transport := t.transport
+	t.mutex.RUnlock()
+	return transport.RoundTrip(req)
+}
+
+// CloseIdleConnections 关闭空闲连接，供 http.Client.CloseIdleConnections 调用
+func (t *HTTPTransport) CloseIdleConnections() {
+	t.mutex.RLock()
+	transport := t.transport
+	t.mutex.RUnlock()
+	transport.CloseIdleConnections()
+}
+
+// Client 创建使用该传输层的客户端，timeout 为0时不限制（用于大文件下载）
+func (t *HTTPTransport) Client(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: t, Timeout: timeout}
+}
+
+// newTransport 按选项创建 http.Transport，其余参数与 http.DefaultTransport 相同
+func newTransport(options NetworkOptions) (*http.Transport, error) {
+	proxy, err := newProxyFunc(options)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := newTLSConfig(options)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+// newProxyFunc 按 NO_PROXY 规则选择代理，本机地址始终直连
+func newProxyFunc(options NetworkOptions) (func(*http.Request) (*url.URL, error), error) {
+	config := httpproxy.FromEnvironment()
+	if options.ProxyURL != "" {
+		proxyURL, err := parseProxyURL(options.ProxyURL, options.ProxyPassword)
+		if err != nil {
+			return nil, err
+		}
+		config = &httpproxy.Config{HTTPProxy: proxyURL, HTTPSProxy: proxyURL, NoProxy: config.NoProxy}
+	}
+	if options.NoProxy != "" {
+		config.NoProxy = options.NoProxy
+	}
+
+	proxyForURL := config.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxyForURL(req.URL)
+	}, nil
+}
+
+// proxyEnvNames 外部命令（curl、wget等）读取的代理环境变量，大小写两种都设置
+var proxyEnvNames = []string{"HTTP_PROXY", "HTTPS_PROXY", "ALL_PROXY", "NO_PROXY"}
+
+// ProxyEnvironment 在 environ 的基础上设置配置的代理，供 reinstall 脚本等外部命令使用
+//
+// 配置了代理时设置 HTTP(S)_PROXY 和 ALL_PROXY；socks5 代理写成 socks5h://，让 curl 通过代理解析域名，
+// 与程序自身的行为一致。没有配置代理时沿用 environ 中的代理环境变量。
+func ProxyEnvironment(environ []string, options NetworkOptions) ([]string, error) {
+	values := map[string]string{}
+	if options.ProxyURL != "" {
+		proxyURL, err := parseProxyURL(options.ProxyURL, options.ProxyPassword)
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(proxyURL, "socks5://") {
+			proxyURL = "socks5h://" + strings.TrimPrefix(proxyURL, "socks5://")
+		}
+		values["HTTP_PROXY"] = proxyURL
+		values["HTTPS_PROXY"] = proxyURL
+		values["ALL_PROXY"] = proxyURL
+	}
+	if options.NoProxy != "" {
+		values["NO_PROXY"] = options.NoProxy
+	}
+	if len(values) == 0 {
+		return environ, nil
+	}
+
+	env := make([]string, 0, len(environ)+2*len(values))
+	for _, entry := range environ {
+		name, _, _ := strings.Cut(entry, "=")
+		if _, ok := values[strings.ToUpper(name)]; ok {
+			continue
+		}
+		env = append(env, entry)
+	}
+	for _, name := range proxyEnvNames {
+		if value, ok := values[name]; ok {
+			env = append(env, name+"="+value, strings.ToLower(name)+"="+value)
+		}
+	}
+	return env, nil
+}
+
+// proxyPassword 代理密码（单独保存的或地址中自带的），用于脱敏
+func (o NetworkOptions) proxyPassword() string {
+	// 地址中自带密码时 parseProxyURL 使用地址中的密码
+	if parsed, err := url.Parse(o.ProxyURL); err == nil && parsed.User != nil {
+		if password, ok := parsed.User.Password(); ok {
+			return password
+		}
+	}
+	return o.ProxyPassword
+}
+
+// parseProxyURL 校验代理地址，地址中只有用户名时补上密码
+func parseProxyURL(location, password string) (string, error) {
+	parsed, err := url.Parse(location)
+	if err != nil || parsed.Host == "" {
+		return "", fmt.Errorf("代理地址无效: %q", location)
+	}
+	switch parsed.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return "", fmt.Errorf("不支持的代理类型 %q", parsed.Scheme)
+	}
+	if parsed.User != nil && password != "" {
+		if _, ok := parsed.User.Password(); !ok {
+			parsed.User = url.UserPassword(parsed.User.Username(), password)
+		}
+	}
+	return parsed.String(), nil
+}
+
+// newTLSConfig 加载额外的CA证书，并为固定的主机校验证书公钥
+func newTLSConfig(options NetworkOptions) (*tls.Config, error) {
+	config := &tls.Config{}
+
+	if options.CAFile != "" {
+		data, err := os.ReadFile(options.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取CA证书失败: %v", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("CA证书文件中没有有效的PEM证书: %s", options.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if options.PinnedHost != "" && len(options.PinnedKeys) > 0 {
+		pins := make(map[[sha256.Size]byte]bool, len(options.PinnedKeys))
+		for _, pin := range options.PinnedKeys {
+			digest, err := parsePinnedKey(pin)
+			if err != nil {
+				return nil, err
+			}
+			pins[digest] = true
+		}
+		host := options.PinnedHost
+		if net.ParseIP(host) != nil {
+			// IP地址不会出现在SNI中，握手时无法区分主机
+			return nil, fmt.Errorf("公钥固定需要API地址使用域名: %s", host)
+		}
+		// 在正常的证书校验之后执行，只作用于固定的主机
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			if !strings.EqualFold(state.ServerName, host) {
+				return nil
+			}
+			for _, cert := range state.PeerCertificates {
+				if pins[sha256.Sum256(cert.RawSubjectPublicKeyInfo)] {
+					return nil
+				}
+			}
+			return fmt.Errorf("%s 的证书公钥与固定的公钥不匹配", host)
+		}
+	}
+	return config, nil
+}
+
+// parsePinnedKey 解析base64编码的公钥SHA-256，可带 sha256// 前缀
+func parsePinnedKey(pin string) ([sha256.Size]byte, error) {
+	var digest [sha256.Size]byte
+	encoded := strings.TrimPrefix(strings.TrimSpace(pin), pinnedKeyPrefix)
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(decoded) != sha256.Size {
+		return digest, fmt.Errorf("公钥固定值应为base64编码的SHA-256: %q", pin)
+	}
+	copy(digest[:], decoded)
+	return digest, nil
+}
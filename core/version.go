@@ -0,0 +1,4 @@
+package core
+
+// AppVersion 程序版本号，发布构建时可通过 -ldflags "-X SystemReinstaller/core.AppVersion=..." 覆盖
+var AppVersion = "2.0.0"
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -10,6 +11,9 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"time"
+
+	"SystemReinstaller/utils"
 )
 
 // VHDManager VHD管理器
@@ -19,6 +23,10 @@ type VHDManager struct {
 	workingDir    string
 	progress      map[string]*DownloadProgress
 	progressMutex sync.RWMutex
+	prober        *ImageProber
+	httpClient    *http.Client
+	rateLimit     int64 // 下载限速(字节/秒)，0为不限速
+	logger        *utils.Logger
 }
 
 // VHDInfo VHD信息
@@ -56,7 +64,23 @@ func NewVHDManager() *VHDManager {
 		vhdDir:      filepath.Join(workingDir, "vhd"),
 		downloadDir: filepath.Join(workingDir, "downloads"),
 		progress:    make(map[string]*DownloadProgress),
+		prober:      NewImageProber(nil),
+		httpClient:  DefaultHTTPTransport().Client(0), // 下载大文件，不限制总时长
+		logger:      utils.NewNopLogger(),
+	}
+}
+
+// SetLogger 设置日志记录器
+func (vm *VHDManager) SetLogger(logger *utils.Logger) {
+	vm.logger = logger.With("component", "vhd")
+}
+
+// SetDownloadConfig 应用下载配置：保存目录和限速
+func (vm *VHDManager) SetDownloadConfig(config DownloadConfig) {
+	if config.Dir != "" {
+		vm.vhdDir = config.Dir
 	}
+	vm.rateLimit = config.RateLimit
 }
 
 // Initialize 初始化VHD管理器
@@ -163,19 +187,15 @@ func (vm *VHDManager) DownloadVHD(vhd VHDInfo) error {
 	}
 	vm.progressMutex.Unlock()
 
-	// 创建HTTP请求
-	resp, err := http.Get(vhd.URL)
+	// 打开镜像（HTTP地址或本地目录中的file://地址）
+	vm.logger.Info("开始下载VHD", "name", vhd.Name, "url", vhd.URL)
+	body, contentLength, err := openImageSource(vm.httpClient, vhd.URL)
 	if err != nil {
+		vm.logger.Error("下载VHD失败", "name", vhd.Name, "error", err)
 		vm.updateProgress(vhd.Name, 0, "error", fmt.Sprintf("下载失败: %v", err))
 		return err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		err := fmt.Errorf("HTTP错误: %d", resp.StatusCode)
-		vm.updateProgress(vhd.Name, 0, "error", err.Error())
-		return err
-	}
+	defer body.Close()
 
 	// 创建本地文件
 	file, err := os.Create(localPath)
@@ -186,16 +206,24 @@ func (vm *VHDManager) DownloadVHD(vhd VHDInfo) error {
 	defer file.Close()
 
 	// 下载文件
-	contentLength := resp.ContentLength
 	var downloaded int64
+	startedAt := time.Now()
 
 	buffer := make([]byte, 32*1024) // 32KB buffer
 	for {
-		n, err := resp.Body.Read(buffer)
+		n, err := body.Read(buffer)
 		if n > 0 {
 			file.Write(buffer[:n])
 			downloaded += int64(n)
 
+			// 限速：已下载的字节数超过按速率应下载的量时等待
+			if vm.rateLimit > 0 {
+				expected := time.Duration(float64(downloaded) / float64(vm.rateLimit) * float64(time.Second))
+				if wait := expected - time.Since(startedAt); wait > 0 {
+					time.Sleep(wait)
+				}
+			}
+
 			// 更新进度
 			if contentLength > 0 {
 				percentage := int((downloaded * 100) / contentLength)
@@ -206,15 +234,29 @@ func (vm *VHDManager) DownloadVHD(vhd VHDInfo) error {
 			break
 		}
 		if err != nil {
+			vm.logger.Error("下载VHD失败", "name", vhd.Name, "downloaded", downloaded, "error", err)
 			vm.updateProgress(vhd.Name, 0, "error", fmt.Sprintf("下载失败: %v", err))
 			return err
 		}
 	}
 
+	vm.logger.Info("VHD下载完成", "name", vhd.Name, "bytes", downloaded)
 	vm.updateProgress(vhd.Name, 100, "completed", "下载完成")
 	return nil
 }
 
+// ProbeVHD 探测VHD下载地址的大小和断点续传支持，并更新vhd.Size
+func (vm *VHDManager) ProbeVHD(ctx context.Context, vhd *VHDInfo) (*ImageProbe, error) {
+	result, err := vm.prober.Probe(ctx, vhd.URL)
+	if err != nil {
+		return nil, fmt.Errorf("探测镜像失败: %v", err)
+	}
+	if result.Size > 0 {
+		vhd.Size = fmt.Sprintf("%.2f GB", float64(result.Size)/(1024*1024*1024))
+	}
+	return result, nil
+}
+
 // InstallVHD 安装VHD系统
 func (vm *VHDManager) InstallVHD(vhdPath string, options InstallOptions) error {
 	// 检查VHD文件是否存在
@@ -298,3 +340,33 @@ func (vm *VHDManager) UnmountVHD(vhdPath string) error {
 	cmd.Stdin = strings.NewReader(fmt.Sprintf("select vdisk file=%s\ndetach vdisk\n", vhdPath))
 	return cmd.Run()
 }
+
+// openImageSource 打开镜像地址，返回内容和长度（未知时为-1）
+func openImageSource(client *http.Client, location string) (io.ReadCloser, int64, error) {
+	if strings.HasPrefix(location, "file://") {
+		path, err := fileURLPath(location)
+		if err != nil {
+			return nil, 0, err
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, 0, err
+		}
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, 0, err
+		}
+		return file, info.Size(), nil
+	}
+
+	resp, err := client.Get(location)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, 0, fmt.Errorf("HTTP错误: %d", resp.StatusCode)
+	}
+	return resp.Body, resp.ContentLength, nil
+}
@@ -104,7 +104,9 @@ export const useDownloadStore = defineStore('download', () => {
     // 按启动模式筛选
     if (filters.bootMode !== '自动检测') {
       filtered = filtered.filter(vhd => 
-        vhd.bootMode === filters.bootMode
+        vhd.bootModes && vhd.bootModes.length > 0
+          ? vhd.bootModes.includes(filters.bootMode)
+          : vhd.bootMode === filters.bootMode
       )
     }
     
@@ -1,13 +1,13 @@
 import { defineStore } from 'pinia'
 import { ref, reactive } from 'vue'
-import { GetSystemDrivers, BackupDrivers, RestoreDrivers, LoadBackupHistory, DeleteBackup } from '../utils/wails'
+import { GetSystemDrivers, BackupDrivers, RestoreDrivers, GetDriverBackupDir, LoadBackupHistory, DeleteBackup, VerifyBackup } from '../utils/wails'
 
 export const useDriverStore = defineStore('driver', () => {
   // 驱动状态
   const drivers = ref([])
   const backupRecords = ref([])
   const selectedBackup = ref(null)
-  const backupPath = ref('driver_backup')
+  const backupPath = ref('') // 由配置的 drivers.backup_dir 决定，只用于显示
   
   // 操作状态
   const isBackingUp = ref(false)
@@ -17,7 +17,8 @@ export const useDriverStore = defineStore('driver', () => {
   // 备份配置
   const backupConfig = reactive({
     enableCompression: true,
-    backupLocation: 'driver_backup',
+    format: 'zip',
+    firmware: false, // 同时备份发行版自带的固件（Linux）
     autoCleanup: false,
     maxBackups: 10
   })
@@ -37,10 +38,11 @@ export const useDriverStore = defineStore('driver', () => {
     }
   }
   
-  const loadBackupHistory = async (path) => {
+  const loadBackupHistory = async () => {
     isLoading.value = true
     try {
-      const result = await LoadBackupHistory(path || backupPath.value)
+      backupPath.value = await GetDriverBackupDir()
+      const result = await LoadBackupHistory()
       backupRecords.value = result || []
       return result
     } catch (error) {
@@ -51,10 +53,10 @@ export const useDriverStore = defineStore('driver', () => {
     }
   }
   
-  const backupSystemDrivers = async (targetPath) => {
+  const backupSystemDrivers = async () => {
     isBackingUp.value = true
     try {
-      const result = await BackupDrivers(targetPath || backupPath.value)
+      const result = await BackupDrivers(backupConfig.format || 'zip', backupConfig.firmware)
       
       // 刷新备份历史
       await loadBackupHistory()
@@ -83,7 +85,10 @@ export const useDriverStore = defineStore('driver', () => {
   
   const deleteBackupRecord = async (record) => {
     try {
-      await DeleteBackup(record)
+      const result = await DeleteBackup(record.path)
+      if (!result.success) {
+        throw new Error(result.message)
+      }
       
       // 刷新备份历史
       await loadBackupHistory()
@@ -98,6 +103,21 @@ export const useDriverStore = defineStore('driver', () => {
     }
   }
   
+  const verifyBackupRecord = async (record) => {
+    const result = await VerifyBackup(record.path)
+    if (result.status) {
+      const { success, message, ...verified } = result
+      const index = backupRecords.value.findIndex(r => r.path === record.path)
+      if (index >= 0) {
+        backupRecords.value[index] = verified
+      }
+      if (selectedBackup.value && selectedBackup.value.path === record.path) {
+        selectedBackup.value = verified
+      }
+    }
+    return result
+  }
+  
   const selectBackup = (record) => {
     selectedBackup.value = record
   }
@@ -106,10 +126,6 @@ export const useDriverStore = defineStore('driver', () => {
     selectedBackup.value = null
   }
   
-  const setBackupPath = (path) => {
-    backupPath.value = path
-  }
-  
   const updateBackupConfig = (config) => {
     Object.assign(backupConfig, config)
     
@@ -156,9 +172,6 @@ export const useDriverStore = defineStore('driver', () => {
         console.error('恢复备份配置失败:', error)
       }
     }
-    
-    // 设置备份路径
-    backupPath.value = backupConfig.backupLocation
   }
   
   return {
@@ -178,9 +191,9 @@ export const useDriverStore = defineStore('driver', () => {
     backupSystemDrivers,
     restoreDriversFromBackup,
     deleteBackupRecord,
+    verifyBackupRecord,
     selectBackup,
     clearSelection,
-    setBackupPath,
     updateBackupConfig,
     
     // Getters
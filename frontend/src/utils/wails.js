@@ -29,12 +29,12 @@ export const GetSystemDrivers = async () => {
   return [];
 };
 
-export const BackupDrivers = async (drivers) => {
+export const BackupDrivers = async (format = 'zip', firmware = false) => {
   if (isWailsEnv && window.go.main.App.BackupDrivers) {
-    return await window.go.main.App.BackupDrivers(drivers);
+    return await window.go.main.App.BackupDrivers(format, firmware);
   }
   // 开发环境模拟
-  console.log('模拟备份驱动:', drivers);
+  console.log('模拟备份驱动:', format, firmware);
   return { success: true, message: '备份完成（模拟）' };
 };
 
@@ -47,6 +47,14 @@ export const RestoreDrivers = async (backupPath) => {
   return { success: true, message: '恢复完成（模拟）' };
 };
 
+export const GetDriverBackupDir = async () => {
+  if (isWailsEnv && window.go.main.App.GetDriverBackupDir) {
+    return await window.go.main.App.GetDriverBackupDir();
+  }
+  // 开发环境模拟数据
+  return 'driver_backups';
+};
+
 export const LoadBackupHistory = async () => {
   if (isWailsEnv && window.go.main.App.LoadBackupHistory) {
     return await window.go.main.App.LoadBackupHistory();
@@ -55,15 +63,24 @@ export const LoadBackupHistory = async () => {
   return [];
 };
 
-export const DeleteBackup = async (backupId) => {
+export const DeleteBackup = async (backupPath) => {
   if (isWailsEnv && window.go.main.App.DeleteBackup) {
-    return await window.go.main.App.DeleteBackup(backupId);
+    return await window.go.main.App.DeleteBackup(backupPath);
   }
   // 开发环境模拟
-  console.log('模拟删除备份:', backupId);
+  console.log('模拟删除备份:', backupPath);
   return { success: true, message: '删除完成（模拟）' };
 };
 
+export const VerifyBackup = async (backupPath) => {
+  if (isWailsEnv && window.go.main.App.VerifyBackup) {
+    return await window.go.main.App.VerifyBackup(backupPath);
+  }
+  // 开发环境模拟
+  console.log('模拟校验备份:', backupPath);
+  return { success: true, message: '备份完整（模拟）', status: 'ok' };
+};
+
 // 下载相关
 export const GetAvailableServers = async () => {
   if (isWailsEnv && window.go.main.App.GetAvailableServers) {
@@ -96,6 +113,105 @@ export const DownloadVHD = async (vhdId, savePath) => {
   return { success: true, message: '下载开始（模拟）' };
 };
 
+// API认证相关
+export const SetAPICredentials = async (apiKey, signingSecret = '') => {
+  if (isWailsEnv && window.go.main.App.SetAPICredentials) {
+    return await window.go.main.App.SetAPICredentials(apiKey, signingSecret);
+  }
+  // 开发环境模拟
+  return { success: true, message: 'API密钥已保存（模拟）' };
+};
+
+// 密钥库相关
+export const GetSecretStoreStatus = async () => {
+  if (isWailsEnv && window.go.main.App.GetSecretStoreStatus) {
+    return await window.go.main.App.GetSecretStoreStatus();
+  }
+  return { exists: false, locked: true, path: '' };
+};
+
+export const UnlockSecretStore = async (passphrase) => {
+  if (isWailsEnv && window.go.main.App.UnlockSecretStore) {
+    return await window.go.main.App.UnlockSecretStore(passphrase);
+  }
+  console.log('模拟解锁密钥库');
+  return { success: true, message: '密钥库已解锁（模拟）', created: false };
+};
+
+export const LockSecretStore = async () => {
+  if (isWailsEnv && window.go.main.App.LockSecretStore) {
+    return await window.go.main.App.LockSecretStore();
+  }
+  return { success: true, message: '密钥库已锁定（模拟）' };
+};
+
+export const ChangeSecretStorePassphrase = async (oldPassphrase, newPassphrase) => {
+  if (isWailsEnv && window.go.main.App.ChangeSecretStorePassphrase) {
+    return await window.go.main.App.ChangeSecretStorePassphrase(oldPassphrase, newPassphrase);
+  }
+  return { success: true, message: '口令已修改（模拟）' };
+};
+
+export const SetProxyPassword = async (password) => {
+  if (isWailsEnv && window.go.main.App.SetProxyPassword) {
+    return await window.go.main.App.SetProxyPassword(password);
+  }
+  return { success: true, message: '代理密码已保存（模拟）' };
+};
+
+// reinstall脚本相关
+export const GetReinstallScriptInfo = async () => {
+  if (isWailsEnv && window.go.main.App.GetReinstallScriptInfo) {
+    return await window.go.main.App.GetReinstallScriptInfo();
+  }
+  // 开发环境模拟数据
+  return { success: true, version: 'dev', source: 'embedded' };
+};
+
+export const CheckScriptUpdate = async () => {
+  if (isWailsEnv && window.go.main.App.CheckScriptUpdate) {
+    return await window.go.main.App.CheckScriptUpdate();
+  }
+  // 开发环境模拟
+  return { success: true, available: false, currentVersion: 'dev', latestVersion: 'dev' };
+};
+
+export const ApplyScriptUpdate = async () => {
+  if (isWailsEnv && window.go.main.App.ApplyScriptUpdate) {
+    return await window.go.main.App.ApplyScriptUpdate();
+  }
+  // 开发环境模拟
+  console.log('模拟更新脚本');
+  return { success: true, message: '脚本已更新（模拟）', version: 'dev', source: 'update' };
+};
+
+export const RollbackReinstallScript = async () => {
+  if (isWailsEnv && window.go.main.App.RollbackReinstallScript) {
+    return await window.go.main.App.RollbackReinstallScript();
+  }
+  // 开发环境模拟
+  console.log('模拟回滚脚本');
+  return { success: true, message: '已回滚到内置脚本（模拟）', version: 'dev', source: 'embedded' };
+};
+
+// 安装历史相关
+export const GetInstallHistory = async (osType = '', outcome = '', limit = 0) => {
+  if (isWailsEnv && window.go.main.App.GetInstallHistory) {
+    return await window.go.main.App.GetInstallHistory(osType, outcome, limit);
+  }
+  // 开发环境模拟数据
+  return [];
+};
+
+export const ExportInstallHistory = async (savePath, format) => {
+  if (isWailsEnv && window.go.main.App.ExportInstallHistory) {
+    return await window.go.main.App.ExportInstallHistory(savePath, format);
+  }
+  // 开发环境模拟
+  console.log('模拟导出安装历史:', savePath, format);
+  return { success: true, message: '导出完成（模拟）', path: savePath };
+};
+
 // 文件操作相关
 export const SelectFile = async (filters) => {
   if (isWailsEnv && window.go.main.App.SelectFile) {
@@ -106,11 +222,153 @@ export const SelectFile = async (filters) => {
   return null;
 };
 
-export const SelectDirectory = async () => {
+export const SelectDirectory = async (defaultDir = '') => {
   if (isWailsEnv && window.go.main.App.SelectDirectory) {
-    return await window.go.main.App.SelectDirectory();
+    return await window.go.main.App.SelectDirectory(defaultDir);
   }
   // 开发环境模拟
   console.log('模拟目录选择');
   return null;
-};
\ No newline at end of file
+};
+// 镜像探测
+export const ProbeImage = async (url) => {
+  if (isWailsEnv && window.go.main.App.ProbeImage) {
+    return await window.go.main.App.ProbeImage(url);
+  }
+  // 开发环境模拟
+  return { url, size: -1, accept_ranges: false, resumable: false, segmented: false };
+};
+
+// 日志相关
+export const GetRecentLogs = async (limit = 200) => {
+  if (isWailsEnv && window.go.main.App.GetRecentLogs) {
+    return await window.go.main.App.GetRecentLogs(limit);
+  }
+  return [];
+};
+
+export const SetLogLevel = async (level) => {
+  if (isWailsEnv && window.go.main.App.SetLogLevel) {
+    return await window.go.main.App.SetLogLevel(level);
+  }
+  // 开发环境模拟
+  return { success: true, message: `日志级别已设置为${level}（模拟）` };
+};
+
+export const ExportLogs = async (savePath) => {
+  if (isWailsEnv && window.go.main.App.ExportLogs) {
+    return await window.go.main.App.ExportLogs(savePath);
+  }
+  // 开发环境模拟
+  console.log('模拟导出日志:', savePath);
+  return { success: true, message: '日志导出完成（模拟）', path: savePath };
+
+export const UploadDiagnostics = async () => {
+  if (isWailsEnv && window.go.main.App.UploadDiagnostics) {
+    return await window.go.main.App.UploadDiagnostics();
+  }
+  // 开发环境模拟
+  console.log('模拟上传诊断信息');
+  return { success: true, consent: true, message: '诊断信息已上传（模拟）', ticket_id: 'DEV-000000' };
+};
+
+export const CancelDiagnosticsUpload = async () => {
+  if (isWailsEnv && window.go.main.App.CancelDiagnosticsUpload) {
+    return await window.go.main.App.CancelDiagnosticsUpload();
+  }
+  return { success: true, message: '已取消诊断上传（模拟）' };
+};
+};
+
+// 程序配置
+export const GetConfig = async () => {
+  if (isWailsEnv && window.go.main.App.GetConfig) {
+    return await window.go.main.App.GetConfig();
+  }
+  // 开发环境模拟
+  return {
+    version: 1,
+    log_level: 'info',
+    theme: 'light',
+    download: { concurrency: 4, rate_limit: 0 }
+  };
+};
+
+export const UpdateConfig = async (changes) => {
+  if (isWailsEnv && window.go.main.App.UpdateConfig) {
+    return await window.go.main.App.UpdateConfig(changes);
+  }
+  console.log('模拟保存配置:', changes);
+  return { success: true, message: '配置已保存（模拟）' };
+};
+
+// 安装方案
+export const ListProfiles = async () => {
+  if (isWailsEnv && window.go.main.App.ListProfiles) {
+    return await window.go.main.App.ListProfiles();
+  }
+  // 开发环境模拟数据
+  return [];
+};
+
+export const GetProfile = async (name) => {
+  if (isWailsEnv && window.go.main.App.GetProfile) {
+    return await window.go.main.App.GetProfile(name);
+  }
+  return { success: false, message: '安装方案不存在（模拟）' };
+};
+
+export const CreateProfile = async (profile) => {
+  if (isWailsEnv && window.go.main.App.CreateProfile) {
+    return await window.go.main.App.CreateProfile(profile);
+  }
+  console.log('模拟创建安装方案:', profile.name);
+  return { success: true, message: '安装方案已保存（模拟）', ...profile };
+};
+
+export const UpdateProfile = async (name, profile) => {
+  if (isWailsEnv && window.go.main.App.UpdateProfile) {
+    return await window.go.main.App.UpdateProfile(name, profile);
+  }
+  console.log('模拟修改安装方案:', name);
+  return { success: true, message: '安装方案已保存（模拟）', ...profile };
+};
+
+export const DeleteProfile = async (name) => {
+  if (isWailsEnv && window.go.main.App.DeleteProfile) {
+    return await window.go.main.App.DeleteProfile(name);
+  }
+  console.log('模拟删除安装方案:', name);
+  return { success: true, message: '安装方案已删除（模拟）' };
+};
+
+export const ExportProfiles = async (savePath, names = [], format = '') => {
+  if (isWailsEnv && window.go.main.App.ExportProfiles) {
+    return await window.go.main.App.ExportProfiles(savePath, names, format);
+  }
+  console.log('模拟导出安装方案:', savePath);
+  return { success: true, message: '导出完成（模拟）', path: savePath };
+};
+
+export const ImportProfiles = async (path, format = '', overwrite = false) => {
+  if (isWailsEnv && window.go.main.App.ImportProfiles) {
+    return await window.go.main.App.ImportProfiles(path, format, overwrite);
+  }
+  console.log('模拟导入安装方案:', path);
+  return { success: true, message: '已导入0个安装方案（模拟）', imported: [] };
+};
+
+export const ApplyProfile = async (name, overrides = {}) => {
+  if (isWailsEnv && window.go.main.App.ApplyProfile) {
+    return await window.go.main.App.ApplyProfile(name, overrides);
+  }
+  return { success: false, message: '安装方案不存在（模拟）' };
+};
+
+// 订阅后端事件，返回取消订阅的函数
+export const OnEvent = (name, callback) => {
+  if (isWailsEnv && window.runtime && window.runtime.EventsOn) {
+    return window.runtime.EventsOn(name, callback);
+  }
+  return () => {};
+};
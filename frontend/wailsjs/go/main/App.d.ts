@@ -1,14 +1,48 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
-export function BackupDrivers(arg1:string):Promise<Record<string, any>>;
+export function ApplyProfile(arg1:string,arg2:Record<string, any>):Promise<Record<string, any>>;
 
-export function DeleteBackup(arg1:any):Promise<Record<string, any>>;
+export function ApplyScriptUpdate():Promise<Record<string, any>>;
+
+export function BackupDrivers(arg1:string,arg2:boolean):Promise<Record<string, any>>;
+
+export function CancelDiagnosticsUpload():Promise<Record<string, any>>;
+
+export function ChangeSecretStorePassphrase(arg1:string,arg2:string):Promise<Record<string, any>>;
+
+export function CheckScriptUpdate():Promise<Record<string, any>>;
+
+export function CreateProfile(arg1:any):Promise<Record<string, any>>;
+
+export function DeleteBackup(arg1:string):Promise<Record<string, any>>;
+
+export function DeleteProfile(arg1:string):Promise<Record<string, any>>;
 
 export function DownloadVHD(arg1:any,arg2:string):Promise<Record<string, any>>;
 
+export function ExportInstallHistory(arg1:string,arg2:string):Promise<Record<string, any>>;
+
+export function ExportLogs(arg1:string):Promise<Record<string, any>>;
+
+export function ExportProfiles(arg1:string,arg2:Array<string>,arg3:string):Promise<Record<string, any>>;
+
 export function GetAvailableServers():Promise<Array<any>>;
 
+export function GetConfig():Promise<Record<string, any>>;
+
+export function GetDriverBackupDir():Promise<string>;
+
+export function GetInstallHistory(arg1:string,arg2:string,arg3:number):Promise<Array<any>>;
+
+export function GetProfile(arg1:string):Promise<Record<string, any>>;
+
+export function GetRecentLogs(arg1:number):Promise<Array<any>>;
+
+export function GetReinstallScriptInfo():Promise<Record<string, any>>;
+
+export function GetSecretStoreStatus():Promise<Record<string, any>>;
+
 export function GetSystemDrivers():Promise<Array<any>>;
 
 export function GetSystemInfo():Promise<Record<string, any>>;
@@ -17,10 +51,36 @@ export function GetVHDListFromServer(arg1:any):Promise<Array<any>>;
 
 export function Greet(arg1:string):Promise<string>;
 
+export function ImportProfiles(arg1:string,arg2:string,arg3:boolean):Promise<Record<string, any>>;
+
+export function ListProfiles():Promise<Array<any>>;
+
 export function LoadBackupHistory():Promise<Array<any>>;
 
+export function LockSecretStore():Promise<Record<string, any>>;
+
+export function ProbeImage(arg1:string):Promise<Record<string, any>>;
+
 export function RestoreDrivers(arg1:string):Promise<Record<string, any>>;
 
-export function SelectDirectory():Promise<string>;
+export function RollbackReinstallScript():Promise<Record<string, any>>;
+
+export function SelectDirectory(arg1:string):Promise<string>;
 
 export function SelectFile(arg1:any):Promise<string>;
+
+export function SetAPICredentials(arg1:string,arg2:string):Promise<Record<string, any>>;
+
+export function SetLogLevel(arg1:string):Promise<Record<string, any>>;
+
+export function SetProxyPassword(arg1:string):Promise<Record<string, any>>;
+
+export function UnlockSecretStore(arg1:string):Promise<Record<string, any>>;
+
+export function UpdateConfig(arg1:any):Promise<Record<string, any>>;
+
+export function UpdateProfile(arg1:string,arg2:any):Promise<Record<string, any>>;
+
+export function UploadDiagnostics():Promise<Record<string, any>>;
+
+export function VerifyBackup(arg1:string):Promise<Record<string, any>>;
@@ -2,22 +2,90 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
-export function BackupDrivers(arg1) {
-  return window['go']['main']['App']['BackupDrivers'](arg1);
+export function ApplyProfile(arg1, arg2,  any>) {
+  return window['go']['main']['App']['ApplyProfile'](arg1, arg2,  any>);
+}
+
+export function ApplyScriptUpdate() {
+  return window['go']['main']['App']['ApplyScriptUpdate']();
+}
+
+export function BackupDrivers(arg1, arg2) {
+  return window['go']['main']['App']['BackupDrivers'](arg1, arg2);
+}
+
+export function CancelDiagnosticsUpload() {
+  return window['go']['main']['App']['CancelDiagnosticsUpload']();
+}
+
+export function ChangeSecretStorePassphrase(arg1, arg2) {
+  return window['go']['main']['App']['ChangeSecretStorePassphrase'](arg1, arg2);
+}
+
+export function CheckScriptUpdate() {
+  return window['go']['main']['App']['CheckScriptUpdate']();
+}
+
+export function CreateProfile(arg1) {
+  return window['go']['main']['App']['CreateProfile'](arg1);
 }
 
 export function DeleteBackup(arg1) {
   return window['go']['main']['App']['DeleteBackup'](arg1);
 }
 
+export function DeleteProfile(arg1) {
+  return window['go']['main']['App']['DeleteProfile'](arg1);
+}
+
 export function DownloadVHD(arg1, arg2) {
   return window['go']['main']['App']['DownloadVHD'](arg1, arg2);
 }
 
+export function ExportInstallHistory(arg1, arg2) {
+  return window['go']['main']['App']['ExportInstallHistory'](arg1, arg2);
+}
+
+export function ExportLogs(arg1) {
+  return window['go']['main']['App']['ExportLogs'](arg1);
+}
+
+export function ExportProfiles(arg1, arg2, arg3) {
+  return window['go']['main']['App']['ExportProfiles'](arg1, arg2, arg3);
+}
+
 export function GetAvailableServers() {
   return window['go']['main']['App']['GetAvailableServers']();
 }
 
+export function GetConfig() {
+  return window['go']['main']['App']['GetConfig']();
+}
+
+export function GetDriverBackupDir() {
+  return window['go']['main']['App']['GetDriverBackupDir']();
+}
+
+export function GetInstallHistory(arg1, arg2, arg3) {
+  return window['go']['main']['App']['GetInstallHistory'](arg1, arg2, arg3);
+}
+
+export function GetProfile(arg1) {
+  return window['go']['main']['App']['GetProfile'](arg1);
+}
+
+export function GetRecentLogs(arg1) {
+  return window['go']['main']['App']['GetRecentLogs'](arg1);
+}
+
+export function GetReinstallScriptInfo() {
+  return window['go']['main']['App']['GetReinstallScriptInfo']();
+}
+
+export function GetSecretStoreStatus() {
+  return window['go']['main']['App']['GetSecretStoreStatus']();
+}
+
 export function GetSystemDrivers() {
   return window['go']['main']['App']['GetSystemDrivers']();
 }
@@ -34,18 +102,70 @@ export function Greet(arg1) {
   return window['go']['main']['App']['Greet'](arg1);
 }
 
+export function ImportProfiles(arg1, arg2, arg3) {
+  return window['go']['main']['App']['ImportProfiles'](arg1, arg2, arg3);
+}
+
+export function ListProfiles() {
+  return window['go']['main']['App']['ListProfiles']();
+}
+
 export function LoadBackupHistory() {
   return window['go']['main']['App']['LoadBackupHistory']();
 }
 
+export function LockSecretStore() {
+  return window['go']['main']['App']['LockSecretStore']();
+}
+
+export function ProbeImage(arg1) {
+  return window['go']['main']['App']['ProbeImage'](arg1);
+}
+
 export function RestoreDrivers(arg1) {
   return window['go']['main']['App']['RestoreDrivers'](arg1);
 }
 
-export function SelectDirectory() {
-  return window['go']['main']['App']['SelectDirectory']();
+export function RollbackReinstallScript() {
+  return window['go']['main']['App']['RollbackReinstallScript']();
+}
+
+export function SelectDirectory(arg1) {
+  return window['go']['main']['App']['SelectDirectory'](arg1);
 }
 
 export function SelectFile(arg1) {
   return window['go']['main']['App']['SelectFile'](arg1);
 }
+
+export function SetAPICredentials(arg1, arg2) {
+  return window['go']['main']['App']['SetAPICredentials'](arg1, arg2);
+}
+
+export function SetLogLevel(arg1) {
+  return window['go']['main']['App']['SetLogLevel'](arg1);
+}
+
+export function SetProxyPassword(arg1) {
+  return window['go']['main']['App']['SetProxyPassword'](arg1);
+}
+
+export function UnlockSecretStore(arg1) {
+  return window['go']['main']['App']['UnlockSecretStore'](arg1);
+}
+
+export function UpdateConfig(arg1) {
+  return window['go']['main']['App']['UpdateConfig'](arg1);
+}
+
+export function UpdateProfile(arg1, arg2) {
+  return window['go']['main']['App']['UpdateProfile'](arg1, arg2);
+}
+
+export function UploadDiagnostics() {
+  return window['go']['main']['App']['UploadDiagnostics']();
+}
+
+export function VerifyBackup(arg1) {
+  return window['go']['main']['App']['VerifyBackup'](arg1);
+}
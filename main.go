@@ -2,6 +2,10 @@ package main
 
 import (
 	"embed"
+	"flag"
+	"os"
+
+	"SystemReinstaller/core"
 
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
@@ -12,8 +16,18 @@ import (
 var assets embed.FS
 
 func main() {
+	cmd := parseCommandLine(os.Args[1:])
+	if cmd.printConfig {
+		os.Exit(printConfig(cmd.config))
+	}
+
 	// Create an instance of the app structure
-	app := NewApp()
+	app := NewApp(cmd.catalog, cmd.config)
+	// 没有可用的reinstall脚本时无法安装系统，直接退出而不是启动一个不能用的界面
+	if err := app.initialize(); err != nil {
+		println("Error:", err.Error())
+		os.Exit(1)
+	}
 
 	// Create application with options
 	err := wails.Run(&options.App{
@@ -27,6 +41,7 @@ func main() {
 		},
 		BackgroundColour: &options.RGBA{R: 250, G: 250, B: 250, A: 1}, // 改为浅灰色
 		OnStartup:        app.startup,
+		OnShutdown:       app.shutdown,
 		Bind: []interface{}{
 			app,
 		},
@@ -40,3 +55,62 @@ func main() {
 		println("Error:", err.Error())
 	}
 }
+
+// commandLine 命令行参数
+type commandLine struct {
+	catalog     core.CatalogOptions
+	config      core.ConfigOptions
+	printConfig bool
+}
+
+// parseCommandLine 解析命令行参数，无法识别的参数忽略
+func parseCommandLine(args []string) commandLine {
+	var cmd commandLine
+	flags := flag.NewFlagSet("SystemReinstaller", flag.ContinueOnError)
+	flags.StringVar(&cmd.config.Path, "config", "", "配置文件路径")
+	flags.BoolVar(&cmd.printConfig, "print-config", false, "输出生效的配置及每项的来源后退出")
+	flags.Func("set", "覆盖配置项，格式为 key=value（可重复指定）", func(arg string) error {
+		override, err := core.ParseConfigOverride(arg)
+		if err != nil {
+			return err
+		}
+		cmd.config.Overrides = append(cmd.config.Overrides, override)
+		return nil
+	})
+
+	// 常用配置项的快捷参数
+	shortcut := func(name, key, usage string) {
+		flags.Func(name, usage, func(value string) error {
+			cmd.config.Overrides = append(cmd.config.Overrides, core.ConfigOverride{
+				Key:    key,
+				Value:  value,
+				Source: core.ConfigSourceCLI,
+				Origin: "-" + name,
+			})
+			return nil
+		})
+	}
+	shortcut("api-url", "api.base_url", "API地址")
+	shortcut("download-dir", "download.dir", "镜像保存目录")
+	shortcut("log-level", "log_level", "日志级别：debug, info, warning, error")
+
+	flags.Func("catalog-dir", "本地镜像目录（可重复指定）", func(dir string) error {
+		cmd.catalog.CatalogDirs = append(cmd.catalog.CatalogDirs, dir)
+		return nil
+	})
+	if err := flags.Parse(args); err != nil {
+		println("参数错误:", err.Error())
+	}
+	return cmd
+}
+
+// printConfig 输出生效的配置及每项的来源，返回进程退出码
+func printConfig(options core.ConfigOptions) int {
+	installer := core.NewSystemInstaller()
+	installer.SetConfigOptions(options)
+	if err := installer.PrintConfig(os.Stdout); err != nil {
+		println("加载配置失败:", err.Error())
+		return 1
+	}
+	return 0
+}
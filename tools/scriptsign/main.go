@@ -0,0 +1,114 @@
+// scriptsign 生成reinstall脚本发布密钥并为发布的脚本签名
+//
+//	go run ./tools/scriptsign keygen -out release.key
+//	go run ./tools/scriptsign sign -key release.key -version 2025.06.01 -base-url https://example.com/scripts/2025.06.01 reinstall.sh reinstall.bat > release.json
+//
+// keygen 输出的公钥需要登记到 core/script_update.go 的 scriptUpdatePublicKeys 中；
+// sign 输出的JSON即脚本更新接口返回的发布信息，脚本文件需上传到 base-url 下。
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"SystemReinstaller/core"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "keygen":
+		err = keygen(os.Args[2:])
+	case "sign":
+		err = sign(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "错误:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "用法: scriptsign keygen -out <私钥文件>")
+	fmt.Fprintln(os.Stderr, "      scriptsign sign -key <私钥文件> -version <版本> -base-url <地址> <脚本文件>...")
+	os.Exit(2)
+}
+
+// keygen 生成Ed25519密钥对，私钥以base64写入0600文件，公钥输出到标准输出
+func keygen(args []string) error {
+	flags := flag.NewFlagSet("keygen", flag.ExitOnError)
+	out := flags.String("out", "", "私钥输出文件")
+	flags.Parse(args)
+	if *out == "" {
+		return fmt.Errorf("需要指定 -out")
+	}
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	// O_EXCL 避免覆盖已有私钥
+	file, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(base64.StdEncoding.EncodeToString(privateKey) + "\n"); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	fmt.Println(base64.StdEncoding.EncodeToString(publicKey))
+	return nil
+}
+
+// sign 读取脚本文件并输出签名的发布信息
+func sign(args []string) error {
+	flags := flag.NewFlagSet("sign", flag.ExitOnError)
+	keyPath := flags.String("key", "", "私钥文件")
+	version := flags.String("version", "", "脚本版本号（点分数字）")
+	baseURL := flags.String("base-url", "", "脚本文件下载地址前缀")
+	flags.Parse(args)
+	if *keyPath == "" || *version == "" || *baseURL == "" || flags.NArg() == 0 {
+		usage()
+	}
+
+	encoded, err := os.ReadFile(*keyPath)
+	if err != nil {
+		return err
+	}
+	privateKey, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil {
+		return fmt.Errorf("私钥格式错误: %v", err)
+	}
+
+	files := make(map[string][]byte, flags.NArg())
+	for _, path := range flags.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		files[filepath.Base(path)] = data
+	}
+
+	release, err := core.SignScriptRelease(ed25519.PrivateKey(privateKey), *version, *baseURL, files)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(release)
+}
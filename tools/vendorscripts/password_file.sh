@@ -0,0 +1,11 @@
+# SystemReinstaller: 从 REINSTALL_PASSWORD_FILE 指向的 0600 文件读取密码，避免密码出现在进程命令行上。
+# set -- 只修改脚本内部的位置参数，不会改变 /proc/<pid>/cmdline。
+if [ -n "$REINSTALL_PASSWORD_FILE" ]; then
+    if [ ! -r "$REINSTALL_PASSWORD_FILE" ]; then
+        echo "Cannot read password file: $REINSTALL_PASSWORD_FILE" >&2
+        exit 1
+    fi
+    __reinstall_password=$(cat "$REINSTALL_PASSWORD_FILE")
+    set -- "$@" --password "$__reinstall_password"
+    unset __reinstall_password REINSTALL_PASSWORD_FILE
+fi
@@ -0,0 +1,327 @@
+package utils
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotationPolicy 日志轮转和保留策略，零值表示不限制
+type RotationPolicy struct {
+	MaxSize    int64         // 单个日志文件的最大字节数
+	Daily      bool          // 跨天时轮转
+	MaxAge     time.Duration // 旧日志的保留时间
+	MaxBackups int           // 最多保留的旧日志数量
+	Compress   bool          // 旧日志用gzip压缩
+}
+
+// DefaultRotationPolicy 默认策略：10MB或跨天轮转，保留30天内最多10个旧日志
+func DefaultRotationPolicy() RotationPolicy {
+	return RotationPolicy{
+		MaxSize:    10 << 20,
+		Daily:      true,
+		MaxAge:     30 * 24 * time.Hour,
+		MaxBackups: 10,
+		Compress:   true,
+	}
+}
+
+// RotatingFileSink 按大小和日期轮转的日志文件
+//
+// 当前日志固定写入 path，轮转后的旧日志命名为
+// <名称>-<时间>.log[.gz]，与当前日志放在同一目录。
+type RotatingFileSink struct {
+	mutex    sync.Mutex
+	path     string
+	policy   RotationPolicy
+	encoder  Encoder
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	compress sync.WaitGroup
+	// compressing 正在后台压缩的旧日志，清理时跳过，压缩完成后再清理一次
+	compressing  map[string]bool
+	compressFile func(path string) error // 测试中可替换
+}
+
+// NewRotatingFileSink 打开日志文件，已有的文件超过限制时先轮转
+func NewRotatingFileSink(path string, encoder Encoder, policy RotationPolicy) (*RotatingFileSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	sink := &RotatingFileSink{path: path, policy: policy, encoder: encoder, compressing: make(map[string]bool), compressFile: gzipFile}
+	if err := sink.open(); err != nil {
+		return nil, err
+	}
+	// 轮转启动的压缩完成后会加锁清理
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	if sink.shouldRotate(0, time.Now()) {
+		if err := sink.rotate(); err != nil {
+			return nil, err
+		}
+	}
+	sink.prune()
+	return sink, nil
+}
+
+// Path 当前日志文件路径
+func (s *RotatingFileSink) Path() string {
+	return s.path
+}
+
+// open 以追加方式打开当前日志文件
+func (s *RotatingFileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	s.file = file
+	s.size = info.Size()
+	s.openedAt = time.Now()
+	if s.size > 0 {
+		// 已有内容的文件按最后写入时间判断是否跨天
+		s.openedAt = info.ModTime()
+	}
+	return nil
+}
+
+// shouldRotate 写入n字节前是否需要轮转
+func (s *RotatingFileSink) shouldRotate(n int, now time.Time) bool {
+	if s.size == 0 {
+		return false
+	}
+	if s.policy.MaxSize > 0 && s.size+int64(n) > s.policy.MaxSize {
+		return true
+	}
+	if s.policy.Daily {
+		y1, m1, d1 := s.openedAt.Date()
+		y2, m2, d2 := now.Date()
+		return y1 != y2 || m1 != m2 || d1 != d2
+	}
+	return false
+}
+
+// Write 写入一条日志，必要时先轮转
+func (s *RotatingFileSink) Write(entry Entry) error {
+	data, err := s.encoder.Encode(entry)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.file == nil {
+		return fmt.Errorf("日志文件已关闭")
+	}
+	if s.shouldRotate(len(data), entry.Time) {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+		s.prune()
+	}
+	n, err := s.file.Write(data)
+	s.size += int64(n)
+	return err
+}
+
+// Rotate 立即轮转
+func (s *RotatingFileSink) Rotate() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.rotate(); err != nil {
+		return err
+	}
+	s.prune()
+	return nil
+}
+
+// rotate 关闭当前文件，重命名为旧日志后重新打开
+func (s *RotatingFileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	s.file = nil
+
+	backup := s.backupName(s.openedAt)
+	if err := os.Rename(s.path, backup); err != nil {
+		// 重命名失败时继续写入原文件
+		if openErr := s.open(); openErr != nil {
+			return openErr
+		}
+		return err
+	}
+	if s.policy.Compress {
+		// 压缩在后台进行，Close会等待完成；压缩期间清理会跳过该文件，完成后再按策略清理
+		s.compressing[backup] = true
+		s.compress.Add(1)
+		go func() {
+			defer s.compress.Done()
+			if err := s.compressFile(backup); err != nil {
+				fmt.Fprintf(os.Stderr, "压缩日志失败: %v\n", err)
+			}
+			s.mutex.Lock()
+			defer s.mutex.Unlock()
+			delete(s.compressing, backup)
+			s.prune()
+		}()
+	}
+	return s.open()
+}
+
+// backupName 旧日志文件名，重名时追加序号
+func (s *RotatingFileSink) backupName(t time.Time) string {
+	ext := filepath.Ext(s.path)
+	stem := strings.TrimSuffix(s.path, ext)
+	name := fmt.Sprintf("%s-%s%s", stem, t.Format("20060102-150405"), ext)
+	for i := 1; fileExists(name) || fileExists(name+".gz"); i++ {
+		name = fmt.Sprintf("%s-%s.%d%s", stem, t.Format("20060102-150405"), i, ext)
+	}
+	return name
+}
+
+// prune 按数量和时间删除旧日志，调用时需持有 s.mutex
+// 旧版本按启动时间命名的日志（<名称>_<时间>.log）也一并清理；正在压缩的日志计入数量但不删除
+func (s *RotatingFileSink) prune() {
+	if s.policy.MaxBackups <= 0 && s.policy.MaxAge <= 0 {
+		return
+	}
+
+	backups, err := s.backups()
+	if err != nil {
+		return
+	}
+	cutoff := time.Now().Add(-s.policy.MaxAge)
+	// 压缩过程中同一份日志可能同时存在 .log 和 .log.gz，只计一次
+	seen := make(map[string]bool, len(backups))
+	for _, backup := range backups {
+		original := strings.TrimSuffix(backup.path, ".gz")
+		if seen[original] {
+			continue
+		}
+		seen[original] = true
+		if s.compressing[original] {
+			continue
+		}
+		expired := s.policy.MaxAge > 0 && backup.modTime.Before(cutoff)
+		excess := s.policy.MaxBackups > 0 && len(seen) > s.policy.MaxBackups
+		if expired || excess {
+			os.Remove(original)
+			os.Remove(original + ".gz")
+		}
+	}
+}
+
+// logBackup 旧日志文件
+type logBackup struct {
+	path    string
+	modTime time.Time
+}
+
+// backups 按修改时间倒序列出旧日志
+func (s *RotatingFileSink) backups() ([]logBackup, error) {
+	dir := filepath.Dir(s.path)
+	ext := filepath.Ext(s.path)
+	stem := strings.TrimSuffix(filepath.Base(s.path), ext)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	backups := []logBackup{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || name == filepath.Base(s.path) || !strings.HasPrefix(name, stem) {
+			continue
+		}
+		rest := strings.TrimSuffix(name, ".gz")
+		if !strings.HasSuffix(rest, ext) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, logBackup{path: filepath.Join(dir, name), modTime: info.ModTime()})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].modTime.After(backups[j].modTime)
+	})
+	return backups, nil
+}
+
+// Close 关闭日志文件并等待压缩完成
+func (s *RotatingFileSink) Close() error {
+	s.mutex.Lock()
+	var err error
+	if s.file != nil {
+		err = s.file.Close()
+		s.file = nil
+	}
+	s.mutex.Unlock()
+	s.compress.Wait()
+	return err
+}
+
+// gzipFile 压缩文件为 <path>.gz 并删除原文件
+func gzipFile(path string) error {
+	source, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	info, err := source.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmpPath := path + ".gz.tmp"
+	target, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	writer := gzip.NewWriter(target)
+	writer.Name = filepath.Base(path)
+	writer.ModTime = info.ModTime()
+	if _, err := io.Copy(writer, source); err != nil {
+		writer.Close()
+		target.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		target.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := target.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path+".gz"); err != nil {
+		return err
+	}
+	// 保留原始的修改时间，按时间清理时才准确
+	os.Chtimes(path+".gz", info.ModTime(), info.ModTime())
+	source.Close()
+	return os.Remove(path)
+}
+
+// fileExists 文件是否存在
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRotatingFileSinkPrunesAfterCompression(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	sink, err := NewRotatingFileSink(path, TextEncoder{}, RotationPolicy{MaxBackups: 2, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 压缩得比轮转慢，后面的轮转一定会在前面的压缩完成前清理
+	var compressErrors atomic.Int32
+	sink.compressFile = func(path string) error {
+		time.Sleep(20 * time.Millisecond)
+		err := gzipFile(path)
+		if err != nil {
+			compressErrors.Add(1)
+		}
+		return err
+	}
+
+	// 连续轮转时，上一次的压缩可能还在进行，清理不能删掉正在压缩的文件
+	for i := 0; i < 6; i++ {
+		if err := sink.Write(Entry{Time: time.Now(), Level: LevelInfo, Message: strings.Repeat("x", 64<<10)}); err != nil {
+			t.Fatal(err)
+		}
+		if err := sink.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := compressErrors.Load(); n != 0 {
+		t.Fatalf("%d 个日志在压缩过程中被清理", n)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var backups []string
+	for _, entry := range entries {
+		if entry.Name() == "app.log" {
+			continue
+		}
+		backups = append(backups, entry.Name())
+		if !strings.HasSuffix(entry.Name(), ".log.gz") {
+			t.Errorf("压缩后留下了未压缩或临时文件: %s", entry.Name())
+		}
+	}
+	if len(backups) != 2 {
+		t.Fatalf("backups = %v, want 2 compressed logs", backups)
+	}
+}
@@ -0,0 +1,216 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Encoder 日志编码器
+type Encoder interface {
+	Encode(entry Entry) ([]byte, error)
+}
+
+// TextEncoder 文本格式：时间 [级别] 消息 key=value ...
+type TextEncoder struct{}
+
+// Encode 编码一条日志
+func (TextEncoder) Encode(entry Entry) ([]byte, error) {
+	var builder strings.Builder
+	builder.WriteString(entry.Time.Format("2006/01/02 15:04:05"))
+	builder.WriteString(" [")
+	builder.WriteString(entry.Level.String())
+	builder.WriteString("] ")
+	builder.WriteString(entry.Message)
+	for _, field := range entry.Fields {
+		builder.WriteByte(' ')
+		builder.WriteString(field.Key)
+		builder.WriteByte('=')
+		value := fmt.Sprint(field.Value)
+		if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+			value = strconv.Quote(value)
+		}
+		builder.WriteString(value)
+	}
+	builder.WriteByte('\n')
+	return []byte(builder.String()), nil
+}
+
+// JSONEncoder JSON Lines格式，字段与 time/level/msg 同级
+type JSONEncoder struct{}
+
+// Encode 编码一条日志
+func (JSONEncoder) Encode(entry Entry) ([]byte, error) {
+	data, err := json.Marshal(entryMap(entry))
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// reservedFieldPrefix 与 time/level/msg 同名的字段加上的前缀
+const reservedFieldPrefix = "fields."
+
+// entryMap 转换为map，便于JSON编码和发送到前端
+// 与 time/level/msg 同名的字段改名为 fields.<名称>，不会覆盖保留键或被保留键覆盖
+func entryMap(entry Entry) map[string]interface{} {
+	record := make(map[string]interface{}, len(entry.Fields)+3)
+	for _, field := range entry.Fields {
+		key := field.Key
+		switch key {
+		case "time", "level", "msg":
+			key = reservedFieldPrefix + key
+		}
+		record[key] = field.Value
+	}
+	record["time"] = entry.Time.Format(time.RFC3339Nano)
+	record["level"] = strings.ToLower(entry.Level.String())
+	record["msg"] = entry.Message
+	return record
+}
+
+// WriterSink 编码后写入io.Writer
+type WriterSink struct {
+	mutex   sync.Mutex // 串行写入，io.Writer 不一定支持并发
+	writer  io.Writer
+	encoder Encoder
+}
+
+// NewConsoleSink 输出到控制台
+func NewConsoleSink(writer io.Writer, encoder Encoder) *WriterSink {
+	return &WriterSink{writer: writer, encoder: encoder}
+}
+
+// Write 写入一条日志
+func (s *WriterSink) Write(entry Entry) error {
+	data, err := s.encoder.Encode(entry)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	_, err = s.writer.Write(data)
+	return err
+}
+
+// Close 控制台不需要关闭
+func (s *WriterSink) Close() error {
+	return nil
+}
+
+// FileSink 追加写入日志文件
+type FileSink struct {
+	file    *os.File
+	encoder Encoder
+}
+
+// NewFileSink 打开（必要时创建）日志文件
+func NewFileSink(path string, encoder Encoder) (*FileSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{file: file, encoder: encoder}, nil
+}
+
+// Path 日志文件路径
+func (s *FileSink) Path() string {
+	return s.file.Name()
+}
+
+// Write 写入一条日志
+func (s *FileSink) Write(entry Entry) error {
+	data, err := s.encoder.Encode(entry)
+	if err != nil {
+		return err
+	}
+	_, err = s.file.Write(data)
+	return err
+}
+
+// Close 关闭日志文件
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// RingSink 在内存中保留最近的日志，供界面和诊断导出读取
+type RingSink struct {
+	mutex   sync.Mutex
+	entries []Entry
+	next    int
+	full    bool
+}
+
+// NewRingSink 创建容量为capacity的环形缓冲
+func NewRingSink(capacity int) *RingSink {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &RingSink{entries: make([]Entry, capacity)}
+}
+
+// Write 写入一条日志，满了以后覆盖最旧的
+func (s *RingSink) Write(entry Entry) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.entries[s.next] = entry
+	s.next = (s.next + 1) % len(s.entries)
+	if s.next == 0 {
+		s.full = true
+	}
+	return nil
+}
+
+// Entries 按时间顺序返回缓冲中的日志
+func (s *RingSink) Entries() []Entry {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.full {
+		return append([]Entry(nil), s.entries[:s.next]...)
+	}
+	entries := make([]Entry, 0, len(s.entries))
+	entries = append(entries, s.entries[s.next:]...)
+	return append(entries, s.entries[:s.next]...)
+}
+
+// Close 清空缓冲
+func (s *RingSink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.next, s.full = 0, false
+	return nil
+}
+
+// EventSink 将日志作为事件发送到前端
+//
+// emit 通常为 wails runtime.EventsEmit 的包装，事件数据为
+// 包含 time/level/msg 及各字段的对象。
+type EventSink struct {
+	name string
+	emit func(name string, data ...interface{})
+}
+
+// NewEventSink 创建事件输出目标
+func NewEventSink(name string, emit func(name string, data ...interface{})) *EventSink {
+	return &EventSink{name: name, emit: emit}
+}
+
+// Write 发送一条日志事件
+func (s *EventSink) Write(entry Entry) error {
+	s.emit(s.name, entryMap(entry))
+	return nil
+}
+
+// Close 事件输出不需要关闭
+func (s *EventSink) Close() error {
+	return nil
+}
@@ -2,70 +2,260 @@ package utils
 
 import (
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// Logger 日志记录器
+// LogLevelEnv 覆盖默认日志级别的环境变量
+const LogLevelEnv = "SYSTEMREINSTALLER_LOG_LEVEL"
+
+// DefaultLogDir 默认日志目录（相对于工作目录）
+const DefaultLogDir = "logs"
+
+// DefaultLogFile 默认日志文件名，轮转后的旧日志在同一目录
+const DefaultLogFile = "system_reinstaller.log"
+
+// Level 日志级别
+type Level int32
+
+// 日志级别，从低到高
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarning
+	LevelError
+)
+
+// String 级别名称
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarning:
+		return "WARNING"
+	case LevelError:
+		return "ERROR"
+	default:
+		return fmt.Sprintf("LEVEL(%d)", int32(l))
+	}
+}
+
+// ParseLevel 解析级别名称，不区分大小写
+func ParseLevel(name string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warning", "warn":
+		return LevelWarning, nil
+	case "error":
+		return LevelError, nil
+	default:
+		return LevelInfo, fmt.Errorf("未知的日志级别: %s", name)
+	}
+}
+
+// Field 日志字段
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Entry 一条日志
+type Entry struct {
+	Time    time.Time
+	Level   Level
+	Message string
+	Fields  []Field
+}
+
+// Sink 日志输出目标，Write 可能被多个goroutine同时调用
+type Sink interface {
+	Write(entry Entry) error
+	Close() error
+}
+
+// loggerCore 同一个Logger及其With派生出的子Logger共享的状态
+type loggerCore struct {
+	level    atomic.Int32
+	mutex    sync.Mutex
+	sinks    []Sink
+	closed   bool           // Close 之后的日志直接丢弃
+	inflight sync.WaitGroup // 正在锁外写入输出目标的日志，Close 等待它们完成后再关闭输出目标
+}
+
+// Logger 结构化日志记录器
+//
+// 消息之后的参数按 key, value 成对解析为字段：
+//
+//	logger.Info("下载完成", "file", name, "bytes", size)
 type Logger struct {
-	logFile *os.File
-	logger  *log.Logger
+	core   *loggerCore
+	fields []Field
+}
+
+// New 创建输出到指定目标的日志记录器
+func New(level Level, sinks ...Sink) *Logger {
+	core := &loggerCore{sinks: sinks}
+	core.level.Store(int32(level))
+	return &Logger{core: core}
+}
+
+// NewNopLogger 不输出任何内容的日志记录器，用作组件的默认值
+func NewNopLogger() *Logger {
+	return New(LevelError + 1)
 }
 
-// NewLogger 创建新的日志记录器
+// NewLogger 创建默认日志记录器：写入 logs/ 下按大小和日期轮转的日志文件并输出到控制台
+// 默认级别为INFO，可通过环境变量 SYSTEMREINSTALLER_LOG_LEVEL 调整
 func NewLogger() *Logger {
-	// 创建日志目录
-	logDir := "logs"
-	os.MkdirAll(logDir, 0755)
-	
-	// 创建日志文件
-	timestamp := time.Now().Format("20060102_150405")
-	logFileName := fmt.Sprintf("system_reinstaller_%s.log", timestamp)
-	logPath := filepath.Join(logDir, logFileName)
-	
-	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err != nil {
-		log.Printf("无法创建日志文件: %v", err)
-		return &Logger{
-			logger: log.New(os.Stdout, "", log.LstdFlags),
+	level := LevelInfo
+	if name := os.Getenv(LogLevelEnv); name != "" {
+		if parsed, err := ParseLevel(name); err == nil {
+			level = parsed
 		}
 	}
-	
-	return &Logger{
-		logFile: logFile,
-		logger:  log.New(logFile, "", log.LstdFlags),
+
+	logger := New(level, NewConsoleSink(os.Stdout, TextEncoder{}))
+
+	logPath := filepath.Join(DefaultLogDir, DefaultLogFile)
+	fileSink, err := NewRotatingFileSink(logPath, TextEncoder{}, DefaultRotationPolicy())
+	if err != nil {
+		logger.Error("无法创建日志文件", "error", err)
+		return logger
 	}
+	logger.AddSink(fileSink)
+	return logger
 }
 
-// Info 记录信息日志
-func (l *Logger) Info(message string) {
-	l.logger.Printf("[INFO] %s", message)
-	fmt.Printf("[INFO] %s\n", message)
+// AddSink 添加输出目标，Close 之后添加的输出目标直接关闭
+func (l *Logger) AddSink(sink Sink) {
+	l.core.mutex.Lock()
+	defer l.core.mutex.Unlock()
+	if l.core.closed {
+		sink.Close()
+		return
+	}
+	l.core.sinks = append(l.core.sinks, sink)
 }
 
-// Error 记录错误日志
-func (l *Logger) Error(message string) {
-	l.logger.Printf("[ERROR] %s", message)
-	fmt.Printf("[ERROR] %s\n", message)
+// SetLevel 设置最低输出级别，运行中可随时调整
+func (l *Logger) SetLevel(level Level) {
+	l.core.level.Store(int32(level))
 }
 
-// Warning 记录警告日志
-func (l *Logger) Warning(message string) {
-	l.logger.Printf("[WARNING] %s", message)
-	fmt.Printf("[WARNING] %s\n", message)
+// Level 当前最低输出级别
+func (l *Logger) Level() Level {
+	return Level(l.core.level.Load())
+}
+
+// Enabled 指定级别是否会输出
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.Level()
+}
+
+// With 返回附带固定字段的子Logger
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	fields := make([]Field, 0, len(l.fields)+len(keyvals)/2)
+	fields = append(fields, l.fields...)
+	fields = append(fields, toFields(keyvals)...)
+	return &Logger{core: l.core, fields: fields}
 }
 
 // Debug 记录调试日志
-func (l *Logger) Debug(message string) {
-	l.logger.Printf("[DEBUG] %s", message)
-	fmt.Printf("[DEBUG] %s\n", message)
+func (l *Logger) Debug(message string, keyvals ...interface{}) {
+	l.log(LevelDebug, message, keyvals)
+}
+
+// Info 记录信息日志
+func (l *Logger) Info(message string, keyvals ...interface{}) {
+	l.log(LevelInfo, message, keyvals)
+}
+
+// Warning 记录警告日志
+func (l *Logger) Warning(message string, keyvals ...interface{}) {
+	l.log(LevelWarning, message, keyvals)
+}
+
+// Error 记录错误日志
+func (l *Logger) Error(message string, keyvals ...interface{}) {
+	l.log(LevelError, message, keyvals)
 }
 
-// Close 关闭日志文件
+// log 组装日志并写入所有输出目标
+func (l *Logger) log(level Level, message string, keyvals []interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	fields := l.fields
+	if len(keyvals) > 0 {
+		fields = make([]Field, 0, len(l.fields)+len(keyvals)/2)
+		fields = append(fields, l.fields...)
+		fields = append(fields, toFields(keyvals)...)
+	}
+	entry := Entry{Time: time.Now(), Level: level, Message: message, Fields: fields}
+
+	// 锁内只复制输出目标列表，写入在锁外进行，慢的输出目标（如前端事件）不会阻塞其他goroutine记录日志
+	l.core.mutex.Lock()
+	if l.core.closed {
+		l.core.mutex.Unlock()
+		return
+	}
+	sinks := append([]Sink(nil), l.core.sinks...)
+	l.core.inflight.Add(1)
+	l.core.mutex.Unlock()
+	defer l.core.inflight.Done()
+	for _, sink := range sinks {
+		if err := sink.Write(entry); err != nil {
+			fmt.Fprintf(os.Stderr, "写入日志失败: %v\n", err)
+		}
+	}
+}
+
+// Close 关闭所有输出目标，之后记录的日志被丢弃
+// 先等待已经开始写入的日志完成，输出目标不会在写入过程中被关闭
 func (l *Logger) Close() {
-	if l.logFile != nil {
-		l.logFile.Close()
+	l.core.mutex.Lock()
+	if l.core.closed {
+		l.core.mutex.Unlock()
+		return
+	}
+	l.core.closed = true
+	sinks := l.core.sinks
+	l.core.sinks = nil
+	l.core.mutex.Unlock()
+
+	l.core.inflight.Wait()
+	for _, sink := range sinks {
+		sink.Close()
 	}
-}
\ No newline at end of file
+}
+
+// toFields 将 key, value 成对的参数转换为字段，缺少值的键记为 !BADKEY
+func toFields(keyvals []interface{}) []Field {
+	fields := make([]Field, 0, (len(keyvals)+1)/2)
+	for i := 0; i < len(keyvals); i += 2 {
+		if i+1 >= len(keyvals) {
+			fields = append(fields, Field{Key: "!BADKEY", Value: keyvals[i]})
+			break
+		}
+		key, ok := keyvals[i].(string)
+		if !ok {
+			key = fmt.Sprint(keyvals[i])
+		}
+		value := keyvals[i+1]
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		fields = append(fields, Field{Key: key, Value: value})
+	}
+	return fields
+}
@@ -0,0 +1,163 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+)
+
+// funcSink 调用函数的输出目标
+type funcSink func(entry Entry)
+
+func (f funcSink) Write(entry Entry) error { f(entry); return nil }
+func (f funcSink) Close() error            { return nil }
+
+func TestLoggerCallsSinksOutsideLock(t *testing.T) {
+	logger := New(LevelDebug)
+	done := make(chan struct{})
+	// 输出目标在写入时再次记录日志或添加输出目标，持有锁时会死锁
+	logger.AddSink(funcSink(func(entry Entry) {
+		if entry.Message == "outer" {
+			logger.AddSink(funcSink(func(Entry) {}))
+			logger.Info("inner")
+			close(done)
+		}
+	}))
+
+	go logger.Info("outer")
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("在输出目标中记录日志时死锁")
+	}
+}
+
+func TestWriterSinkConcurrentWrites(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(LevelDebug, NewConsoleSink(&buf, JSONEncoder{}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			logger.Info("message", "n", i)
+		}(i)
+	}
+	wg.Wait()
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) != 20 {
+		t.Fatalf("日志行数 = %d, want 20", len(lines))
+	}
+	for _, line := range lines {
+		var record map[string]interface{}
+		if err := json.Unmarshal(line, &record); err != nil {
+			t.Fatalf("日志行损坏: %q", line)
+		}
+	}
+}
+
+func TestEntryMapReservedKeys(t *testing.T) {
+	now := time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC)
+	record := entryMap(Entry{
+		Time:    now,
+		Level:   LevelWarning,
+		Message: "real",
+		Fields:  []Field{{"time", "user"}, {"level", "debug"}, {"msg", "fake"}, {"file", "a.txt"}},
+	})
+
+	want := map[string]interface{}{
+		"time":         now.Format(time.RFC3339Nano),
+		"level":        "warning",
+		"msg":          "real",
+		"fields.time":  "user",
+		"fields.level": "debug",
+		"fields.msg":   "fake",
+		"file":         "a.txt",
+	}
+	if len(record) != len(want) {
+		t.Fatalf("entryMap = %v, want %v", record, want)
+	}
+	for key, value := range want {
+		if record[key] != value {
+			t.Errorf("%s = %v, want %v", key, record[key], value)
+		}
+	}
+}
+
+// closeCheckSink 记录写入次数，关闭后仍被写入时报错
+type closeCheckSink struct {
+	mutex      sync.Mutex
+	closed     bool
+	writes     int
+	afterClose int
+	firstWrite sync.Once
+	started    chan struct{} // 不为nil时第一次写入先通知再等待 writeDelay
+	writeDelay time.Duration
+}
+
+func (s *closeCheckSink) Write(entry Entry) error {
+	if s.started != nil {
+		s.firstWrite.Do(func() {
+			close(s.started)
+			time.Sleep(s.writeDelay)
+		})
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		s.afterClose++
+	}
+	s.writes++
+	return nil
+}
+
+func (s *closeCheckSink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.closed = true
+	return nil
+}
+
+func TestLoggerCloseDropsLaterWrites(t *testing.T) {
+	sink := &closeCheckSink{writeDelay: 50 * time.Millisecond, started: make(chan struct{})}
+	logger := New(LevelDebug, sink)
+	child := logger.With("component", "test")
+
+	// 第一条日志在写入中途时关闭，Close 应等待它写完
+	go logger.Info("in flight")
+	<-sink.started
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				child.Info("concurrent")
+			}
+		}()
+	}
+	logger.Close()
+	wg.Wait()
+	child.Info("after close")
+	logger.Close()
+
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	if sink.afterClose != 0 {
+		t.Fatalf("关闭后仍写入了 %d 条日志", sink.afterClose)
+	}
+	if sink.writes == 0 {
+		t.Fatal("正在写入的日志应在关闭前完成")
+	}
+
+	added := &closeCheckSink{}
+	logger.AddSink(added)
+	if !added.closed {
+		t.Fatal("关闭后添加的输出目标应被直接关闭")
+	}
+}